@@ -0,0 +1,83 @@
+package carwings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A ResponseHook is called with the raw JSON of every response from
+// the Carwings service, the endpoint it came from and when it was
+// received.  data must not be modified or kept after it returns.
+type ResponseHook func(endpoint string, received time.Time, data []byte)
+
+// WithResponseHook calls h with every response the session receives.
+// Responses to logging in include the session ID, so treat what h
+// keeps like a password.  It can be given more than once, and along
+// with WithArchiveDir; the hooks are called in the order given.
+func WithResponseHook(h ResponseHook) Option {
+	return func(s *Session) { s.addResponseHook(h) }
+}
+
+// WithArchiveDir writes every response the session receives to its
+// own file in dir, named after when it was received and its endpoint,
+// e.g. 20240501T100000.000Z-BatteryStatusRecordsRequest.json.  The
+// directory is created if it doesn't exist.  Failures to write are
+// logged, and don't fail the request.
+func WithArchiveDir(dir string) Option {
+	return func(s *Session) {
+		s.addResponseHook(func(endpoint string, received time.Time, data []byte) {
+			if err := archiveResponse(dir, endpoint, received, data); err != nil {
+				s.logf("carwings: archiving %s response: %v", endpoint, err)
+			}
+		})
+	}
+}
+
+// addResponseHook makes the session call h with every response, after
+// the hooks it already has.
+func (s *Session) addResponseHook(h ResponseHook) {
+	prev := s.responseHook
+	if prev == nil {
+		s.responseHook = h
+		return
+	}
+	s.responseHook = func(endpoint string, received time.Time, data []byte) {
+		prev(endpoint, received, data)
+		h(endpoint, received, data)
+	}
+}
+
+func archiveResponse(dir, endpoint string, received time.Time, data []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	// Responses to the same endpoint can arrive in the same
+	// millisecond, like the months of GetMonthlyStatisticsRange, so
+	// later ones get a sequence number after the time instead of
+	// replacing the first.
+	stamp := received.UTC().Format("20060102T150405.000Z")
+	endpoint = strings.TrimSuffix(endpoint, ".php")
+	for n := 1; ; n++ {
+		name := stamp + "-" + endpoint + ".json"
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d-%s.json", stamp, n, endpoint)
+		}
+
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+}
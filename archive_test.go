@@ -0,0 +1,62 @@
+package carwings
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArchiveDir(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": monthlyHandler(),
+	})
+
+	var (
+		mu     sync.Mutex
+		hooked int
+	)
+	dir := t.TempDir()
+	s := newTestSession(t, ts,
+		WithLocation(time.UTC),
+		WithResponseHook(func(string, time.Time, []byte) {
+			mu.Lock()
+			hooked++
+			mu.Unlock()
+		}),
+		WithArchiveDir(dir),
+
+		// Every response is received at the same time
+		WithClock(fixedClock(testNow)),
+	)
+
+	from := time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.GetMonthlyStatisticsRange(from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each month is archived, though they were received together
+	months, err := filepath.Glob(filepath.Join(dir, "*-PriceSimulatorDetailInfoRequest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 4 {
+		t.Errorf("archived %q, want 4 months", months)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20181020T120000.000Z-PriceSimulatorDetailInfoRequest.json")); err != nil {
+		t.Error(err)
+	}
+
+	// The hook given before WithArchiveDir still sees every response
+	all, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if hooked != len(all) {
+		t.Errorf("hook called %d times for %d responses", hooked, len(all))
+	}
+}
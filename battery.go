@@ -0,0 +1,237 @@
+package carwings
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// UpdateStatus asks the Carwings service to request an update from
+// the vehicle.  This is an asynchronous operation: it returns a
+// "result key" that must be used to poll for status with the
+// CheckUpdate method.
+func (s *Session) UpdateStatus() (string, error) {
+	return s.UpdateStatusContext(context.Background())
+}
+
+// UpdateStatusContext is like UpdateStatus, but uses ctx for its requests.
+func (s *Session) UpdateStatusContext(ctx context.Context) (string, error) {
+	if s.MinUpdateInterval > 0 {
+		s.updateMu.Lock()
+		defer s.updateMu.Unlock()
+
+		if !s.lastUpdate.IsZero() && s.now().Sub(s.lastUpdate) < s.MinUpdateInterval {
+			return s.lastUpdateKey, nil
+		}
+	}
+
+	resp, err := call[wire.ResultKey](ctx, s, "BatteryStatusCheckRequest.php", nil)
+	if err != nil {
+		return "", err
+	}
+
+	if s.MinUpdateInterval > 0 {
+		s.lastUpdate, s.lastUpdateKey = s.now(), resp.ResultKey
+	}
+
+	return resp.ResultKey, nil
+}
+
+// CheckUpdate returns whether the update corresponding to the
+// provided result key has finished.
+func (s *Session) CheckUpdate(resultKey string) (bool, error) {
+	return s.CheckUpdateContext(context.Background(), resultKey)
+}
+
+// CheckUpdateContext is like CheckUpdate, but uses ctx for its requests.
+func (s *Session) CheckUpdateContext(ctx context.Context, resultKey string) (bool, error) {
+	params := url.Values{}
+	params.Set("resultKey", resultKey)
+
+	resp, err := call[wire.Result](ctx, s, "BatteryStatusCheckResultRequest.php", params)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.OperationResult == electricWaveAbnormal {
+		err = ErrUpdateFailed
+	}
+
+	return resp.ResponseFlag == 1, err
+}
+
+// BatteryStatus returns the most recent battery status from the
+// Carwings service.  Note that this data is not real-time: it is
+// cached from the last time the vehicle data was updated.  Use
+// UpdateStatus method to update vehicle data.
+func (s *Session) BatteryStatus() (BatteryStatus, error) {
+	return s.BatteryStatusContext(context.Background())
+}
+
+// BatteryStatusContext is like BatteryStatus, but uses ctx for its requests.
+func (s *Session) BatteryStatusContext(ctx context.Context) (BatteryStatus, error) {
+	resp, err := call[wire.BatteryStatus](ctx, s, "BatteryStatusRecordsRequest.php", nil)
+	if err != nil {
+		return BatteryStatus{}, err
+	}
+
+	if wire.IsEmpty(resp.BatteryStatusRecords) {
+		return BatteryStatus{}, ErrBatteryStatusUnavailable
+	}
+
+	var batrec wire.BatteryStatusRecord
+	partial := s.decode("BatteryStatusRecordsRequest.php", "BatteryStatusRecords", resp.BatteryStatusRecords, &batrec)
+	if partial != nil && s.ParseMode != ParseLenient {
+		return BatteryStatus{}, partial
+	}
+
+	remaining := batrec.BatteryStatus.BatteryRemainingAmount.Int()
+	remainingWH := batrec.BatteryStatus.BatteryRemainingAmountWH.Int()
+	capacity := batrec.BatteryStatus.BatteryCapacity.Int()
+
+	soc := batrec.BatteryStatus.SOC.Value.Int()
+	if soc == 0 && capacity > 0 {
+		soc = int(math.Round(float64(remaining) / float64(capacity) * 100))
+	}
+
+	var timestamp time.Time
+	if t := time.Time(batrec.NotificationDateAndTime); !t.IsZero() {
+		timestamp = t.In(s.location())
+	}
+
+	voltage := chargeVoltage(batrec.ChargeMode)
+
+	bs := BatteryStatus{
+		Timestamp:          timestamp,
+		Retrieved:          s.now(),
+		Capacity:           capacity,
+		Remaining:          remaining,
+		RemainingWH:        remainingWH,
+		StateOfCharge:      soc,
+		CruisingRangeACOn:  batrec.CruisingRangeAcOn.Int(),
+		CruisingRangeACOff: batrec.CruisingRangeAcOff.Int(),
+		PluginState:        PluginState(batrec.PluginState),
+		ChargingStatus:     ChargingStatus(batrec.BatteryStatus.BatteryChargingStatus),
+		TimeToFull: TimeToFull{
+			Level1:      time.Duration(batrec.TimeRequiredToFull.HourRequiredToFull.Int())*time.Hour + time.Duration(batrec.TimeRequiredToFull.MinutesRequiredToFull.Int())*time.Minute,
+			Level2:      time.Duration(batrec.TimeRequiredToFull200.HourRequiredToFull.Int())*time.Hour + time.Duration(batrec.TimeRequiredToFull200.MinutesRequiredToFull.Int())*time.Minute,
+			Level2At6kW: time.Duration(batrec.TimeRequiredToFull200_6kW.HourRequiredToFull.Int())*time.Hour + time.Duration(batrec.TimeRequiredToFull200_6kW.MinutesRequiredToFull.Int())*time.Minute,
+		},
+		ChargeVoltage: voltage,
+	}
+	bs.ChargeType = ChargeTypeOf(bs.ChargingStatus, bs.PluginState, voltage)
+
+	// The status is only updated when the vehicle is asked for new
+	// data, so estimate the charging power from the last different
+	// sample we saw.
+	s.mu.Lock()
+	if bs.Timestamp.Equal(s.lastBattery.Timestamp) {
+		bs.ChargingPower = s.lastBattery.ChargingPower
+	} else {
+		bs.ChargingPower = EstimateChargingPower(s.lastBattery, bs)
+		s.lastBattery = bs
+	}
+	s.mu.Unlock()
+
+	if partial == nil {
+		s.setLastStatus(func(last *lastStatus) {
+			last.Battery, last.BatteryRetrieved = bs, bs.Retrieved
+		})
+	}
+
+	return bs, partial
+}
+
+// chargeVoltage returns the supply voltage in a ChargeMode like
+// "220V", or zero for other values like NOT_CHARGING.
+func chargeVoltage(mode string) int {
+	v, err := strconv.Atoi(strings.TrimSuffix(mode, "V"))
+	if err != nil || v <= 0 || !strings.HasSuffix(mode, "V") {
+		return 0
+	}
+	return v
+}
+
+// UsageCounters returns the vehicle's lifetime quick and normal charge
+// counts, which come with its battery status records.  Like
+// BatteryStatus, they are from the last time the vehicle data was
+// updated.  It returns ErrUsageCountersUnavailable for vehicles that
+// don't report them.
+func (s *Session) UsageCounters() (UsageCounters, error) {
+	return s.UsageCountersContext(context.Background())
+}
+
+// UsageCountersContext is like UsageCounters, but uses ctx for its requests.
+func (s *Session) UsageCountersContext(ctx context.Context) (UsageCounters, error) {
+	resp, err := call[wire.BatteryStatus](ctx, s, "BatteryStatusRecordsRequest.php", nil)
+	if err != nil {
+		return UsageCounters{}, err
+	}
+
+	if wire.IsEmpty(resp.BatteryStatusRecords) {
+		return UsageCounters{}, ErrBatteryStatusUnavailable
+	}
+
+	var batrec wire.BatteryStatusRecord
+	if err := s.decode("BatteryStatusRecordsRequest.php", "BatteryStatusRecords", resp.BatteryStatusRecords, &batrec); err != nil && s.ParseMode != ParseLenient {
+		return UsageCounters{}, err
+	}
+	if batrec.QuickChargeCount == nil || batrec.NormalChargeCount == nil {
+		return UsageCounters{}, ErrUsageCountersUnavailable
+	}
+
+	var timestamp time.Time
+	if t := time.Time(batrec.NotificationDateAndTime); !t.IsZero() {
+		timestamp = t.In(s.location())
+	}
+
+	return UsageCounters{
+		Timestamp:     timestamp,
+		QuickCharges:  batrec.QuickChargeCount.Int(),
+		NormalCharges: batrec.NormalChargeCount.Int(),
+	}, nil
+}
+
+// ChargingRequest begins charging a plugged-in vehicle.  If the
+// Session's RequirePluggedIn is set, it returns ErrNotPluggedIn
+// instead when the last battery status retrieved, if any, says the
+// vehicle isn't plugged in, unless ctx is from ContextWithForce.
+func (s *Session) ChargingRequest() error {
+	return s.ChargingRequestContext(context.Background())
+}
+
+// ChargingRequestContext is like ChargingRequest, but uses ctx for its requests.
+func (s *Session) ChargingRequestContext(ctx context.Context) error {
+	if s.RequirePluggedIn && !forced(ctx) {
+		if bs, _, ok := s.LastBatteryStatus(); ok && bs.PluginState == NotConnected {
+			return ErrNotPluggedIn
+		}
+	}
+
+	params := url.Values{}
+	params.Set("ExecuteTime", s.now().In(s.location()).Format("2006-01-02"))
+
+	_, err := call[wire.Base](ctx, s, "BatteryRemoteChargingRequest.php", params)
+	return err
+}
+
+// StopChargingRequest stops charging the vehicle.  Only some versions
+// of the Carwings portal can; the others return an error matching
+// ErrUnsupported.
+func (s *Session) StopChargingRequest() error {
+	return s.StopChargingRequestContext(context.Background())
+}
+
+// StopChargingRequestContext is like StopChargingRequest, but uses ctx for its requests.
+func (s *Session) StopChargingRequestContext(ctx context.Context) error {
+	params := url.Values{}
+	params.Set("ExecuteTime", s.now().In(s.location()).Format("2006-01-02"))
+
+	_, err := call[wire.Base](ctx, s, "BatteryRemoteChargingStopRequest.php", params)
+	return err
+}
@@ -0,0 +1,126 @@
+package carwings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// WithStatisticsCache keeps the monthly statistics of months that are
+// over in dir, so GetMonthlyStatistics only asks Carwings for each of
+// them once and refetches just the current month.  Each vehicle has
+// its own subdirectory.  A leading ~ in dir is replaced with $HOME.
+func WithStatisticsCache(dir string) Option {
+	if len(dir) > 0 && dir[0] == '~' {
+		dir = os.Getenv("HOME") + dir[1:]
+	}
+	return func(s *Session) { s.statsDir = dir }
+}
+
+// WithStatisticsGrace makes WithStatisticsCache wait until d after a
+// month is over before caching it, since trips are sometimes
+// reported days late.  By default, months are cached as soon as they
+// are over.
+func WithStatisticsGrace(d time.Duration) Option {
+	return func(s *Session) { s.statsGrace = d }
+}
+
+// monthLocation returns the time zone months are in for statistics:
+// the session's, or month's own before the session has logged in.
+func (s *Session) monthLocation(month time.Time) *time.Location {
+	if loc := s.location(); loc != nil {
+		return loc
+	}
+	return month.Location()
+}
+
+// settledMonth reports whether the statistics for month won't change
+// any more: the month is over, and the Session's statistics grace
+// period has passed since.
+func (s *Session) settledMonth(month time.Time) bool {
+	loc := s.monthLocation(month)
+	m := month.In(loc)
+	end := time.Date(m.Year(), m.Month()+1, 1, 0, 0, 0, 0, loc)
+	return !s.now().Before(end.Add(s.statsGrace))
+}
+
+// monthFile returns the cache file for month, or "" if month isn't
+// cached.
+func (s *Session) monthFile(month time.Time) string {
+	if s.statsDir == "" || !s.settledMonth(month) {
+		return ""
+	}
+
+	s.mu.Lock()
+	vin := s.VIN
+	s.mu.Unlock()
+	if vin == "" {
+		return ""
+	}
+
+	return filepath.Join(s.statsDir, vin, month.In(s.monthLocation(month)).Format("2006-01")+".json")
+}
+
+// cachedMonth returns the cached statistics for month, if there are
+// any.  A cache that can't be read is treated as empty.
+func (s *Session) cachedMonth(month time.Time) (MonthlyStatistics, bool) {
+	var ms MonthlyStatistics
+
+	fn := s.monthFile(month)
+	if fn == "" {
+		return ms, false
+	}
+
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return ms, false
+	}
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return ms, false
+	}
+
+	// Restore the time zone of the trip times, which JSON only keeps
+	// the offset of.  Older caches have the local times as UTC.
+	loc := s.monthLocation(month)
+	for _, day := range ms.Dates {
+		for i := range day.Trips {
+			t := &day.Trips[i]
+			if t.Started.Location() == time.UTC {
+				t.Started = time.Time(wire.Time(t.Started).FixLocation(loc))
+			} else {
+				t.Started = t.Started.In(loc)
+			}
+			t.GPSDateTime = t.Started
+		}
+	}
+	return ms, true
+}
+
+// cacheMonth stores the statistics for month, if it is settled.
+func (s *Session) cacheMonth(month time.Time, ms MonthlyStatistics) error {
+	fn := s.monthFile(month)
+	if fn == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(ms)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a crash doesn't leave a
+	// truncated month behind.
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
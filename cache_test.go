@@ -0,0 +1,236 @@
+package carwings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A time when August 2018 is settled in every time zone.
+var testNow = time.Date(2018, 10, 20, 12, 0, 0, 0, time.UTC)
+
+func TestStatisticsCacheRoundTrip(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": monthlyHandler("2018-08-31T00:05:00", "2018-08-31T23:30:00"),
+	})
+
+	for _, r := range testRegions {
+		dir := t.TempDir()
+		loc := loadLocation(t, r.zone)
+		s := newTestSession(t, ts,
+			WithLocation(loc),
+			WithClock(fixedClock(testNow)),
+			WithStatisticsCache(dir),
+		)
+		before := ts.count("PriceSimulatorDetailInfoRequest")
+		august := time.Date(2018, 8, 15, 12, 0, 0, 0, loc)
+
+		want, err := s.GetMonthlyStatistics(august)
+		if err != nil {
+			t.Fatalf("%s: %v", r.region, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "VIN123", "2018-08.json")); err != nil {
+			t.Errorf("%s: %v", r.region, err)
+		}
+
+		got, ok := s.cachedMonth(august)
+		if !ok {
+			t.Fatalf("%s: August not cached", r.region)
+		}
+		checkSameStatistics(t, r.region, got, want, loc)
+
+		// Asking again is answered from the cache
+		if _, err := s.GetMonthlyStatistics(august); err != nil {
+			t.Fatalf("%s: %v", r.region, err)
+		}
+		if n := ts.count("PriceSimulatorDetailInfoRequest") - before; n != 1 {
+			t.Errorf("%s: %d requests, want 1", r.region, n)
+		}
+	}
+}
+
+// checkSameStatistics checks that got, read from the cache, is the
+// same as want, with its trip times in loc.
+func checkSameStatistics(t *testing.T, region string, got, want MonthlyStatistics, loc *time.Location) {
+	t.Helper()
+
+	if got.Total != want.Total || got.ElectricityRate != want.ElectricityRate || got.EfficiencyScale != want.EfficiencyScale {
+		t.Errorf("%s: got %+v, want %+v", region, got, want)
+	}
+	if len(got.Dates) != len(want.Dates) {
+		t.Fatalf("%s: got %d days, want %d", region, len(got.Dates), len(want.Dates))
+	}
+	for i := range want.Dates {
+		g, w := got.Dates[i], want.Dates[i]
+		if g.TargetDate != w.TargetDate || len(g.Trips) != len(w.Trips) {
+			t.Fatalf("%s: got day %+v, want %+v", region, g, w)
+		}
+		for j := range w.Trips {
+			gt, wt := g.Trips[j], w.Trips[j]
+			if !gt.Started.Equal(wt.Started) || gt.Started.Location() != loc || !gt.GPSDateTime.Equal(wt.GPSDateTime) {
+				t.Errorf("%s: trip %d started %v, want %v", region, wt.TripId, gt.Started, wt.Started)
+			}
+			gt.Started, gt.GPSDateTime = wt.Started, wt.GPSDateTime
+			if gt != wt {
+				t.Errorf("%s: got trip %+v, want %+v", region, gt, wt)
+			}
+		}
+	}
+}
+
+func TestStatisticsCacheSettledMonths(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": monthlyHandler("2018-08-31T23:30:00"),
+	})
+
+	// Early on 1 October in UTC, it's still 30 September west of it.
+	// A month is settled once it's over in the session's time zone,
+	// and the grace period after that has passed.
+	now := time.Date(2018, 10, 1, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		month time.Time
+		grace time.Duration
+		want  map[string]bool // cached, by region
+	}{
+		{
+			month: time.Date(2018, 9, 15, 0, 0, 0, 0, time.UTC),
+			want:  map[string]bool{"NNA": false, "NE": true, "NML": true},
+		},
+		{
+			month: time.Date(2018, 8, 15, 0, 0, 0, 0, time.UTC),
+			want:  map[string]bool{"NNA": true, "NE": true, "NML": true},
+		},
+		{
+			month: time.Date(2018, 10, 15, 0, 0, 0, 0, time.UTC),
+			want:  map[string]bool{"NNA": false, "NE": false, "NML": false},
+		},
+		{
+			month: time.Date(2018, 9, 15, 0, 0, 0, 0, time.UTC),
+			grace: 72 * time.Hour,
+			want:  map[string]bool{"NNA": false, "NE": false, "NML": false},
+		},
+		{
+			month: time.Date(2018, 8, 15, 0, 0, 0, 0, time.UTC),
+			grace: 72 * time.Hour,
+			want:  map[string]bool{"NNA": true, "NE": true, "NML": true},
+		},
+	}
+
+	for _, r := range testRegions {
+		for _, tt := range tests {
+			s := newTestSession(t, ts,
+				WithLocation(loadLocation(t, r.zone)),
+				WithClock(fixedClock(now)),
+				WithStatisticsCache(t.TempDir()),
+				WithStatisticsGrace(tt.grace),
+			)
+			if _, err := s.GetMonthlyStatistics(tt.month); err != nil {
+				t.Fatalf("%s: %v", r.region, err)
+			}
+			if _, ok := s.cachedMonth(tt.month); ok != tt.want[r.region] {
+				t.Errorf("%s: %s with grace %v cached = %t, want %t", r.region, tt.month.Format("2006-01"), tt.grace, ok, tt.want[r.region])
+			}
+		}
+	}
+}
+
+func TestStatisticsCacheOlderFormat(t *testing.T) {
+	// Caches written before trip times had a time zone have the
+	// vehicle's local time as UTC
+	const older = `{
+		"EfficiencyScale": "kWh/100km",
+		"Dates": [{
+			"TargetDate": "2018-08-31",
+			"Trips": [{
+				"TripId": "1",
+				"PowerConsumptTotal": "2461.12",
+				"PowerConsumptMoter": "3812.22",
+				"PowerConsumptMinus": "1351.1",
+				"TravelDistance": "17841",
+				"ElectricMileage": "13.8",
+				"CO2Reduction": "3",
+				"MapDisplayFlg": "NONACTIVE",
+				"GpsDatetime": "2018-08-31T23:30:00Z",
+				"Started": "2018-08-31T23:30:00Z"
+			}]
+		}],
+		"Total": {"TotalNumberOfTrips": "1", "TotalPowerConsumptTotal": "0", "TotalPowerConsumptMoter": "0", "TotalPowerConsumptMinus": "0", "TotalTravelDistance": "17841", "TotalElectricMileage": "0", "TotalCO2Reductiont": "0"}
+	}`
+
+	ts := newTestService(t, nil)
+	for _, r := range testRegions {
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "VIN123"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "VIN123", "2018-08.json"), []byte(older), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		loc := loadLocation(t, r.zone)
+		s := newTestSession(t, ts,
+			WithLocation(loc),
+			WithClock(fixedClock(testNow)),
+			WithStatisticsCache(dir),
+		)
+		ms, ok := s.cachedMonth(time.Date(2018, 8, 15, 12, 0, 0, 0, loc))
+		if !ok {
+			t.Fatalf("%s: August not cached", r.region)
+		}
+
+		want := time.Date(2018, 8, 31, 23, 30, 0, 0, loc)
+		trip := ms.Dates[0].Trips[0]
+		if !trip.Started.Equal(want) || trip.Started.Location() != loc || !trip.GPSDateTime.Equal(want) {
+			t.Errorf("%s: started %v, GPSDateTime %v, want %v", r.region, trip.Started, trip.GPSDateTime, want)
+		}
+	}
+}
+
+func TestStatisticsCacheUnreadable(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": monthlyHandler("2018-08-31T23:30:00"),
+	})
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "VIN123"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "VIN123", "2018-08.json"), []byte(`{"Dates": [`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestSession(t, ts, WithClock(fixedClock(testNow)), WithStatisticsCache(dir))
+	august := time.Date(2018, 8, 15, 12, 0, 0, 0, time.UTC)
+	if _, ok := s.cachedMonth(august); ok {
+		t.Fatal("truncated cache read")
+	}
+
+	// The month is requested again, and the cache replaced
+	if _, err := s.GetMonthlyStatistics(august); err != nil {
+		t.Fatal(err)
+	}
+	if n := ts.count("PriceSimulatorDetailInfoRequest"); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+	if _, ok := s.cachedMonth(august); !ok {
+		t.Error("August not cached again")
+	}
+}
+
+func TestStatisticsCacheNotLoggedIn(t *testing.T) {
+	// Without a time zone or VIN from logging in, nothing is cached
+	dir := t.TempDir()
+	s := &Session{clock: fixedClock(testNow), statsDir: dir}
+	august := time.Date(2018, 8, 15, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := s.cachedMonth(august); ok {
+		t.Error("August cached before logging in")
+	}
+	if err := s.cacheMonth(august, MonthlyStatistics{}); err != nil {
+		t.Error(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cached %d files before logging in", len(entries))
+	}
+}
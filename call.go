@@ -0,0 +1,67 @@
+package carwings
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// call sends a request to endpoint and returns the response decoded
+// into a T, one of the response shapes in the wire package.
+func call[T any, PT interface {
+	*T
+	wire.Response
+}](ctx context.Context, s *Session, endpoint string, params url.Values) (T, error) {
+	var resp T
+	err := s.apiRequest(ctx, endpoint, params, PT(&resp))
+	return resp, err
+}
+
+// envelope is the response to an endpoint called with Call.  It
+// keeps the rest of the response, without the fields in wire.Base,
+// so it can be decoded into the caller's type according to the
+// Session's ParseMode.
+type envelope struct {
+	wire.Base
+	rest json.RawMessage
+}
+
+func (e *envelope) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.Base); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	delete(fields, "status")
+	delete(fields, "message")
+
+	var err error
+	e.rest, err = json.Marshal(fields)
+	return err
+}
+
+// Call sends a request to an endpoint of the Carwings service that
+// this package doesn't have a method for, such as
+// "GetScheduledACRemoteRequest.php", and returns the response decoded
+// into a T.  The session ID, VIN and other common parameters are
+// added to params.  Status codes are checked, and errors returned, as
+// for the other methods.
+//
+// T is usually a struct with fields for the parts of the response
+// the caller is interested in.
+func Call[T any](ctx context.Context, s *Session, endpoint string, params url.Values) (T, error) {
+	var result T
+
+	env, err := call[envelope](ctx, s, endpoint, params)
+	if err != nil {
+		return result, err
+	}
+
+	err = s.decode(endpoint, "", env.rest, &result)
+	return result, err
+}
@@ -0,0 +1,211 @@
+package carwings
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// ClimateControlStatus returns the most recent climate control status
+// from the Carwings service.
+func (s *Session) ClimateControlStatus() (ClimateStatus, error) {
+	return s.ClimateControlStatusContext(context.Background())
+}
+
+// ClimateControlStatusContext is like ClimateControlStatus, but uses ctx for its requests.
+func (s *Session) ClimateControlStatusContext(ctx context.Context) (ClimateStatus, error) {
+	resp, err := call[wire.RemoteAC](ctx, s, "RemoteACRecordsRequest.php", nil)
+	if err != nil {
+		return ClimateStatus{}, err
+	}
+
+	// Sometimes the RemoteACRecords field is an empty array
+	// instead of a struct value.  This API... ¯\_(ツ)_/¯
+	if wire.IsEmpty(resp.RemoteACRecords) {
+		return ClimateStatus{}, ErrClimateStatusUnavailable
+	}
+
+	var racr wire.RemoteACRecord
+	partial := s.decode("RemoteACRecordsRequest.php", "RemoteACRecords", resp.RemoteACRecords, &racr)
+	if partial != nil && s.ParseMode != ParseLenient {
+		return ClimateStatus{}, partial
+	}
+
+	batterySec, pluggedSec := racr.ACDurationBatterySec.Int(), racr.ACDurationPluggedSec.Int()
+	if q := quirks[s.Model()]; batterySec == 0 && pluggedSec == 0 {
+		batterySec, pluggedSec = q.climateBatterySec, q.climatePluggedSec
+	}
+
+	running := racr.RemoteACOperation == "START"
+	acStopTime := time.Time(racr.ACStartStopDateAndTime).In(s.location())
+	if running {
+		if NotConnected == PluginState(racr.PluginState) {
+			acStopTime = acStopTime.Add(time.Second * time.Duration(batterySec))
+		} else {
+			acStopTime = acStopTime.Add(time.Second * time.Duration(pluggedSec))
+		}
+	}
+
+	cs := ClimateStatus{
+		LastOperationTime:  time.Time(racr.OperationDateAndTime.FixLocation(s.location())),
+		Retrieved:          s.now(),
+		Running:            running,
+		PluginState:        PluginState(racr.PluginState),
+		BatteryDuration:    batterySec,
+		PluggedDuration:    pluggedSec,
+		TemperatureUnit:    racr.PreAC_unit,
+		Temperature:        racr.PreAC_temp.Int(),
+		ACStopTime:         acStopTime,
+		CruisingRangeACOn:  racr.CruisingRangeAcOn.Int(),
+		CruisingRangeACOff: racr.CruisingRangeAcOff.Int(),
+	}
+
+	if partial == nil {
+		s.setLastStatus(func(last *lastStatus) {
+			last.Climate, last.ClimateRetrieved = cs, cs.Retrieved
+		})
+	}
+
+	return cs, partial
+}
+
+// ClimateOffRequest sends a request to turn off the climate control
+// system.  This is an asynchronous operation: it returns a "result
+// key" that can be used to poll for status with the
+// CheckClimateOffRequest method.
+func (s *Session) ClimateOffRequest() (string, error) {
+	return s.ClimateOffRequestContext(context.Background())
+}
+
+// ClimateOffRequestContext is like ClimateOffRequest, but uses ctx for its requests.
+func (s *Session) ClimateOffRequestContext(ctx context.Context) (string, error) {
+	resp, err := call[wire.ResultKey](ctx, s, "ACRemoteOffRequest.php", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.ResultKey, nil
+}
+
+// CheckClimateOffRequest returns whether the ClimateOffRequest has
+// finished.  If the vehicle reports that it failed, the error is an
+// *OperationError.
+func (s *Session) CheckClimateOffRequest(resultKey string) (bool, error) {
+	return s.CheckClimateOffRequestContext(context.Background(), resultKey)
+}
+
+// CheckClimateOffRequestContext is like CheckClimateOffRequest, but uses ctx for its requests.
+func (s *Session) CheckClimateOffRequestContext(ctx context.Context, resultKey string) (bool, error) {
+	params := url.Values{}
+	params.Set("resultKey", resultKey)
+
+	resp, err := call[wire.ClimateResult](ctx, s, "ACRemoteOffResult.php", params)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.ResponseFlag != 1 {
+		return false, nil
+	}
+
+	return true, operationResult("climate control off", resp.OperationResult)
+}
+
+// ClimateOnRequest sends a request to turn on the climate control
+// system.  This is an asynchronous operation: it returns a "result
+// key" that can be used to poll for status with the
+// CheckClimateOnRequest method.
+//
+// If the Session's MinClimateSOC is set, it returns ErrBatteryTooLow
+// instead when the last battery status retrieved, if any, is below
+// it, unless ctx is from ContextWithForce.
+func (s *Session) ClimateOnRequest() (string, error) {
+	return s.ClimateOnRequestContext(context.Background())
+}
+
+// ClimateOnRequestContext is like ClimateOnRequest, but uses ctx for its requests.
+func (s *Session) ClimateOnRequestContext(ctx context.Context) (string, error) {
+	if s.MinClimateSOC > 0 && !forced(ctx) {
+		if bs, _, ok := s.LastBatteryStatus(); ok && bs.StateOfCharge < s.MinClimateSOC {
+			return "", fmt.Errorf("%w: %d%% is below %d%%", ErrBatteryTooLow, bs.StateOfCharge, s.MinClimateSOC)
+		}
+	}
+
+	resp, err := call[wire.ResultKey](ctx, s, "ACRemoteRequest.php", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.ResultKey, nil
+}
+
+// CheckClimateOnRequest returns whether the ClimateOnRequest has
+// finished.  If the vehicle reports that it failed, the error is an
+// *OperationError.
+func (s *Session) CheckClimateOnRequest(resultKey string) (bool, error) {
+	return s.CheckClimateOnRequestContext(context.Background(), resultKey)
+}
+
+// CheckClimateOnRequestContext is like CheckClimateOnRequest, but uses ctx for its requests.
+func (s *Session) CheckClimateOnRequestContext(ctx context.Context, resultKey string) (bool, error) {
+	params := url.Values{}
+	params.Set("resultKey", resultKey)
+
+	resp, err := call[wire.ClimateResult](ctx, s, "ACRemoteResult.php", params)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.ResponseFlag != 1 {
+		return false, nil
+	}
+
+	return true, operationResult("climate control on", resp.OperationResult)
+}
+
+// CabinTempRequest sends a request to get the cabin temperature. This is an
+// asynchronous operation: it returns a "result key" that can be used
+// to poll for status with the CheckCabinTempRequest method.
+func (s *Session) CabinTempRequest() (string, error) {
+	return s.CabinTempRequestContext(context.Background())
+}
+
+// CabinTempRequestContext is like CabinTempRequest, but uses ctx for its requests.
+func (s *Session) CabinTempRequestContext(ctx context.Context) (string, error) {
+	resp, err := call[wire.ResultKey](ctx, s, "GetInteriorTemperatureRequestForNsp.php", nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.ResultKey, nil
+}
+
+// CheckCabinTempRequest returns whether the CabinTempRequest has finished.
+func (s *Session) CheckCabinTempRequest(resultKey string) (bool, error) {
+	return s.CheckCabinTempRequestContext(context.Background(), resultKey)
+}
+
+// CheckCabinTempRequestContext is like CheckCabinTempRequest, but uses ctx for its requests.
+func (s *Session) CheckCabinTempRequestContext(ctx context.Context, resultKey string) (bool, error) {
+	params := url.Values{}
+	params.Set("resultKey", resultKey)
+
+	resp, err := call[wire.CabinTempResult](ctx, s, "GetInteriorTemperatureResultForNsp.php", params)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	s.cabinTemp = resp.Temperature.Int()
+	s.mu.Unlock()
+
+	return resp.ResponseFlag == 1, nil
+}
+
+// GetCabinTemp returns the latest cached cabin temperature result.
+func (s *Session) GetCabinTemp() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cabinTemp
+}
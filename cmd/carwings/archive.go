@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// archiver writes the parts of a snapshot to JSON files in a
+// directory, named after when the snapshot was taken.
+type archiver struct {
+	dir    string
+	stamp  string
+	files  int
+	failed int
+}
+
+// save writes v to the file for name, or warns about err if getting
+// v failed.  Partial statuses from a -lenient session are saved.
+func (a *archiver) save(name string, v interface{}, err error) error {
+	if err = partial(err); err != nil {
+		fmt.Fprintf(os.Stderr, tr("WARNING: %s not archived: %v\n"), name, err)
+		a.failed++
+		return nil
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fn := filepath.Join(a.dir, a.stamp+"-"+name+".json")
+	if err := os.WriteFile(fn, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	a.files++
+	return nil
+}
+
+func runArchive(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("archive")
+	dir := fs.String("dir", "leaf-data", "directory to write the JSON files to")
+	fs.Parse(args)
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	a := &archiver{dir: *dir, stamp: time.Now().UTC().Format("20060102T150405Z")}
+
+	progress(tr("Getting latest vehicle status..."))
+
+	// Bound how long we wait for the vehicle to report its position
+	vctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	vs, err := s.VehicleStatus(vctx)
+	cancel()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if err := a.save("battery", vs.Battery, vs.BatteryErr); err != nil {
+		return err
+	}
+	if err := a.save("climate", vs.Climate, vs.ClimateErr); err != nil {
+		return err
+	}
+	if err := a.save("location", vs.Location, vs.LocationErr); err != nil {
+		return err
+	}
+
+	progress(tr("Sending daily statistics request..."))
+
+	ds, err := s.GetDailyStatisticsContext(ctx, time.Now().Local())
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := a.save("daily", ds, err); err != nil {
+		return err
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	// Go back until there's been no driving for maxEmptyMonths
+	empty := 0
+	for month := monthOf(time.Now()); empty < maxEmptyMonths; month = month.AddDate(0, -1, 0) {
+		ms, err := s.GetMonthlyStatisticsContext(ctx, month)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil && len(ms.Dates) == 0 {
+			empty++
+			continue
+		}
+
+		failed := a.failed
+		if err := a.save("monthly-"+month.Format("2006-01"), ms, err); err != nil {
+			return err
+		}
+		if a.failed > failed {
+			// Don't go back forever if every request fails
+			empty++
+		} else {
+			empty = 0
+		}
+	}
+
+	fmt.Printf(tr("Archived %d files to %s\n"), a.files, a.dir)
+
+	if a.failed > 0 {
+		return exitCode(1)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+func runBackfill(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("backfill")
+	from := fs.String("from", "", "earliest month (YYYY-MM) to retrieve. Defaults to going back until there's no more driving history.")
+	emptyMonths := fs.Int("empty-months", 12, "without -from, stop going back after this many months in a row without driving")
+	fs.Parse(args)
+
+	if *emptyMonths < 1 {
+		return errors.New("-empty-months must be at least 1")
+	}
+	if cfg.cacheDir == "" {
+		return errors.New("backfill needs a -cache-dir to store the statistics in")
+	}
+
+	var first time.Time
+	if *from != "" {
+		var err error
+		if first, err = parseMonth(*from); err != nil {
+			return err
+		}
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	// Months that are over are cached as they are retrieved, and
+	// months cached by an earlier run aren't retrieved again.
+	fetched, stats, err := drivingHistory(ctx, s, first, *emptyMonths, hasDates)
+	if err != nil {
+		return err
+	}
+
+	var (
+		months, trips int
+		earliest      time.Time
+	)
+	for i, ms := range stats {
+		if !hasDates(ms) {
+			continue
+		}
+		if months == 0 {
+			earliest = fetched[i]
+		}
+		months++
+		for _, d := range ms.Dates {
+			trips += len(d.Trips)
+		}
+	}
+
+	if months == 0 {
+		fmt.Println(tr("No driving history available"))
+		return nil
+	}
+
+	fmt.Printf(tr("Retrieved %d trips in %d months since %s\n"), trips, months, earliest.Format("January 2006"))
+	return nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+	"github.com/joeshaw/carwings/httpd"
+)
+
+const (
+	// calendarRefresh is how often the calendar is downloaded again.
+	calendarRefresh = 15 * time.Minute
+
+	// calendarHorizon is how far ahead events are looked for.
+	calendarHorizon = 48 * time.Hour
+)
+
+// calendarEvent is a VEVENT from an iCalendar (RFC 5545) file.  Only
+// the properties needed to know when an event starts are kept.
+// Recurrence rules are supported for the DAILY, WEEKLY, MONTHLY and
+// YEARLY frequencies with INTERVAL, COUNT, UNTIL and, for WEEKLY,
+// BYDAY.  All-day events are ignored.
+type calendarEvent struct {
+	uid     string
+	summary string
+	start   time.Time
+	allDay  bool
+	rrule   map[string]string
+	exdates map[time.Time]bool
+}
+
+// icsProperty splits an unfolded content line into its name, parameters
+// and value.
+func icsProperty(line string) (name string, params map[string]string, value string) {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return "", nil, ""
+	}
+	value = line[i+1:]
+
+	parts := strings.Split(line[:i], ";")
+	name = strings.ToUpper(parts[0])
+	params = map[string]string{}
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return name, params, value
+}
+
+// parseICSTime parses a DATE or DATE-TIME value.  Times without a zone
+// are in the TZID parameter's zone, or local time if there isn't one.
+func parseICSTime(value string, params map[string]string) (t time.Time, allDay bool, err error) {
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	switch {
+	case len(value) == 8:
+		t, err = time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+}
+
+func parseICS(data string) ([]calendarEvent, error) {
+	// Unfold continuation lines
+	data = strings.Replace(data, "\r\n", "\n", -1)
+	data = strings.Replace(data, "\n ", "", -1)
+	data = strings.Replace(data, "\n\t", "", -1)
+
+	var (
+		events []calendarEvent
+		ev     *calendarEvent
+	)
+
+	for _, line := range strings.Split(data, "\n") {
+		name, params, value := icsProperty(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			ev = &calendarEvent{exdates: map[time.Time]bool{}}
+
+		case name == "END" && value == "VEVENT" && ev != nil:
+			if !ev.start.IsZero() {
+				events = append(events, *ev)
+			}
+			ev = nil
+
+		case ev == nil:
+			continue
+
+		case name == "UID":
+			ev.uid = value
+
+		case name == "SUMMARY":
+			ev.summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(value)
+
+		case name == "DTSTART":
+			t, allDay, err := parseICSTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTSTART %q: %v", value, err)
+			}
+			ev.start, ev.allDay = t, allDay
+
+		case name == "RRULE":
+			ev.rrule = map[string]string{}
+			for _, part := range strings.Split(value, ";") {
+				if kv := strings.SplitN(part, "=", 2); len(kv) == 2 {
+					ev.rrule[strings.ToUpper(kv[0])] = strings.ToUpper(kv[1])
+				}
+			}
+
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := parseICSTime(v, params); err == nil {
+					ev.exdates[t.UTC()] = true
+				}
+			}
+		}
+	}
+
+	return events, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// occurrences returns the start times of the event in [from, to).
+func (e calendarEvent) occurrences(from, to time.Time) []time.Time {
+	var occs []time.Time
+	add := func(t time.Time) {
+		if !t.Before(from) && t.Before(to) && !e.exdates[t.UTC()] {
+			occs = append(occs, t)
+		}
+	}
+
+	if e.rrule == nil {
+		add(e.start)
+		return occs
+	}
+
+	interval, _ := strconv.Atoi(e.rrule["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(e.rrule["COUNT"])
+	var until time.Time
+	if u := e.rrule["UNTIL"]; u != "" {
+		until, _, _ = parseICSTime(u, map[string]string{})
+	}
+
+	// done reports whether the n'th occurrence, at t, is past the end
+	// of the rule or the window.
+	done := func(n int, t time.Time) bool {
+		return (count > 0 && n >= count) || (!until.IsZero() && t.After(until)) || !t.Before(to)
+	}
+
+	switch e.rrule["FREQ"] {
+	case "DAILY":
+		for n, t := 0, e.start; !done(n, t); n++ {
+			add(t)
+			t = e.start.AddDate(0, 0, interval*(n+1))
+		}
+
+	case "MONTHLY", "YEARLY":
+		months := interval
+		if e.rrule["FREQ"] == "YEARLY" {
+			months *= 12
+		}
+
+		// Months without the day of the start, like the 31st or
+		// February 29th, are skipped and not counted, as RFC 5545
+		// says, rather than overflowing into the next month.
+		y, m, d := e.start.Date()
+		hour, min, sec := e.start.Clock()
+		for i, n := 0, 0; ; i++ {
+			t := time.Date(y, m+time.Month(months*i), d, hour, min, sec, e.start.Nanosecond(), e.start.Location())
+			if done(n, t) {
+				break
+			}
+			if t.Day() != d {
+				continue
+			}
+			add(t)
+			n++
+		}
+
+	case "WEEKLY":
+		var byday []time.Weekday
+		for _, d := range strings.Split(e.rrule["BYDAY"], ",") {
+			if wd, ok := icsWeekdays[d]; ok {
+				byday = append(byday, wd)
+			}
+		}
+		if len(byday) == 0 {
+			byday = []time.Weekday{e.start.Weekday()}
+		}
+
+		// Weeks start on Monday
+		offset := func(wd time.Weekday) int { return (int(wd) + 6) % 7 }
+		sort.Slice(byday, func(i, j int) bool { return offset(byday[i]) < offset(byday[j]) })
+		week := e.start.AddDate(0, 0, -offset(e.start.Weekday()))
+
+		n := 0
+		for w := 0; ; w += interval {
+			for _, wd := range byday {
+				t := week.AddDate(0, 0, 7*w+offset(wd))
+				if t.Before(e.start) {
+					continue
+				}
+				if done(n, t) {
+					return occs
+				}
+				add(t)
+				n++
+			}
+		}
+
+	default:
+		// Unsupported frequency; just use the first occurrence
+		add(e.start)
+	}
+
+	return occs
+}
+
+func fetchCalendar(ctx context.Context, url string) ([]calendarEvent, error) {
+	if strings.HasPrefix(url, "webcal://") {
+		url = "https://" + strings.TrimPrefix(url, "webcal://")
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseICS(string(body))
+}
+
+// precondition turns on climate control for the default vehicle of h
+// lead before each event in the calendar at cfg.calendarURL whose
+// summary contains cfg.calendarTag, until ctx is done.
+func precondition(ctx context.Context, h *httpd.Server, cfg config) {
+	var (
+		events  []calendarEvent
+		fetched time.Time
+		started = map[string]time.Time{} // event UID and start -> start
+	)
+
+	tag := strings.ToLower(cfg.calendarTag)
+	lead := cfg.preconditionLead
+
+	for {
+		if time.Since(fetched) >= calendarRefresh {
+			evs, err := fetchCalendar(ctx, cfg.calendarURL)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(logOutput(), "Error reading calendar: %s\n", err)
+			} else {
+				events = evs
+			}
+			fetched = time.Now()
+		}
+
+		now := time.Now()
+		wait := calendarRefresh - time.Since(fetched)
+
+		for key, start := range started {
+			if start.Before(now) {
+				delete(started, key)
+			}
+		}
+
+		for _, e := range events {
+			if e.allDay || !strings.Contains(strings.ToLower(e.summary), tag) {
+				continue
+			}
+
+			for _, start := range e.occurrences(now, now.Add(calendarHorizon)) {
+				key := e.uid + "@" + start.UTC().Format(time.RFC3339)
+				if _, ok := started[key]; ok {
+					continue
+				}
+
+				if d := time.Until(start.Add(-lead)); d > 0 {
+					if d < wait {
+						wait = d
+					}
+					continue
+				}
+
+				started[key] = start
+				fmt.Fprintf(logOutput(), "Turning on climate control for %q at %s\n", e.summary, start.Local().Format("Mon 15:04"))
+
+				err := h.Command(ctx, "", "Climate control on request", func(ctx context.Context, s *carwings.Session) error {
+					key, err := s.ClimateOnRequestContext(ctx)
+					if err != nil {
+						return err
+					}
+					_, err = pollResult(ctx, key, cfg.timeout, s.CheckClimateOnRequestContext, nil)
+					return err
+				})
+				if err != nil {
+					fmt.Fprintf(logOutput(), "Error turning on climate control: %s\n", err)
+				}
+			}
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joeshaw/carwings"
+)
+
+// gridIntensity is the approximate average carbon intensity of
+// electricity generation, in grams of CO2 per kWh, in each region.
+// They are rough national or continental averages from recent years;
+// local grids vary a lot, so use -grid-intensity if you know yours.
+var gridIntensity = map[string]float64{
+	carwings.RegionUSA:       370,
+	carwings.RegionEurope:    250,
+	carwings.RegionCanada:    120,
+	carwings.RegionAustralia: 550,
+	carwings.RegionJapan:     450,
+}
+
+func runCarbon(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("carbon")
+	period := periodFlags(fs)
+	intensity := fs.Float64("grid-intensity", gridIntensity[cfg.region], "carbon intensity of your electricity, in grams of CO2 per kWh. Defaults to an average for the -region.")
+	efficiency := fs.Float64("charging-efficiency", 0.85, "fraction of the energy drawn from the grid that ends up in the battery")
+	fs.Parse(args)
+
+	if *intensity <= 0 {
+		return fmt.Errorf("-grid-intensity must be positive")
+	}
+	if *efficiency <= 0 || *efficiency > 1 {
+		return fmt.Errorf("-charging-efficiency must be between 0 and 1")
+	}
+
+	months, err := period()
+	if err != nil {
+		return err
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	var (
+		trips     int
+		meters    int
+		power     float64 // Wh
+		reduction int     // kg
+	)
+	stats, err := s.GetMonthlyStatisticsRangeContext(ctx, months[0], months[len(months)-1])
+	if err != nil {
+		return err
+	}
+	for _, ms := range stats {
+		for _, d := range ms.Dates {
+			for _, t := range d.Trips {
+				trips++
+				meters += t.Meters
+				power += t.PowerConsumedTotal
+			}
+		}
+		reduction += ms.Total.CO2Reduction
+	}
+
+	fmt.Printf("Carbon footprint from %s to %s\n",
+		months[0].Format("January 2006"), months[len(months)-1].Format("January 2006"))
+
+	if trips == 0 {
+		fmt.Printf("  (no trips in this period)\n")
+		fmt.Println()
+		return nil
+	}
+
+	grid := power / 1000 / *efficiency
+	emitted := grid * *intensity / 1000
+
+	fmt.Printf("  Driving:      %d trips, %s using %.1f kWh\n", trips, prettyUnits(cfg.units, meters), power/1000)
+	fmt.Printf("  Grid energy:  %.1f kWh at %.0f%% charging efficiency\n", grid, *efficiency*100)
+	fmt.Printf("  Emissions:    %.1f kg CO2 at %.0f g/kWh => %.0f g/%s\n",
+		emitted, *intensity, emitted*1000/metersToUnits(cfg.units, meters), cfg.units)
+	fmt.Printf("  CO2 reduction reported by Carwings: %d kg\n", reduction)
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// redacted replaces sensitive values in a debug dump.
+const redacted = "REDACTED"
+
+// sensitiveKeys are the request parameters and response fields, in
+// lower case, whose values are left out of debug dumps: credentials,
+// session IDs, vehicle and unit identifiers, personal details and
+// where the car is.
+var sensitiveKeys = map[string]bool{
+	"userid":           true,
+	"password":         true,
+	"custom_sessionid": true,
+	"vin":              true,
+	"dcmid":            true,
+	"simid":            true,
+	"naviid":           true,
+	"encryptednaviid":  true,
+	"msn":              true,
+	"nickname":         true,
+	"email":            true,
+	"tel":              true,
+	"lat":              true,
+	"lng":              true,
+	"latitude":         true,
+	"longitude":        true,
+	"profileimage":     true,
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	return sensitiveKeys[key] || strings.Contains(key, "sessionid") || strings.Contains(key, "password") ||
+		strings.Contains(key, "mail") || strings.Contains(key, "phone") || strings.Contains(key, "address")
+}
+
+// exchange is a request to the Carwings service and its response, as
+// recorded by a recorder.
+type exchange struct {
+	Endpoint string
+	Params   url.Values
+	Status   int             `json:",omitempty"`
+	Header   http.Header     `json:",omitempty"`
+	Response json.RawMessage `json:",omitempty"`
+	Body     string          `json:",omitempty"` // if the response isn't JSON
+	Error    string          `json:",omitempty"`
+	Elapsed  string
+}
+
+// recorder is an http.RoundTripper that keeps every request it sends
+// through next, and the responses, for debug-dump.
+type recorder struct {
+	next http.RoundTripper // http.DefaultTransport if nil
+
+	mu        sync.Mutex
+	exchanges []exchange
+}
+
+func (rec *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	ex := exchange{Endpoint: strings.TrimSuffix(path.Base(req.URL.Path), ".php")}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		ex.Params, _ = url.ParseQuery(string(body))
+	}
+
+	next := rec.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	if err == nil {
+		var body []byte
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		ex.Status, ex.Header = resp.StatusCode, resp.Header
+		if json.Valid(body) {
+			ex.Response = body
+		} else {
+			ex.Body = string(body)
+		}
+	}
+	if err != nil {
+		ex.Error = err.Error()
+	}
+	ex.Elapsed = time.Since(start).Round(time.Millisecond).String()
+
+	rec.mu.Lock()
+	rec.exchanges = append(rec.exchanges, ex)
+	rec.mu.Unlock()
+
+	return resp, err
+}
+
+// recorded returns the exchanges recorded so far.
+func (rec *recorder) recorded() []exchange {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]exchange(nil), rec.exchanges...)
+}
+
+// redactJSON replaces the values of sensitive fields anywhere in v.
+func redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if sensitive(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactJSON(e)
+		}
+	}
+	return v
+}
+
+// redact returns ex with the values of sensitive parameters and fields
+// replaced, and any of the literal secrets that still appear.
+func (ex exchange) redact(secrets []string) exchange {
+	params := url.Values{}
+	for k, vs := range ex.Params {
+		if sensitive(k) {
+			vs = []string{redacted}
+		}
+		params[k] = vs
+	}
+	ex.Params = params
+
+	header := http.Header{}
+	for k, vs := range ex.Header {
+		if k == "Set-Cookie" {
+			vs = []string{redacted}
+		}
+		header[k] = vs
+	}
+	ex.Header = header
+
+	if ex.Response != nil {
+		var v interface{}
+		if err := json.Unmarshal(ex.Response, &v); err == nil {
+			ex.Response, _ = json.Marshal(redactJSON(v))
+		}
+	}
+
+	replace := func(s string) string {
+		for _, secret := range secrets {
+			// Too short to be told apart from the rest
+			if len(secret) >= 4 {
+				s = strings.ReplaceAll(s, secret, redacted)
+			}
+		}
+		return s
+	}
+	ex.Response = json.RawMessage(replace(string(ex.Response)))
+	if len(ex.Response) == 0 {
+		ex.Response = nil
+	}
+	ex.Body, ex.Error = replace(ex.Body), replace(ex.Error)
+	return ex
+}
+
+// debugEnv describes where a debug dump was taken.
+type debugEnv struct {
+	Time       time.Time
+	Version    string
+	GoVersion  string
+	OS         string
+	Arch       string
+	Region     string
+	URL        string
+	ParseMode  string
+	Lang       string
+	TimeZone   string
+	Mock       bool
+	LoginError string `json:",omitempty"`
+}
+
+// serviceURL returns the URL of the Carwings service the configuration
+// talks to, the same way the Session chooses it.
+func (cfg config) serviceURL() string {
+	if cfg.url != "" {
+		return cfg.url
+	}
+	if u, ok := carwings.RegionBaseURLs[cfg.region]; ok {
+		return u
+	}
+	return carwings.BaseURL
+}
+
+func newDebugEnv(cfg config) debugEnv {
+	env := debugEnv{
+		Time:      time.Now(),
+		Version:   "unknown",
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Region:    cfg.region,
+		URL:       cfg.serviceURL(),
+		ParseMode: "default",
+		Lang:      lang,
+		TimeZone:  time.Now().Format("MST -07:00"),
+		Mock:      cfg.mockDir != "",
+	}
+	switch cfg.parseMode() {
+	case carwings.ParseStrict:
+		env.ParseMode = "strict"
+	case carwings.ParseLenient:
+		env.ParseMode = "lenient"
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		env.Version = bi.Main.Version
+	}
+	return env
+}
+
+// runDebugDump exercises the main endpoints and writes what was sent
+// and received, redacted, to a tarball for bug reports.  It also runs
+// when logging in failed, with s nil, since that's often the problem
+// being reported.
+func runDebugDump(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("debug-dump")
+	out := fs.String("o", "", "file to write the tarball to (default carwings-debug-<time>.tar.gz)")
+	update := fs.Bool("update", false, "also ask the vehicle for its status and location, which wakes it up")
+	fs.Parse(args)
+
+	env := newDebugEnv(cfg)
+	if *out == "" {
+		*out = "carwings-debug-" + env.Time.UTC().Format("20060102T150405Z") + ".tar.gz"
+	}
+
+	var steps bytes.Buffer
+	step := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(&steps, "%-24s %v\n", name, err)
+			fmt.Fprintf(os.Stderr, tr("WARNING: %s failed: %v\n"), name, err)
+		} else {
+			fmt.Fprintf(&steps, "%-24s ok\n", name)
+		}
+	}
+
+	if cfg.loginErr != nil {
+		env.LoginError = cfg.loginErr.Error()
+		step("login", cfg.loginErr)
+	} else {
+		progress(tr("Exercising Carwings endpoints..."))
+
+		// A saved session skips logging in, which is worth capturing
+		loggedIn := false
+		for _, ex := range cfg.recorder.recorded() {
+			loggedIn = loggedIn || ex.Endpoint == "UserLoginRequest"
+		}
+		if !loggedIn {
+			step("login", s.LoginContext(ctx))
+		}
+
+		_, err := s.BatteryStatusContext(ctx)
+		step("battery", err)
+		_, err = s.ClimateControlStatusContext(ctx)
+		step("climate", err)
+		_, err = s.GetDailyStatisticsContext(ctx, time.Now().Local())
+		step("daily statistics", err)
+		_, err = s.GetMonthlyStatisticsContext(ctx, monthOf(time.Now()))
+		step("monthly statistics", err)
+
+		if *update {
+			key, err := s.UpdateStatusContext(ctx)
+			if err == nil {
+				progressStart(tr("Waiting for update to complete... "))
+				err = waitForResult(ctx, key, cfg.timeout, s.CheckUpdateContext)
+			}
+			step("update", err)
+
+			// Bound how long we wait for the vehicle to respond
+			vctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+			vs, err := s.VehicleStatus(vctx)
+			cancel()
+			if err != nil {
+				step("vehicle status", err)
+			} else {
+				step("location", vs.LocationErr)
+				step("cabin temperature", vs.CabinTempErr)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	// The password is only ever sent encrypted, as a redacted parameter
+	secrets := []string{cfg.username, cfg.vin}
+	if s != nil {
+		secrets = append(secrets, s.VIN)
+	}
+
+	type file struct {
+		name string
+		data []byte
+	}
+	envJSON, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []file{
+		{"env.json", append(envJSON, '\n')},
+		{"steps.txt", steps.Bytes()},
+	}
+	for i, ex := range cfg.recorder.recorded() {
+		data, err := json.MarshalIndent(ex.redact(secrets), "", "  ")
+		if err != nil {
+			return err
+		}
+		files = append(files, file{fmt.Sprintf("%02d-%s.json", i+1, ex.Endpoint), append(data, '\n')})
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	dir := strings.TrimSuffix(path.Base(*out), ".tar.gz") + "/"
+	for _, file := range files {
+		hdr := &tar.Header{Name: dir + file.name, Mode: 0644, Size: int64(len(file.data)), ModTime: env.Time}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("Wrote %d requests to %s.  Check it for anything private before attaching it to an issue.\n"), len(files)-2, *out)
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// departure is a regular departure time, such as "mon-fri 07:30".
+type departure struct {
+	days [7]bool       // indexed by time.Weekday
+	at   time.Duration // offset from midnight
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for i, d := range weekdays {
+		if strings.HasPrefix(s, d) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+func (d departure) String() string {
+	var days []string
+	for i, ok := range d.days {
+		if ok {
+			days = append(days, weekdays[i])
+		}
+	}
+	return fmt.Sprintf("%s %02d:%02d", strings.Join(days, ","), int(d.at.Hours()), int(d.at.Minutes())%60)
+}
+
+// next returns the first time after t that this departure's reminder,
+// lead before the departure itself, is due, along with the departure
+// time.
+func (d departure) next(t time.Time, lead time.Duration) (reminder, departs time.Time) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	// Look far enough ahead for the lead time to reach back to now
+	for i := 0; i <= 7+int(lead/(24*time.Hour)); i++ {
+		day := midnight.AddDate(0, 0, i)
+		if !d.days[day.Weekday()] {
+			continue
+		}
+		departs = day.Add(d.at)
+		if reminder = departs.Add(-lead); reminder.After(t) {
+			return reminder, departs
+		}
+	}
+
+	return time.Time{}, time.Time{}
+}
+
+// departures is a flag.Value that collects departure times from
+// repeated -departure flags or config file lines.
+type departures []departure
+
+var _ flag.Value = (*departures)(nil)
+
+func (ds *departures) String() string {
+	if ds == nil {
+		return ""
+	}
+	s := make([]string, len(*ds))
+	for i, d := range *ds {
+		s[i] = d.String()
+	}
+	return strings.Join(s, "; ")
+}
+
+func (ds *departures) Set(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return fmt.Errorf("departure %q must be in the format \"<days> <HH:MM>\"", value)
+	}
+
+	var d departure
+
+	for _, span := range strings.Split(fields[0], ",") {
+		if span == "daily" {
+			for i := range d.days {
+				d.days[i] = true
+			}
+			continue
+		}
+
+		days := strings.SplitN(span, "-", 2)
+		first, err := parseWeekday(days[0])
+		if err != nil {
+			return err
+		}
+		last := first
+		if len(days) == 2 {
+			if last, err = parseWeekday(days[1]); err != nil {
+				return err
+			}
+		}
+
+		// Ranges may wrap around the end of the week, like "sat-sun"
+		for day := first; ; day = (day + 1) % 7 {
+			d.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	c, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return fmt.Errorf("invalid time %q -- must be HH:MM", fields[1])
+	}
+	d.at = time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute
+
+	*ds = append(*ds, d)
+	return nil
+}
+
+// runDepartures fires the departure_reminder event for each vehicle
+// that isn't ready, lead before each departure, until ctx is done.  A
+// vehicle isn't ready if, as of its latest battery status, it isn't
+// plugged in or its state of charge is below the charge target.
+func (t *triggers) runDepartures(ctx context.Context, ds departures, lead time.Duration) {
+	if len(ds) == 0 {
+		return
+	}
+
+	for {
+		now := time.Now()
+
+		var reminder, departs time.Time
+		for _, d := range ds {
+			r, dt := d.next(now, lead)
+			if !r.IsZero() && (reminder.IsZero() || r.Before(reminder)) {
+				reminder, departs = r, dt
+			}
+		}
+		if reminder.IsZero() {
+			return
+		}
+
+		if err := sleep(ctx, time.Until(reminder)); err != nil {
+			return
+		}
+
+		t.mu.Lock()
+		last := make(map[string]carwings.BatteryStatus, len(t.last))
+		for vin, bs := range t.last {
+			last[vin] = bs
+		}
+		t.mu.Unlock()
+
+		for vin, bs := range last {
+			if isPluggedIn(bs) && bs.StateOfCharge >= t.target {
+				continue
+			}
+			t.fire(notification{Event: eventDeparture, VIN: vin, Battery: bs, Threshold: t.target, Departure: departs})
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultEmailEvents  = eventChargeComplete + "," + eventChargeStopped + "," + eventLowSOC + "," + eventSOCReached
+	defaultEmailSubject = "{{.Title}}: {{.Battery.StateOfCharge}}%"
+	defaultEmailBody    = `{{.Title}}: {{.Battery.StateOfCharge}}%, {{.Range}}
+
+{{.VIN}}, {{.Time.Format "2006-01-02 15:04"}}
+`
+)
+
+// emailNotifier sends notifications by email through an SMTP server.
+// Servers on port 465 are assumed to use implicit TLS; otherwise
+// STARTTLS is used if the server offers it.
+type emailNotifier struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+	events   map[string]bool
+	subject  *template.Template
+	body     *template.Template
+}
+
+func newEmailNotifier(cfg config) (*emailNotifier, error) {
+	host, _, err := net.SplitHostPort(cfg.smtpHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -smtp-host %q: %v", cfg.smtpHost, err)
+	}
+
+	e := &emailNotifier{
+		addr:     cfg.smtpHost,
+		host:     host,
+		username: cfg.smtpUsername,
+		password: cfg.smtpPassword,
+		from:     cfg.emailFrom,
+		events:   map[string]bool{},
+	}
+
+	for _, to := range strings.Split(cfg.emailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			e.to = append(e.to, to)
+		}
+	}
+	if len(e.to) == 0 {
+		return nil, fmt.Errorf("-email-to is required with -smtp-host")
+	}
+	if e.from == "" {
+		e.from = e.to[0]
+	}
+
+	for _, ev := range strings.Split(cfg.emailEvents, ",") {
+		ev = strings.TrimSpace(ev)
+		if _, ok := eventTitles[ev]; !ok {
+			return nil, fmt.Errorf("unknown event %q in -email-events", ev)
+		}
+		e.events[ev] = true
+	}
+
+	e.subject, err = template.New("subject").Parse(cfg.emailSubject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -email-subject: %v", err)
+	}
+
+	body := defaultEmailBody
+	if cfg.emailTemplate != "" {
+		path := cfg.emailTemplate
+		if strings.HasPrefix(path, "~") {
+			path = os.Getenv("HOME") + path[1:]
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	e.body, err = template.New("body").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -email-template: %v", err)
+	}
+
+	return e, nil
+}
+
+func (e *emailNotifier) notify(ctx context.Context, n notification) error {
+	if !e.events[n.Event] {
+		return nil
+	}
+
+	var subject, body bytes.Buffer
+	if err := e.subject.Execute(&subject, n); err != nil {
+		return err
+	}
+	if err := e.body.Execute(&body, n); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject.String()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	return e.send(ctx, msg.Bytes())
+}
+
+func (e *emailNotifier) send(ctx context.Context, msg []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: e.host}
+	if strings.HasSuffix(e.addr, ":465") {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
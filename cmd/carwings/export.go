@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// Trip export formats
+const (
+	formatInflux  = "influx"
+	formatParquet = "parquet"
+)
+
+// runTripsExport writes every trip in the vehicle's history to a file
+// for analysis with other tools.
+func runTripsExport(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("trips export")
+	format := fs.String("format", formatInflux, "file format: influx (line protocol) or parquet")
+	file := fs.String("file", "", "file to write. Defaults to carwings-trips.lp or carwings-trips.parquet.")
+	since := fs.String("since", "", "earliest month (YYYY-MM) to export. Defaults to the beginning of the vehicle's history.")
+	fs.Parse(args)
+
+	var write func(io.Writer, string, []carwings.TripDetail) error
+	switch *format {
+	case formatInflux:
+		write = writeInfluxTrips
+		if *file == "" {
+			*file = "carwings-trips.lp"
+		}
+	case formatParquet:
+		write = writeParquetTrips
+		if *file == "" {
+			*file = "carwings-trips.parquet"
+		}
+	default:
+		return fmt.Errorf("unsupported format (%q) -- must be influx or parquet", *format)
+	}
+
+	var first time.Time
+	if *since != "" {
+		var err error
+		if first, err = parseMonth(*since); err != nil {
+			return err
+		}
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	_, stats, err := drivingHistory(ctx, s, first, maxEmptyMonths, hasDates)
+	if err != nil {
+		return err
+	}
+
+	var trips []carwings.TripDetail
+	for _, ms := range stats {
+		for _, d := range ms.Dates {
+			trips = append(trips, d.Trips...)
+		}
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if err := write(f, s.VIN, trips); err != nil {
+		f.Close()
+		os.Remove(*file)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("Wrote %s\n"), *file)
+	return nil
+}
+
+// influxEscaper escapes tag values in InfluxDB line protocol.
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// writeInfluxTrips writes trips as InfluxDB line protocol, one "trip"
+// point per trip tagged with the VIN, timestamped in nanoseconds.
+func writeInfluxTrips(w io.Writer, vin string, trips []carwings.TripDetail) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range trips {
+		// Fields can't be NaN, so trips without distance have none
+		var efficiency string
+		if t.Meters > 0 {
+			efficiency = fmt.Sprintf(",efficiency_kwh_per_km=%g", tripEfficiency(t))
+		}
+		fmt.Fprintf(bw, "trip,vin=%s trip_id=%di,distance_m=%di,energy_wh=%g,motor_wh=%g,regenerated_wh=%g%s,co2_reduction_kg=%di %d\n",
+			influxEscaper.Replace(vin), t.TripId, t.Meters, t.PowerConsumedTotal, t.PowerConsumedMotor,
+			t.PowerRegenerated, efficiency, t.CO2Reduction, t.Started.UnixNano())
+	}
+	return bw.Flush()
+}
+
+// writeParquetTrips writes trips as a Parquet file with a column for
+// each field.  The efficiency of trips without distance is 0.
+func writeParquetTrips(w io.Writer, vin string, trips []carwings.TripDetail) error {
+	columns := []parquetColumn{
+		{name: "started", typ: parquetInt64, converted: parquetTimestampMillis},
+		{name: "trip_id", typ: parquetInt64},
+		{name: "distance_m", typ: parquetInt64},
+		{name: "energy_wh", typ: parquetDouble},
+		{name: "motor_wh", typ: parquetDouble},
+		{name: "regenerated_wh", typ: parquetDouble},
+		{name: "efficiency_kwh_per_km", typ: parquetDouble},
+		{name: "co2_reduction_kg", typ: parquetInt64},
+	}
+	for _, t := range trips {
+		columns[0].ints = append(columns[0].ints, t.Started.UnixNano()/int64(time.Millisecond))
+		columns[1].ints = append(columns[1].ints, int64(t.TripId))
+		columns[2].ints = append(columns[2].ints, int64(t.Meters))
+		columns[3].floats = append(columns[3].floats, t.PowerConsumedTotal)
+		columns[4].floats = append(columns[4].floats, t.PowerConsumedMotor)
+		columns[5].floats = append(columns[5].floats, t.PowerRegenerated)
+		var efficiency float64
+		if t.Meters > 0 {
+			efficiency = tripEfficiency(t)
+		}
+		columns[6].floats = append(columns[6].floats, efficiency)
+		columns[7].ints = append(columns[7].ints, int64(t.CO2Reduction))
+	}
+	return writeParquet(w, len(trips), columns)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/joeshaw/carwings"
+	"github.com/joeshaw/carwings/httpd"
+)
+
+// runExporter periodically updates the vehicles on the account and
+// serves their data for Prometheus.  Unlike the server, it exposes
+// nothing that can control the vehicles.
+func runExporter(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("exporter")
+	listen := fs.String("listen", ":9777", "address to serve metrics on")
+	fs.Parse(args)
+
+	if cfg.pprofAddr != "" {
+		go servePprof(ctx, cfg.pprofAddr)
+	}
+
+	st := &serverState{
+		vin:     s.VIN,
+		ctx:     ctx,
+		cfg:     cfg,
+		servers: map[string]*httpd.Server{},
+	}
+	if err := st.setNotifiers(cfg); err != nil {
+		return err
+	}
+
+	h := httpd.New(s, st.options(""))
+	st.servers[""] = h
+
+	sessions, err := connectAccount(ctx, cfg, profile{username: cfg.username, password: cfg.password}, s)
+	if err != nil {
+		return err
+	}
+	for _, vs := range sessions {
+		h.Add(vs)
+	}
+
+	go h.Run(ctx)
+	go st.handleSignals(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", h.ServeMetrics)
+	mux.HandleFunc("/healthz", h.ServeHealth)
+	mux.HandleFunc("/readyz", h.ServeReady)
+
+	srv := http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(logOutput(), "Serving metrics on %s...\n", srv.Addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
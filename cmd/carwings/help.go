@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// commandHelp describes a command for its usage, shown by
+// "carwings help <command>" or "carwings <command> -h".
+type commandHelp struct {
+	name     string
+	args     string // positional arguments, after any flags
+	summary  string // for the list of commands
+	details  string
+	examples []string
+	flags    []string // global flags that matter for it; a trailing * matches a prefix
+}
+
+var commandHelps = []commandHelp{
+	{
+		name:    "update",
+		summary: "Load latest data from vehicle",
+		details: "Asks the vehicle to report its status to Carwings, and waits up to\n-timeout for it to respond.  Each update wakes up the car's telematics\nunit, which drains its 12V battery a little.",
+		flags:   []string{"timeout", "min-update-interval"},
+	},
+	{
+		name:     "battery",
+		summary:  "Get most recently loaded battery status",
+		details:  "Shows the battery status the vehicle last reported.  Run update first\nfor a fresh one.",
+		examples: []string{"carwings update && carwings battery", "carwings -json battery"},
+		flags:    []string{"units", "lenient", "strict", "time-format", "json", "plain"},
+	},
+	{
+		name:    "charge",
+		summary: "Begin charging plugged-in vehicle",
+		flags:   []string{"require-plugged-in"},
+	},
+	{
+		name:     "charge-stop",
+		summary:  "Stop charging vehicle",
+		details:  "Only some versions of the Carwings service can stop charging.  The\nothers report an error and charging carries on.",
+		examples: []string{"carwings charge-stop"},
+	},
+	{
+		name:    "climate",
+		summary: "Get most recently loaded climate control status",
+		flags:   []string{"lenient", "strict", "time-format", "json", "plain"},
+	},
+	{
+		name:    "climate-off",
+		summary: "Turn off climate control",
+	},
+	{
+		name:     "climate-on",
+		summary:  "Turn on climate control",
+		details:  "With -min-climate-soc, climate control isn't turned on while the\nbattery is below it, unless -force is given.",
+		examples: []string{"carwings -min-climate-soc 30 climate-on"},
+		flags:    []string{"min-climate-soc"},
+	},
+	{
+		name:    "cabin-temp",
+		summary: "Get cabin temperature",
+	},
+	{
+		name:     "counters",
+		summary:  "Get lifetime quick and normal charge counts",
+		details:  "Shows how many times the battery has been quick charged and charged at\nLevel 1 or 2, as of the last update, for judging how a used car's\nbattery was treated.  Not every vehicle reports them.",
+		examples: []string{"carwings update && carwings counters"},
+		flags:    []string{"lenient", "strict", "time-format"},
+	},
+	{
+		name:     "ping",
+		summary:  "Check that Carwings is reachable and the session is valid",
+		details:  "Makes a lightweight request that doesn't reach the vehicle, logging in\nagain if the session has expired.  Exits with a non-zero status if it\nfails, for monitoring scripts and checks before longer operations.",
+		examples: []string{"carwings ping && carwings archive"},
+	},
+	{
+		name:    "status",
+		summary: "Get battery, climate and cabin temperature at once",
+		flags:   []string{"units", "lenient", "strict", "time-format", "json", "plain"},
+	},
+	{
+		name:     "range",
+		summary:  "Estimate range from recent driving efficiency",
+		details:  "Exits with status 2 if the -to trip is out of range, or 3 if it's\nonly in range without AC or by some estimates.",
+		examples: []string{"carwings range -to 45"},
+		flags:    []string{"units", "effunits", "cache-*"},
+	},
+	{
+		name:     "predict",
+		summary:  "Predict when charging will reach a target",
+		examples: []string{"carwings predict -target 80"},
+		flags:    []string{"charge-target", "charger-level", "time-format"},
+	},
+	{
+		name:    "daily",
+		summary: "Daily driving statistics",
+		flags:   []string{"effunits"},
+	},
+	{
+		name:     "monthly",
+		args:     "[<month>]",
+		summary:  "Monthly driving statistics",
+		details:  "Shows the trips of a month, the current one by default.  The month\ncan be YYYY-MM, this-month, last-month or a month name like may,\nwhich means the most recent May.",
+		examples: []string{"carwings monthly last-month", "carwings monthly 2024-05", "carwings monthly -sort distance -desc may"},
+		flags:    []string{"units", "effunits", "electricity-rate", "tariff", "cache-*"},
+	},
+	{
+		name:     "trips",
+		summary:  "Rank or summarize trips over a period",
+		examples: []string{"carwings trips -top 10 -by efficiency -from 2024-01 -to 2024-03", "carwings trips -group weekday -from last-month", "carwings -history-dir ~/.carwings trips -tag commute -top 0 -output xlsx"},
+		flags:    []string{"units", "effunits", "cache-*", "history-dir"},
+	},
+	{
+		name:     "trips tag",
+		summary:  "Tag or note a trip, for trips -tag",
+		details:  "Identifies the trip by when it started, as trips and monthly list it.\nTags and notes are kept in -history-dir.",
+		examples: []string{"carwings -history-dir ~/.carwings trips tag 2024-05-01 08:15 commute", "carwings -history-dir ~/.carwings trips tag -note \"Client visit\" 2024-05-02 13:40 business"},
+		flags:    []string{"history-dir", "cache-*"},
+	},
+	{
+		name:     "trips export",
+		summary:  "Export all trips for InfluxDB or as Parquet",
+		examples: []string{"carwings trips export -since 2024-01 > trips.lp"},
+		flags:    []string{"cache-*"},
+	},
+	{
+		name:    "odometer",
+		summary: "Estimate mileage from trip history",
+		flags:   []string{"units", "cache-*"},
+	},
+	{
+		name:     "cost",
+		summary:  "Driving cost split by time-of-use tariff",
+		examples: []string{"carwings -tariff \"offpeak 0.08 00:00-07:00\" cost -from this-month"},
+		flags:    []string{"tariff", "electricity-rate", "cache-*"},
+	},
+	{
+		name:    "carbon",
+		summary: "Carbon footprint of driving",
+		flags:   []string{"units", "cache-*"},
+	},
+	{
+		name:     "archive",
+		summary:  "Save everything available as JSON files",
+		examples: []string{"carwings archive -dir ./leaf-data"},
+		flags:    []string{"archive-dir", "cache-*"},
+	},
+	{
+		name:    "backfill",
+		summary: "Cache all driving history in -cache-dir",
+		flags:   []string{"cache-*"},
+	},
+	{
+		name:     "debug-dump",
+		summary:  "Save redacted requests and responses for a bug report",
+		details:  "Exercises the main endpoints, logging in even with a saved session,\nand writes every request and response to a tarball along with the\nversion, platform, region and service URL.  Credentials, session IDs,\nVINs and locations are redacted.  It also runs when logging in fails.",
+		examples: []string{"carwings debug-dump -o leaf-debug.tar.gz", "carwings -region NE debug-dump -update"},
+		flags:    []string{"region", "url", "timeout", "lenient", "strict"},
+	},
+	{
+		name:     "watch",
+		summary:  "Update and print battery status periodically",
+		examples: []string{"carwings watch -interval 15m"},
+		flags:    []string{"homebridge-*", "trigger-url", "ifttt-key", "low-soc", "soc-*", "quiet-hours", "history-dir", "time-format", "relogin-interval"},
+	},
+	{
+		name:     "server",
+		summary:  "Listen for requests on port 8040",
+		examples: []string{"carwings -server-addr :8040 -server-update-interval 30m server"},
+		flags:    []string{"server-*", "quiet-hours", "base-path", "pprof", "profile", "alexa-token", "google-home-token", "slack-signing-secret", "departure*", "plan-charging", "calendar-*", "precondition-lead", "smtp-*", "email-*", "relogin-interval", "admin-token"},
+	},
+	{
+		name:    "exporter",
+		summary: "Serve Prometheus metrics only, on port 9777",
+		flags:   []string{"server-update-interval", "server-active-update-interval", "server-idle-update-interval", "quiet-hours", "relogin-interval"},
+	},
+}
+
+// findCommandHelp returns the help for the named command, or nil if
+// there isn't any.
+func findCommandHelp(name string) *commandHelp {
+	for i := range commandHelps {
+		if commandHelps[i].name == name {
+			return &commandHelps[i]
+		}
+	}
+	return nil
+}
+
+// commandFlags returns the flag set for the named command, whose usage
+// is its help.
+func commandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() { printCommandHelp(name, fs) }
+	return fs
+}
+
+// printCommandHelp prints the usage of the named command, with its
+// flags fs and the global flags relevant to it.
+func printCommandHelp(name string, fs *flag.FlagSet) {
+	h := findCommandHelp(name)
+	if h == nil {
+		h = &commandHelp{name: name}
+	}
+
+	w := fs.Output()
+	fmt.Fprintf(w, "USAGE\n")
+	fmt.Fprintf(w, "  %s [flags] %s", os.Args[0], h.name)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(w, " [%s flags]", h.name)
+	}
+	if h.args != "" {
+		fmt.Fprintf(w, " %s", h.args)
+	}
+	fmt.Fprintf(w, "\n\n")
+
+	if h.summary != "" {
+		fmt.Fprintf(w, "%s.\n\n", h.summary)
+	}
+	if h.details != "" {
+		fmt.Fprintf(w, "%s\n\n", h.details)
+	}
+
+	if len(h.examples) > 0 {
+		fmt.Fprintf(w, "EXAMPLES\n")
+		for _, ex := range h.examples {
+			fmt.Fprintf(w, "  %s\n", ex)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	if hasFlags {
+		fmt.Fprintf(w, "FLAGS\n")
+		fs.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(w, "  -%s %s\n", f.Name, f.Usage)
+		})
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(h.flags) > 0 {
+		fmt.Fprintf(w, "GLOBAL FLAGS\n")
+		newFlagSet(new(config), flag.ContinueOnError).VisitAll(func(f *flag.Flag) {
+			for _, pat := range h.flags {
+				if f.Name == pat || (strings.HasSuffix(pat, "*") && strings.HasPrefix(f.Name, strings.TrimSuffix(pat, "*"))) {
+					fmt.Fprintf(w, "  -%s %s\n", f.Name, f.Usage)
+					break
+				}
+			}
+		})
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(w, "See %s -h for all of the global flags.\n", os.Args[0])
+	}
+}
+
+// helpRequested reports whether the arguments of a command ask for its
+// help.
+func helpRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "-h", "-help", "--help":
+			return true
+		case "--":
+			return false
+		}
+	}
+	return false
+}
+
+// runHelp prints the help for the command in args, without logging
+// in: "help <command>" or "<command> ... -h".  Plugins are run with -h
+// to print their own.
+func runHelp(global *flag.FlagSet, cfg config, args []string) {
+	if args[0] == "help" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		global.Usage()
+		return
+	}
+
+	cmd := strings.ToLower(args[0])
+	helpArgs := []string{"-h"}
+	if cmd == "trips" && len(args) > 1 && (args[1] == "export" || args[1] == "tag") {
+		helpArgs = []string{args[1], "-h"}
+	}
+
+	run := command(cmd, &cfg)
+	if run == nil {
+		path := findPlugin(cmd)
+		if path == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: unknown command %q\n", cmd)
+			os.Exit(1)
+		}
+		c := exec.Command(path, "-h")
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		c.Run()
+		return
+	}
+
+	// Every command parses its flags before using the session, and
+	// exits after printing its help.
+	run(context.Background(), nil, cfg, helpArgs)
+}
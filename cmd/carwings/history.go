@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// history is a local store of data retrieved from the vehicle, kept so
+// that trends can be computed across invocations.  It lives in the
+// directory given with -history-dir.  A nil history discards
+// everything written to it.
+type history struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func openHistory(dir string) (*history, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(dir, "~") {
+		dir = os.Getenv("HOME") + dir[1:]
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &history{dir: dir}, nil
+}
+
+// addBattery appends a battery status sample to the history.
+func (h *history) addBattery(bs carwings.BatteryStatus) error {
+	if h == nil || bs.Timestamp.IsZero() {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(h.dir, "battery.jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(f).Encode(bs); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// batteryHistory returns the battery status samples retrieved since
+// the given time, oldest first.  Samples retrieved more than once are
+// only returned once.
+func (h *history) batteryHistory(since time.Time) ([]carwings.BatteryStatus, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(h.dir, "battery.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := map[time.Time]bool{}
+	var samples []carwings.BatteryStatus
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var bs carwings.BatteryStatus
+		if err := json.Unmarshal(s.Bytes(), &bs); err != nil {
+			// Skip lines truncated by a crash
+			continue
+		}
+
+		ts := bs.Timestamp.UTC()
+		if bs.Timestamp.Before(since) || seen[ts] {
+			continue
+		}
+		seen[ts] = true
+		samples = append(samples, bs)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+	return samples, nil
+}
+
+// socSamples returns the state of charge of each battery status sample.
+func socSamples(statuses []carwings.BatteryStatus) []carwings.SOCSample {
+	samples := make([]carwings.SOCSample, len(statuses))
+	for i, bs := range statuses {
+		samples[i] = carwings.SOCSample{Time: bs.Timestamp, StateOfCharge: bs.StateOfCharge}
+	}
+	return samples
+}
+
+// tripNote is what has been recorded about a trip with trips tag.
+type tripNote struct {
+	Tags []string `json:",omitempty"`
+	Note string   `json:",omitempty"`
+}
+
+// hasTag reports whether the trip is tagged with tag.
+func (n tripNote) hasTag(tag string) bool {
+	for _, t := range n.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// tripKeyLayout is how trips are identified in the history: by the
+// minute they started, in the vehicle's time zone, as they are listed.
+// Carwings numbers trips within each day, so its trip IDs aren't
+// unique.
+const tripKeyLayout = "2006-01-02 15:04"
+
+func tripKey(t carwings.TripDetail) string {
+	return t.Started.Format(tripKeyLayout)
+}
+
+// tripNotes returns the notes recorded about trips, by trip key.
+func (h *history) tripNotes() (map[string]tripNote, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.readTripNotes()
+}
+
+func (h *history) readTripNotes() (map[string]tripNote, error) {
+	data, err := os.ReadFile(filepath.Join(h.dir, "trip-notes.json"))
+	if os.IsNotExist(err) {
+		return map[string]tripNote{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	notes := map[string]tripNote{}
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// updateTripNote records what update makes of the note about the trip
+// with the given key, and returns it.  Notes left empty are removed.
+func (h *history) updateTripNote(key string, update func(*tripNote)) (tripNote, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	notes, err := h.readTripNotes()
+	if err != nil {
+		return tripNote{}, err
+	}
+
+	n := notes[key]
+	update(&n)
+	if len(n.Tags) == 0 && n.Note == "" {
+		delete(notes, key)
+	} else {
+		notes[key] = n
+	}
+
+	data, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return n, err
+	}
+
+	// Replace the file whole, so a crash can't lose every note
+	path := filepath.Join(h.dir, "trip-notes.json")
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0600); err != nil {
+		return n, err
+	}
+	return n, os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// homebridge sends vehicle state to the homebridge-http-webhooks
+// plugin whenever it changes.
+//
+// The battery level is sent as the value of a sensor accessory, and
+// charging and climate control as the state of switch accessories.
+// The accessories are named "<prefix>-battery", "<prefix>-charging"
+// and "<prefix>-climate".  Vehicles other than the one given with
+// -vin add their VIN, as in "<prefix>-<vin>-battery".
+type homebridge struct {
+	url    string
+	prefix string
+	vin    string                // primary vehicle
+	queue  chan homebridgeUpdate // for deliver
+	last   map[string]string     // accessory ID -> last value sent, by deliver
+}
+
+// homebridgeUpdate is a value to send to an accessory.
+type homebridgeUpdate struct {
+	id, param, value string
+}
+
+// homebridgeQueueSize is how many updates can wait for a slow
+// Homebridge before more are dropped.
+const homebridgeQueueSize = 32
+
+// newHomebridge returns a homebridge that sends updates until ctx is
+// done, or nil if no Homebridge is configured.
+func newHomebridge(ctx context.Context, cfg config, vin string) *homebridge {
+	if cfg.homebridgeURL == "" {
+		return nil
+	}
+
+	hb := &homebridge{
+		url:    cfg.homebridgeURL,
+		prefix: cfg.homebridgePrefix,
+		vin:    vin,
+		queue:  make(chan homebridgeUpdate, homebridgeQueueSize),
+		last:   map[string]string{},
+	}
+	go hb.deliver(ctx)
+	return hb
+}
+
+func (hb *homebridge) batteryStatus(vin string, bs carwings.BatteryStatus) {
+	hb.send(vin, "battery", "value", strconv.Itoa(bs.StateOfCharge))
+	hb.send(vin, "charging", "state", strconv.FormatBool(isCharging(bs)))
+}
+
+func (hb *homebridge) climateStatus(vin string, cs carwings.ClimateStatus) {
+	hb.send(vin, "climate", "state", strconv.FormatBool(cs.Running))
+}
+
+// send queues an update of the accessory for the given vehicle,
+// without waiting for Homebridge, so a slow or unreachable Homebridge
+// doesn't hold up the updates that call it.  If too many are already
+// queued, it is dropped.
+func (hb *homebridge) send(vin, name, param, value string) {
+	id := hb.prefix + "-" + name
+	if vin != hb.vin {
+		id = hb.prefix + "-" + vin + "-" + name
+	}
+
+	select {
+	case hb.queue <- homebridgeUpdate{id, param, value}:
+	default:
+		fmt.Fprintf(logOutput(), "Error updating Homebridge accessory %s: too many updates pending\n", id)
+	}
+}
+
+// deliver sends each queued update to Homebridge, in order, unless the
+// accessory has already been sent that value.  Errors are logged rather
+// than returned, so a Homebridge outage doesn't interrupt anything else.
+// It returns when ctx is done.
+func (hb *homebridge) deliver(ctx context.Context) {
+	for {
+		var u homebridgeUpdate
+		select {
+		case <-ctx.Done():
+			return
+		case u = <-hb.queue:
+		}
+
+		if last, ok := hb.last[u.id]; ok && last == u.value {
+			continue
+		}
+		if err := hb.update(u); err != nil {
+			fmt.Fprintf(logOutput(), "Error updating Homebridge accessory %s: %s\n", u.id, err)
+			continue
+		}
+		hb.last[u.id] = u.value
+	}
+}
+
+// update sets the value of an accessory.
+func (hb *homebridge) update(upd homebridgeUpdate) error {
+	u, err := url.Parse(hb.url)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("accessoryId", upd.id)
+	q.Set(upd.param, upd.value)
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
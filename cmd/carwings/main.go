@@ -2,16 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joeshaw/carwings"
+	"github.com/joeshaw/carwings/httpd"
 	"github.com/peterbourgon/ff"
 )
 
@@ -19,8 +24,69 @@ type config struct {
 	units                string
 	effunits             string
 	timeout              time.Duration
+	minUpdateInterval    time.Duration
+	reloginInterval      time.Duration
+	lenient, strict      bool
+	requirePluggedIn     bool
+	minClimateSOC        int
 	serverUpdateInterval time.Duration
+	serverActiveInterval time.Duration
+	serverIdleInterval   time.Duration
+	serverReadyTimeout   time.Duration
+	serverJobRetention   time.Duration
+	quietHours           quietHours
 	serverAddr           string
+	basePath             string
+	pprofAddr            string
+	profiles             profiles
+	username, password   string
+	passwordFile         string
+	region               string
+	sessionFile          string
+	vin                  string
+	url                  string
+	lang                 string
+	plain, debug, json   bool
+	timeFormat           string
+	debugFields          bool
+	tariffs              tariffs
+	electricityRate      float64
+	historyDir           string
+	cacheDir             string
+	cacheGrace           time.Duration
+	archiveDir           string
+	mockDir              string
+	homebridgeURL        string
+	triggerURL           string
+	iftttKey             string
+	lowSOC               percentages
+	socAlerts            percentages
+	socHysteresis        int
+	departures           departures
+	departureLead        time.Duration
+	planCharging         bool
+	chargerLevel         string
+	calendarURL          string
+	calendarTag          string
+	preconditionLead     time.Duration
+	smtpHost             string
+	smtpUsername         string
+	smtpPassword         string
+	emailFrom            string
+	emailTo              string
+	emailEvents          string
+	emailSubject         string
+	emailTemplate        string
+	alexaToken           string
+	googleHomeToken      string
+	adminToken           string
+	slackSigningSecret   string
+	homebridgePrefix     string
+	chargeTarget         int
+	history              *history
+	upstream             *httpd.UpstreamStats
+	recorder             *recorder // for debug-dump
+	loginErr             error     // for debug-dump, which runs anyway
 }
 
 const (
@@ -34,6 +100,24 @@ const (
 	unitskWhPer100Km = "kWh/100km"
 )
 
+// setDefaultUnits fills in -units and -effunits, if they weren't given,
+// for the region: miles in the US and km everywhere else.
+func (cfg *config) setDefaultUnits() {
+	miles := cfg.region == carwings.RegionUSA
+	if cfg.units == "" {
+		cfg.units = unitsKM
+		if miles {
+			cfg.units = unitsMiles
+		}
+	}
+	if cfg.effunits == "" {
+		cfg.effunits = unitskWhPer100Km
+		if miles {
+			cfg.effunits = unitskWhPerMile
+		}
+	}
+}
+
 func usage(fs *flag.FlagSet) func() {
 	return func() {
 		fmt.Fprintf(os.Stderr, "USAGE\n")
@@ -45,46 +129,246 @@ func usage(fs *flag.FlagSet) func() {
 		})
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "COMMANDS\n")
-		fmt.Fprintf(os.Stderr, "  update            Load latest data from vehicle\n")
-		fmt.Fprintf(os.Stderr, "  battery           Get most recently loaded battery status\n")
-		fmt.Fprintf(os.Stderr, "  charge            Begin charging plugged-in vehicle\n")
-		fmt.Fprintf(os.Stderr, "  climate           Get most recently loaded climate control status\n")
-		fmt.Fprintf(os.Stderr, "  climate-off       Turn off climate control\n")
-		fmt.Fprintf(os.Stderr, "  climate-on        Turn on climate control\n")
-		fmt.Fprintf(os.Stderr, "  cabin-temp        Get cabin temperature\n")
-		fmt.Fprintf(os.Stderr, "  daily             Daily driving statistics\n")
-		fmt.Fprintf(os.Stderr, "  monthly <y> <m>   Monthly driving statistics\n")
-		fmt.Fprintf(os.Stderr, "  server            Listen for requests on port 8040\n")
+		for _, h := range commandHelps {
+			fmt.Fprintf(os.Stderr, "  %-17s %s\n", h.name, h.summary)
+		}
+		fmt.Fprintf(os.Stderr, "  help <command>    Show the usage of a command\n")
+		fmt.Fprintf(os.Stderr, "  <name>            Run the carwings-<name> plugin on the PATH\n")
 		fmt.Fprintf(os.Stderr, "\n")
 	}
 }
 
-func main() {
-	var (
-		cfg                 config
-		username, password  string
-		region, sessionFile string
-	)
-
-	fs := flag.NewFlagSet("carwings", flag.ExitOnError)
-	fs.StringVar(&username, "username", "", "carwings username")
-	fs.StringVar(&password, "password", "", "carwings password")
-	fs.StringVar(&region, "region", carwings.RegionUSA, "carwings region. Defaults to US (NNA).")
-	fs.StringVar(&sessionFile, "session-file", "~/.carwings-session", "carwings session file")
-	fs.StringVar(&cfg.units, "units", unitsMiles, "units to use (miles or km). Defaults to miles.")
-	fs.StringVar(&cfg.effunits, "effunits", unitskWhPerMile, "efficiency units to use (kWh/mile, kWh/km or kWh/100km). Defaults to kWh/mile.")
-	fs.StringVar(&carwings.BaseURL, "url", carwings.BaseURL, "base carwings api endpoint to use")
+// newFlagSet returns the flag set for the global flags, which fill in
+// cfg when parsed.
+func newFlagSet(cfg *config, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet("carwings", errorHandling)
+	fs.String("config", "", "config file to read flags from, such as /etc/carwings/config. Defaults to ~/.carwings, if it exists.")
+	fs.StringVar(&cfg.username, "username", "", "carwings username")
+	fs.StringVar(&cfg.password, "password", "", "carwings password, or - to read it from standard input")
+	fs.StringVar(&cfg.passwordFile, "password-file", "", "file to read the carwings password from, such as a Docker secret, instead of -password")
+	fs.StringVar(&cfg.region, "region", carwings.RegionUSA, "carwings region. Defaults to US (NNA).")
+	fs.StringVar(&cfg.sessionFile, "session-file", "~/.carwings-session", "carwings session file")
+	fs.StringVar(&cfg.units, "units", "", "units to use (miles or km). Defaults to miles for US (NNA) and km for other regions.")
+	fs.StringVar(&cfg.effunits, "effunits", "", "efficiency units to use (kWh/mile, kWh/km or kWh/100km). Defaults to kWh/mile for US (NNA) and kWh/100km for other regions.")
+	fs.StringVar(&cfg.url, "url", "", "base carwings api endpoint to use. Defaults to the one for -region.")
 	fs.DurationVar(&cfg.timeout, "timeout", 60*time.Second, "update timeout. Defaults to 60s")
+	fs.DurationVar(&cfg.minUpdateInterval, "min-update-interval", 0, "minimum time between update requests to the vehicle. More frequent requests reuse the last one.")
+	fs.DurationVar(&cfg.reloginInterval, "relogin-interval", 0, "with server, exporter and watch, log in again this long after each login, such as 23h, instead of waiting for the session to expire")
+	fs.BoolVar(&cfg.lenient, "lenient", false, "show the parts of a status that could be parsed, with a warning, when some of it can't be")
+	fs.BoolVar(&cfg.requirePluggedIn, "require-plugged-in", false, "refuse to send charging requests when the latest status says the vehicle isn't plugged in")
+	fs.IntVar(&cfg.minClimateSOC, "min-climate-soc", 0, "refuse to turn on climate control when the latest state of charge is below this percentage. 0 disables.")
+	fs.BoolVar(&cfg.strict, "strict", false, "fail on Carwings responses with unknown fields or empty values")
 	fs.DurationVar(&cfg.serverUpdateInterval, "server-update-interval", 10*time.Minute, "interval to update battery info when running a server")
+	fs.DurationVar(&cfg.serverActiveInterval, "server-active-update-interval", 5*time.Minute, "interval to update battery info while charging or running climate control. 0 uses -server-update-interval.")
+	fs.DurationVar(&cfg.serverIdleInterval, "server-idle-update-interval", time.Hour, "interval to update battery info while unplugged and idle. 0 uses -server-update-interval.")
+	fs.DurationVar(&cfg.serverReadyTimeout, "server-ready-timeout", 0, "how long requests made before the server has retrieved its first battery status wait for it. 0 doesn't wait.")
+	fs.DurationVar(&cfg.serverJobRetention, "server-job-retention", time.Hour, "how long the server keeps finished commands for GET /jobs/{id}")
+	fs.Var(&cfg.quietHours, "quiet-hours", "daily period (HH:MM-HH:MM) during which the server won't ask the vehicle for updates")
 	fs.StringVar(&cfg.serverAddr, "server-addr", ":8040", "address for HTTP server to listen on")
-	fs.BoolVar(&carwings.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.basePath, "base-path", "", "path prefix, such as /leaf, to serve all of the HTTP server's endpoints under, for reverse proxies")
+	fs.StringVar(&cfg.pprofAddr, "pprof", "", "address for a separate listener serving net/http/pprof profiles from the server or exporter, such as localhost:6060")
+	fs.Var(&cfg.profiles, "profile", "additional account for the HTTP server to serve under /<name>/, as \"<name> <username> <password> [region]\". May be repeated.")
+	fs.StringVar(&cfg.vin, "vin", "", "VIN of the vehicle to use. Defaults to the first vehicle on the account.")
+	fs.IntVar(&cfg.chargeTarget, "charge-target", 80, "state of charge, in percent, to estimate charging time to in addition to full")
+	fs.StringVar(&cfg.historyDir, "history-dir", "", "directory to keep a history of vehicle data in")
+	fs.StringVar(&cfg.cacheDir, "cache-dir", defaultCacheDir(), "directory to cache the statistics of months that are over in. Empty disables the cache.")
+	fs.DurationVar(&cfg.cacheGrace, "cache-grace", 0, "how long after a month is over to wait before caching it in -cache-dir, for trips reported late, e.g. 72h")
+	fs.StringVar(&cfg.archiveDir, "archive-dir", "", "directory to save every raw Carwings response in")
+	fs.StringVar(&cfg.mockDir, "mock", "", "directory of fixture JSON files, such as an -archive-dir, to answer all requests from instead of Carwings")
+	fs.StringVar(&cfg.alexaToken, "alexa-token", "", "serve Alexa Smart Home directives carrying this token at /alexa when running a server")
+	fs.StringVar(&cfg.googleHomeToken, "google-home-token", "", "serve Google Smart Home fulfillment requests carrying this bearer token at /google-home when running a server")
+	fs.StringVar(&cfg.adminToken, "admin-token", "", "bearer token for the server's /admin endpoints. Without it, they're refused.")
+	fs.StringVar(&cfg.slackSigningSecret, "slack-signing-secret", "", "answer Slack slash commands signed with this secret at /slack when running a server")
+	fs.StringVar(&cfg.homebridgeURL, "homebridge-url", "", "homebridge-http-webhooks URL to send battery, charging and climate state to when it changes, e.g. http://localhost:51828/")
+	fs.StringVar(&cfg.homebridgePrefix, "homebridge-prefix", "carwings", "prefix for the accessory IDs sent to -homebridge-url")
+	fs.StringVar(&cfg.triggerURL, "trigger-url", "", "URL to post charge_complete, plugged_in and low_soc events to. {event}, {vin} and {soc} in it are replaced.")
+	fs.StringVar(&cfg.iftttKey, "ifttt-key", "", "IFTTT Webhooks key, to post events to IFTTT instead of -trigger-url")
+	cfg.lowSOC = percentages{20}
+	fs.Var(&cfg.lowSOC, "low-soc", "comma-separated states of charge, in percent, below which the low_soc event is triggered")
+	fs.Var(&cfg.socAlerts, "soc-alerts", "comma-separated states of charge, in percent, at which the soc_reached event is triggered, e.g. 80,100")
+	fs.IntVar(&cfg.socHysteresis, "soc-hysteresis", 5, "how far, in percent, the state of charge must move back past a -low-soc or -soc-alerts threshold before it triggers again")
+	fs.Var(&cfg.departures, "departure", "regular departure time as \"<days> <HH:MM>\", e.g. \"mon-fri 07:30\", to send a departure_reminder event for if the car isn't ready. May be repeated.")
+	fs.DurationVar(&cfg.departureLead, "departure-reminder", 8*time.Hour, "how long before each -departure to check whether the car is ready")
+	fs.BoolVar(&cfg.planCharging, "plan-charging", false, "when running a server, start charging in time to reach -charge-target by each -departure")
+	fs.StringVar(&cfg.chargerLevel, "charger-level", "2", "charger the car is usually plugged into, for -plan-charging: 1, 2 or 6kw")
+	fs.StringVar(&cfg.calendarURL, "calendar-url", "", "when running a server, iCalendar (ICS) URL to turn on climate control before events from")
+	fs.StringVar(&cfg.calendarTag, "calendar-tag", "[leaf]", "text in the title of -calendar-url events to turn on climate control for")
+	fs.DurationVar(&cfg.preconditionLead, "precondition-lead", 15*time.Minute, "how long before -calendar-url events to turn on climate control")
+	fs.StringVar(&cfg.smtpHost, "smtp-host", "", "SMTP server (host:port) to email events through")
+	fs.StringVar(&cfg.smtpUsername, "smtp-username", "", "SMTP username, if the server requires authentication")
+	fs.StringVar(&cfg.smtpPassword, "smtp-password", "", "SMTP password")
+	fs.StringVar(&cfg.emailFrom, "email-from", "", "sender address for event emails. Defaults to the first -email-to address.")
+	fs.StringVar(&cfg.emailTo, "email-to", "", "comma-separated recipient addresses for event emails")
+	fs.StringVar(&cfg.emailEvents, "email-events", defaultEmailEvents, "comma-separated events to send emails for")
+	fs.StringVar(&cfg.emailSubject, "email-subject", defaultEmailSubject, "Go template for the subject of event emails")
+	fs.StringVar(&cfg.emailTemplate, "email-template", "", "file containing a Go template for the body of event emails")
+	fs.Float64Var(&cfg.electricityRate, "electricity-rate", 0, "electricity rate per kWh for trip costs, instead of the rate configured with Carwings")
+	fs.Var(&cfg.tariffs, "tariff", "time-of-use tariff window as \"<name> <HH:MM>-<HH:MM> <rate per kWh>\". May be repeated.")
+	fs.StringVar(&cfg.lang, "lang", defaultLang(), "language for output (en, de, fr or ja). Defaults to the language of the current locale.")
+	fs.StringVar(&cfg.timeFormat, "time-format", "", `how to print timestamps: a Go time layout, such as "Jan 2 15:04", or "relative" for "2 hours ago"`)
+	fs.BoolVar(&cfg.plain, "plain", false, "log-friendly output: timestamped progress messages without decorations")
+	fs.BoolVar(&cfg.json, "json", false, "print results of the watch and server commands as JSON Lines on stdout, and progress on stderr")
+	fs.BoolVar(&cfg.debug, "debug", false, "debug mode")
+	fs.BoolVar(&cfg.debugFields, "debug-unknown-fields", false, "log fields in Carwings responses that aren't understood yet")
 	fs.Usage = usage(fs)
+	return fs
+}
+
+// defaultCacheDir returns the carwings directory in the user's cache
+// directory, or "" if there isn't one.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "carwings")
+}
+
+// command returns the function running the named built-in command, or
+// nil if there isn't one.
+func command(name string, cfg *config) func(context.Context, *carwings.Session, config, []string) error {
+	switch name {
+	case "update":
+		return runUpdate
+
+	case "battery":
+		return runBattery
+
+	case "charge":
+		return runCharge
+
+	case "charge-stop":
+		return runChargeStop
+
+	case "climate":
+		return runClimateStatus
+
+	case "climate-off":
+		return runClimateOff
+
+	case "climate-on":
+		return runClimateOn
+
+	case "cabin-temp":
+		return runCabinTemp
+
+	case "counters":
+		return runCounters
+
+	case "ping":
+		return runPing
+
+	case "status":
+		return runStatus
+
+	case "range":
+		return runRange
+
+	case "predict":
+		return runPredict
+
+	case "watch":
+		return runWatch
+
+	case "server":
+		cfg.upstream = httpd.NewUpstreamStats()
+		return runServer
+
+	case "exporter":
+		cfg.upstream = httpd.NewUpstreamStats()
+		return runExporter
+
+	case "monthly":
+		return runMonthly
+
+	case "daily":
+		return runDaily
+
+	case "trips":
+		return runTrips
+
+	case "odometer":
+		return runOdometer
+
+	case "cost":
+		return runCost
+
+	case "carbon":
+		return runCarbon
+
+	case "archive":
+		return runArchive
+
+	case "backfill":
+		return runBackfill
+
+	case "debug-dump":
+		cfg.recorder = &recorder{}
+		return runDebugDump
+
+	}
+	return nil
+}
+
+// readPassword replaces the password with the contents of
+// -password-file, or a line read from stdin for -password -, so it
+// needn't be in the process's arguments.
+func (cfg *config) readPassword(stdin io.Reader) error {
+	switch {
+	case cfg.passwordFile != "" && cfg.password != "":
+		return errors.New("-password and -password-file can't be used together")
+
+	case cfg.passwordFile != "":
+		data, err := os.ReadFile(cfg.passwordFile)
+		if err != nil {
+			return err
+		}
+		cfg.password = strings.TrimRight(string(data), "\r\n")
+
+	case cfg.password == "-":
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading password: %w", err)
+		}
+		cfg.password = strings.TrimRight(line, "\r\n")
+	}
+	return nil
+}
+
+// parseFlags parses the command line, environment and config file
+// into fs.  The config file is the one given by -config or
+// $CARWINGS_CONFIG, or otherwise ~/.carwings if there is one.
+func parseFlags(fs *flag.FlagSet) error {
+	config := os.Getenv("CARWINGS_CONFIG")
+	if config == "" {
+		config = filepath.Join(os.Getenv("HOME"), ".carwings")
+		if _, err := os.Stat(config); err != nil {
+			config = ""
+		}
+	}
+	if config != "" {
+		fs.Set("config", config)
+	}
 
-	ff.Parse(fs, os.Args[1:],
-		ff.WithConfigFile(filepath.Join(os.Getenv("HOME"), ".carwings")),
+	return ff.Parse(fs, os.Args[1:],
+		ff.WithConfigFileFlag("config"),
 		ff.WithConfigFileParser(configParser),
 		ff.WithEnvVarPrefix("CARWINGS"),
 	)
+}
+
+func main() {
+	var cfg config
+
+	fs := newFlagSet(&cfg, flag.ExitOnError)
+	if err := parseFlags(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	lang, plain, jsonOutput, timeFormat = cfg.lang, cfg.plain, cfg.json, cfg.timeFormat
+	carwings.Debug = cfg.debug
+	carwings.DebugUnknownFields = cfg.debugFields
 
 	args := fs.Args()
 	if len(args) < 1 {
@@ -92,76 +376,186 @@ func main() {
 		os.Exit(1)
 	}
 
-	if username == "" {
+	// Help doesn't need credentials
+	if strings.ToLower(args[0]) == "help" || helpRequested(args[1:]) {
+		runHelp(fs, cfg, args)
+		return
+	}
+
+	if err := cfg.readPassword(os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	// No credentials are needed for fixtures, and the real session
+	// shouldn't be replaced by a mock one.
+	if cfg.mockDir != "" {
+		if cfg.username == "" {
+			cfg.username, cfg.password = "mock", "mock"
+		}
+		cfg.sessionFile = ""
+	}
+
+	if cfg.username == "" {
 		fmt.Fprintf(os.Stderr, "ERROR: -username must be provided (it used to be -email)\n")
 		os.Exit(1)
 	}
 
-	if password == "" {
+	if cfg.password == "" {
 		fmt.Fprintf(os.Stderr, "ERROR: -password must be provided\n")
 		os.Exit(1)
 	}
 
+	if cfg.lenient && cfg.strict {
+		fmt.Fprintf(os.Stderr, "ERROR: -lenient and -strict can't be used together\n")
+		os.Exit(1)
+	}
+
+	cfg.setDefaultUnits()
 	if cfg.units != unitsMiles && cfg.units != unitsKM {
 		fmt.Fprintf(os.Stderr, "ERROR: unsupported units (%q) -- must be miles or km\n", cfg.units)
 		os.Exit(1)
 	}
 
-	var run func(*carwings.Session, config, []string) error
-
-	cmd, args := strings.ToLower(args[0]), args[1:]
-	switch cmd {
-	case "update":
-		run = runUpdate
+	if cfg.basePath != "" && !strings.HasPrefix(cfg.basePath, "/") {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid base path (%q) -- must start with /\n", cfg.basePath)
+		os.Exit(1)
+	}
+	cfg.basePath = strings.TrimRight(cfg.basePath, "/")
 
-	case "battery":
-		run = runBattery
+	h, err := openHistory(cfg.historyDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.history = h
 
-	case "charge":
-		run = runCharge
+	cmd, args := strings.ToLower(args[0]), args[1:]
+	run := command(cmd, &cfg)
+	if run == nil {
+		path := findPlugin(cmd)
+		if path == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		run = runPlugin(path, fs)
+	}
 
-	case "climate":
-		run = runClimateStatus
+	// Cancel any outstanding requests on the first interrupt.  A
+	// second one exits immediately.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigch := make(chan os.Signal, 2)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigch
+		cancel()
+		<-sigch
+		os.Exit(130)
+	}()
+
+	progress(tr("Logging into Carwings..."))
+
+	opts := append(cfg.sessionOptions(), carwings.WithRegion(cfg.region), carwings.WithVIN(cfg.vin))
+	if cfg.sessionFile != "" {
+		opts = append(opts, carwings.WithStore(carwings.FileStore(cfg.sessionFile)))
+	}
 
-	case "climate-off":
-		run = runClimateOff
+	s, err := carwings.NewSessionContext(ctx, cfg.username, cfg.password, opts...)
+	if err != nil && cfg.recorder != nil && ctx.Err() == nil {
+		// debug-dump records why logging in failed
+		s, cfg.loginErr = nil, err
+	} else if err != nil {
+		exitError(ctx, err)
+	}
 
-	case "climate-on":
-		run = runClimateOn
+	if err := run(ctx, s, cfg, args); err != nil {
+		exitError(ctx, err)
+	}
+}
 
-	case "cabin-temp":
-		run = runCabinTemp
+// sessionOptions returns the options for every Session, whichever
+// account and vehicle it is for.
+func (cfg config) sessionOptions() []carwings.Option {
+	opts := []carwings.Option{
+		carwings.WithBaseURL(cfg.url),
+		carwings.WithMinUpdateInterval(cfg.minUpdateInterval),
+		carwings.WithParseMode(cfg.parseMode()),
+		carwings.WithRequirePluggedIn(cfg.requirePluggedIn),
+		carwings.WithMinClimateSOC(cfg.minClimateSOC),
+	}
+	if cfg.cacheDir != "" {
+		opts = append(opts, carwings.WithStatisticsCache(cfg.cacheDir))
+		opts = append(opts, carwings.WithStatisticsGrace(cfg.cacheGrace))
+	}
+	if cfg.archiveDir != "" {
+		opts = append(opts, carwings.WithArchiveDir(cfg.archiveDir))
+	}
+	if cfg.upstream != nil {
+		opts = append(opts, carwings.WithRequestHook(cfg.upstream.Observe))
+	}
+	var transport http.RoundTripper
+	if cfg.mockDir != "" {
+		transport = mockTransport{dir: cfg.mockDir}
+	}
+	if cfg.recorder != nil {
+		cfg.recorder.next, transport = transport, cfg.recorder
+	}
+	if transport != nil {
+		opts = append(opts, carwings.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	return opts
+}
 
-	case "server":
-		run = runServer
+// exitCode is returned by commands to exit with a specific status,
+// after they have already printed why.
+type exitCode int
 
-	case "monthly":
-		run = runMonthly
-
-	case "daily":
-		run = runDaily
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
 
-	default:
-		fs.Usage()
-		os.Exit(1)
+// exitError prints err and exits.  If the command was interrupted, the
+// error is likely a noisy wrapper around context.Canceled, so a
+// simpler message is printed instead.
+func exitError(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: interrupted\n")
+		os.Exit(130)
 	}
 
-	fmt.Println("Logging into Carwings...")
-
-	s := &carwings.Session{
-		Region:   region,
-		Filename: sessionFile,
+	var code exitCode
+	if errors.As(err, &code) {
+		os.Exit(int(code))
 	}
 
-	if err := s.Connect(username, password); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		os.Exit(1)
+	fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	os.Exit(1)
+}
+
+// parseMode returns the carwings.ParseMode selected by the -lenient
+// and -strict flags.
+func (cfg config) parseMode() carwings.ParseMode {
+	switch {
+	case cfg.strict:
+		return carwings.ParseStrict
+	case cfg.lenient:
+		return carwings.ParseLenient
 	}
+	return carwings.ParseDefault
+}
 
-	if err := run(s, cfg, args); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		os.Exit(1)
+// partial prints a warning and returns nil if err is a
+// *carwings.PartialError from a -lenient session, so the fields that
+// could be parsed are still shown.
+func partial(err error) error {
+	var perr *carwings.PartialError
+	if errors.As(err, &perr) {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+		return nil
 	}
+	return err
 }
 
 func configParser(r io.Reader, set func(name, value string) error) error {
@@ -268,259 +662,473 @@ func efficiencyToUnits(unitsIn, unitsOut string, efficiency float64) float64 {
 }
 
 // waitForResult will poll using the supplied method until either success or error
-func waitForResult(key string, timeout time.Duration, poll func(string) (bool, error)) error {
+func waitForResult(ctx context.Context, key string, timeout time.Duration, poll func(context.Context, string) (bool, error)) error {
+	start := time.Now()
+	attempts, err := pollResult(ctx, key, timeout, poll, progressTick)
+	progressEnd(err, attempts, time.Since(start))
+	return err
+}
+
+// pollResult polls using the supplied method until either success or
+// error, calling tick (if not nil) before each attempt.  It returns
+// the number of attempts made.
+func pollResult(ctx context.Context, key string, timeout time.Duration, poll func(context.Context, string) (bool, error), tick func(int, time.Duration)) (int, error) {
 	// All requests take more than 3 seconds, so wait this before even trying
-	time.Sleep(3 * time.Second)
+	if err := sleep(ctx, 3*time.Second); err != nil {
+		return 0, err
+	}
 
 	start := time.Now()
-	for {
-		fmt.Print("+")
-		done, err := poll(key)
+	for attempt := 1; ; attempt++ {
+		if tick != nil {
+			tick(attempt, time.Since(start))
+		}
+		done, err := poll(ctx, key)
 		if done {
-			break
+			return attempt, err
 		}
-		if time.Since(start) > timeout {
-			err = fmt.Errorf("timed out waiting %v for update", timeout)
+		if err == nil && time.Since(start) > timeout {
+			err = fmt.Errorf("timed out after %v waiting for update (%d attempts)", timeout, attempt)
+		}
+		if err == nil {
+			err = sleep(ctx, 3*time.Second)
 		}
 		if err != nil {
-			fmt.Println("! :-(")
-			return err
+			return attempt, err
 		}
-		time.Sleep(3 * time.Second)
 	}
+}
 
-	fmt.Println(" :-)")
-	return nil
+// sleep pauses for the duration d, or until ctx is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
-func runUpdate(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Requesting update from Carwings...")
+func runUpdate(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("update").Parse(args)
 
-	key, err := s.UpdateStatus()
+	progress(tr("Requesting update from Carwings..."))
+
+	key, err := s.UpdateStatusContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	fmt.Print("Waiting for update to complete... ")
-	return waitForResult(key, cfg.timeout, s.CheckUpdate)
+	progressStart(tr("Waiting for update to complete... "))
+	return waitForResult(ctx, key, cfg.timeout, s.CheckUpdateContext)
+}
+
+// printCapacity prints the battery level, leaving out the parts the
+// vehicle didn't report rather than printing zeros.
+func printCapacity(bs carwings.BatteryStatus) {
+	switch {
+	case bs.Remaining > 0 && bs.RemainingWH > 0:
+		fmt.Printf(tr("  Capacity: %d / %d (%d%%) %.1fkWh\n"), bs.Remaining, bs.Capacity, bs.StateOfCharge, float64(bs.RemainingWH)/1000)
+	case bs.Remaining > 0:
+		fmt.Printf(tr("  Capacity: %d / %d (%d%%)\n"), bs.Remaining, bs.Capacity, bs.StateOfCharge)
+	default:
+		fmt.Printf(tr("  Capacity: %.1fkWh\n"), float64(bs.RemainingWH)/1000)
+	}
 }
 
-func runBattery(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Getting latest retrieved battery status...")
+func runBattery(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("battery").Parse(args)
 
-	bs, err := s.BatteryStatus()
-	if err != nil {
+	progress(tr("Getting latest retrieved battery status..."))
+
+	bs, err := s.BatteryStatusContext(ctx)
+	if err := partial(err); err != nil {
 		return err
 	}
-
-	fmt.Printf("Battery status as of %s:\n", bs.Timestamp)
-	if bs.Remaining > 0 {
-		fmt.Printf("  Capacity: %d / %d (%d%%) %.1fkWh\n", bs.Remaining, bs.Capacity, bs.StateOfCharge, float64(bs.RemainingWH)/1000)
-	} else {
-		fmt.Printf("  Capacity: %.1fkWh\n", float64(bs.RemainingWH)/1000)
+	if err := cfg.history.addBattery(bs); err != nil {
+		return err
 	}
+
+	fmt.Printf(tr("Battery status as of %s:\n"), formatTime(bs.Timestamp, ""))
+	printCapacity(bs)
 	if bs.CruisingRangeACOn > 0 {
-		fmt.Printf("  Cruising range: %s (%s with AC)\n", prettyUnits(cfg.units, bs.CruisingRangeACOff), prettyUnits(cfg.units, bs.CruisingRangeACOn))
-	}
-	fmt.Printf("  Plug-in state: %s\n", bs.PluginState)
-	fmt.Printf("  Charging status: %s\n", bs.ChargingStatus)
-	fmt.Printf("  Time to full:\n")
-	if bs.TimeToFull.Level1 > 0 {
-		fmt.Printf("    Level 1 charge: %s\n", bs.TimeToFull.Level1)
+		fmt.Printf(tr("  Cruising range: %s (%s with AC)\n"), prettyUnits(cfg.units, bs.CruisingRangeACOff), prettyUnits(cfg.units, bs.CruisingRangeACOn))
 	}
-	if bs.TimeToFull.Level2 > 0 {
-		fmt.Printf("    Level 2 charge: %s\n", bs.TimeToFull.Level2)
+	fmt.Printf(tr("  Plug-in state: %s\n"), tr(bs.PluginState.String()))
+	fmt.Printf(tr("  Charging status: %s\n"), tr(bs.ChargingStatus.String()))
+	if bs.ChargingPower > 0 {
+		fmt.Printf(tr("  Charging power: ~%.1f kW\n"), bs.ChargingPower)
 	}
-	if bs.TimeToFull.Level2At6kW > 0 {
-		fmt.Printf("    Level 2 at 6 kW: %s\n", bs.TimeToFull.Level2At6kW)
+	switch {
+	case bs.ChargeType != "" && bs.ChargeVoltage > 0:
+		fmt.Printf(tr("  Charger: %s (%dV)\n"), tr(bs.ChargeType.String()), bs.ChargeVoltage)
+	case bs.ChargeType != "":
+		fmt.Printf(tr("  Charger: %s\n"), tr(bs.ChargeType.String()))
 	}
-	if bs.TimeToFull.Level1 == 0 && bs.TimeToFull.Level2 == 0 && bs.TimeToFull.Level2At6kW == 0 {
-		fmt.Printf("    (no time-to-full estimates available)\n")
+	printTimeToFull(tr("  Time to full:\n"), bs.TimeToFull)
+	if cfg.chargeTarget > 0 && cfg.chargeTarget < 100 && bs.StateOfCharge < cfg.chargeTarget {
+		printTimeToFull(fmt.Sprintf(tr("  Time to %d%%:\n"), cfg.chargeTarget), bs.TimeToSOC(cfg.chargeTarget))
 	}
 	fmt.Println()
 
 	return nil
 }
 
-func runCharge(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Sending charging request...")
+func printTimeToFull(header string, ttf carwings.TimeToFull) {
+	fmt.Print(header)
+	if ttf.Level1 > 0 {
+		fmt.Printf(tr("    Level 1 charge: %s\n"), ttf.Level1)
+	}
+	if ttf.Level2 > 0 {
+		fmt.Printf(tr("    Level 2 charge: %s\n"), ttf.Level2)
+	}
+	if ttf.Level2At6kW > 0 {
+		fmt.Printf(tr("    Level 2 at 6 kW: %s\n"), ttf.Level2At6kW)
+	}
+	if ttf.Level1 == 0 && ttf.Level2 == 0 && ttf.Level2At6kW == 0 {
+		fmt.Print(tr("    (no time-to-full estimates available)\n"))
+	}
+}
+
+func runCharge(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("charge").Parse(args)
+
+	if cfg.requirePluggedIn {
+		// Nothing is cached between runs, so get the plug state
+		// for the session to check
+		progress(tr("Getting latest retrieved battery status..."))
+		if _, err := s.BatteryStatusContext(ctx); partial(err) != nil {
+			return err
+		}
+	}
+
+	progress(tr("Sending charging request..."))
 
-	err := s.ChargingRequest()
+	err := s.ChargingRequestContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Charging request sent")
+	progress(tr("Charging request sent"))
 
 	return nil
 }
 
-func runClimateStatus(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Getting latest retrieved climate control status...")
+func runChargeStop(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("charge-stop").Parse(args)
 
-	cs, err := s.ClimateControlStatus()
+	progress(tr("Sending charging stop request..."))
+
+	err := s.StopChargingRequestContext(ctx)
+	if errors.Is(err, carwings.ErrUnsupported) {
+		return fmt.Errorf("the Carwings service for region %s can't stop charging remotely", cfg.region)
+	}
 	if err != nil {
 		return err
 	}
 
-	running := "no"
+	progress(tr("Charging stop request sent"))
+
+	return nil
+}
+
+func runClimateStatus(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("climate").Parse(args)
+
+	progress(tr("Getting latest retrieved climate control status..."))
+
+	cs, err := s.ClimateControlStatusContext(ctx)
+	if err := partial(err); err != nil {
+		return err
+	}
+
+	running := tr("no")
 	if cs.Running {
-		running = "yes"
+		running = tr("yes")
 	}
 
-	fmt.Printf("Climate status:\n")
-	fmt.Printf("  Running: %s\n", running)
+	fmt.Print(tr("Climate status:\n"))
+	fmt.Printf(tr("  Running: %s\n"), running)
 	if cs.Running {
-		fmt.Printf("  Will stop at: %s\n", cs.ACStopTime)
+		fmt.Printf(tr("  Will stop at: %s\n"), formatTime(cs.ACStopTime, ""))
 	}
 	if cs.PluginState != "" {
-		fmt.Printf("  Plug-in state: %s\n", cs.PluginState)
+		fmt.Printf(tr("  Plug-in state: %s\n"), tr(cs.PluginState.String()))
 	}
 	if cs.Temperature != 0 {
-		fmt.Printf("  Temperature setting: %d %s\n", cs.Temperature, cs.TemperatureUnit)
+		fmt.Printf(tr("  Temperature setting: %d %s\n"), cs.Temperature, cs.TemperatureUnit)
 	}
-	fmt.Printf("  Cruising range: %s (%s with AC)\n", prettyUnits(cfg.units, cs.CruisingRangeACOff), prettyUnits(cfg.units, cs.CruisingRangeACOn))
+	fmt.Printf(tr("  Cruising range: %s (%s with AC)\n"), prettyUnits(cfg.units, cs.CruisingRangeACOff), prettyUnits(cfg.units, cs.CruisingRangeACOn))
 	fmt.Println()
 
 	return nil
 }
 
-func runClimateOff(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Sending climate control off request...")
+func runClimateOff(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("climate-off").Parse(args)
 
-	key, err := s.ClimateOffRequest()
+	progress(tr("Sending climate control off request..."))
+
+	key, err := s.ClimateOffRequestContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	fmt.Print("Waiting for climate control update to complete... ")
-	err = waitForResult(key, cfg.timeout, s.CheckClimateOffRequest)
+	progressStart(tr("Waiting for climate control update to complete... "))
+	err = waitForResult(ctx, key, cfg.timeout, s.CheckClimateOffRequestContext)
 	if err == nil {
-		fmt.Println("Climate control turned off")
+		progress(tr("Climate control turned off"))
 	}
 	return err
 }
 
-func runClimateOn(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Sending climate control on request...")
+func runClimateOn(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("climate-on")
+	force := fs.Bool("force", false, "turn on climate control even if the battery is below -min-climate-soc")
+	fs.Parse(args)
+
+	if *force {
+		ctx = carwings.ContextWithForce(ctx)
+	} else if cfg.minClimateSOC > 0 {
+		// Nothing is cached between runs, so get the state of
+		// charge for the session to check
+		progress(tr("Getting latest retrieved battery status..."))
+		if _, err := s.BatteryStatusContext(ctx); partial(err) != nil {
+			return err
+		}
+	}
+
+	progress(tr("Sending climate control on request..."))
 
-	key, err := s.ClimateOnRequest()
+	key, err := s.ClimateOnRequestContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	fmt.Print("Waiting for climate control update to complete... ")
-	err = waitForResult(key, cfg.timeout, s.CheckClimateOnRequest)
+	progressStart(tr("Waiting for climate control update to complete... "))
+	err = waitForResult(ctx, key, cfg.timeout, s.CheckClimateOnRequestContext)
 
 	if err == nil {
-		fmt.Println("Climate control turned on")
+		progress(tr("Climate control turned on"))
 	}
 	return err
 }
 
-func runCabinTemp(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Getting latest cabin temperature...")
+func runPing(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("ping").Parse(args)
+
+	if err := s.PingContext(ctx); err != nil {
+		return err
+	}
+
+	fmt.Println(tr("Carwings is reachable and the session is valid."))
+	return nil
+}
+
+func runCounters(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("counters").Parse(args)
+
+	progress(tr("Getting charge counters..."))
+
+	uc, err := s.UsageCountersContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("Charge counters as of %s:\n"), formatTime(uc.Timestamp, ""))
+	fmt.Printf(tr("  Quick charges: %d\n"), uc.QuickCharges)
+	fmt.Printf(tr("  Level 1 and 2 charges: %d\n"), uc.NormalCharges)
+	fmt.Println()
+
+	return nil
+}
+
+func runCabinTemp(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("cabin-temp").Parse(args)
 
-	key, err := s.CabinTempRequest()
+	progress(tr("Getting latest cabin temperature..."))
+
+	key, err := s.CabinTempRequestContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	fmt.Print("Waiting for cabin temperature request to complete... ")
-	err = waitForResult(key, cfg.timeout, s.CheckCabinTempRequest)
+	progressStart(tr("Waiting for cabin temperature request to complete... "))
+	err = waitForResult(ctx, key, cfg.timeout, s.CheckCabinTempRequestContext)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Cabin temperature: %d°\n", s.GetCabinTemp())
+	fmt.Printf(tr("Cabin temperature: %d°\n"), s.GetCabinTemp())
 
 	return nil
 }
 
-func runMonthly(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Sending monthly statistics request...")
+func runMonthly(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("monthly")
+	output := fs.String("output", outputText, "output format: text or xlsx")
+	file := fs.String("file", "", "file to write xlsx output to. Defaults to carwings-monthly-<YYYY-MM>.xlsx.")
+	sortBy := fs.String("sort", "", "list the trips sorted by efficiency, distance, energy or time, instead of by day")
+	desc := fs.Bool("desc", false, "with -sort, sort in descending order (least efficient, longest, most energy or latest first)")
+	fs.Parse(args)
+	args = fs.Args()
 
-	var month time.Time
-	if len(args) == 0 {
-		month = time.Now().Local()
-	} else {
-		y, err := strconv.Atoi(args[0])
-		if err != nil {
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	var less func(a, b carwings.TripDetail) bool
+	if *sortBy != "" {
+		var err error
+		if less, err = tripOrder(*sortBy); err != nil {
 			return err
 		}
-		if len(args) > 1 {
-			m, err := strconv.Atoi(args[1])
-			if err != nil {
-				return err
-			}
-			month = time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC)
-		} else {
-			month = time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
-		}
 	}
 
-	ms, err := s.GetMonthlyStatistics(month)
+	month, err := monthArgs(args)
+	if err != nil {
+		return err
+	}
+
+	progress(tr("Sending monthly statistics request..."))
+
+	ms, err := s.GetMonthlyStatisticsContext(ctx, month)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Monthly Driving Statistics for %s\n", month.Format("January 2006"))
-	fmt.Printf("  Driving efficiency: %.4f %s over %s in %d trips\n",
+	if *output == outputXLSX {
+		if *file == "" {
+			*file = "carwings-monthly-" + month.Format("2006-01") + ".xlsx"
+		}
+		var trips []carwings.TripDetail
+		for _, d := range ms.Dates {
+			trips = append(trips, d.Trips...)
+		}
+		if less != nil {
+			sortTrips(trips, less, *desc)
+		}
+		title := fmt.Sprintf(tr("Monthly Driving Statistics for %s\n"), month.Format("January 2006"))
+		return writeStatisticsXLSX(cfg, *file, strings.TrimSpace(title), trips, cfg.defaultRate(ms.ElectricityRate))
+	}
+
+	fmt.Printf(tr("Monthly Driving Statistics for %s\n"), month.Format("January 2006"))
+	fmt.Printf(tr("  Driving efficiency: %.4f %s over %s in %d trips\n"),
 		efficiencyToUnits(ms.EfficiencyScale, cfg.effunits, ms.Total.Efficiency*1000),
 		cfg.effunits, prettyUnits(cfg.units, ms.Total.MetersTravelled), ms.Total.Trips)
-	fmt.Printf("  Driving cost: %.4f at a rate of %.4f/kWh for %.1f kWh => %.4f/%s\n",
+	fmt.Printf(tr("  Driving cost: %.4f at a rate of %.4f/kWh for %.1f kWh => %.4f/%s\n"),
 		ms.ElectricityBill, ms.ElectricityRate, ms.Total.PowerConsumed, ms.ElectricityBill/metersToUnits(cfg.units, ms.Total.MetersTravelled), cfg.units)
+
+	// Costs are shown if there's a rate for trips outside any
+	// -tariff window
+	var cost func(carwings.TripDetail) float64
+	if rate := cfg.defaultRate(ms.ElectricityRate); rate > 0 {
+		cost = func(t carwings.TripDetail) float64 {
+			return t.PowerConsumedTotal / 1000 * cfg.tripRate(t, rate)
+		}
+
+		var total float64
+		for _, d := range ms.Dates {
+			for _, t := range d.Trips {
+				total += cost(t)
+			}
+		}
+		if ms.Total.MetersTravelled > 0 {
+			fmt.Printf(tr("  Trip cost: %.2f => %.4f/%s\n"), total, total/metersToUnits(cfg.units, ms.Total.MetersTravelled), cfg.units)
+		}
+	}
 	fmt.Println()
 
+	if less != nil {
+		// Trips without any distance have no meaningful efficiency
+		var trips []carwings.TripDetail
+		for _, d := range ms.Dates {
+			for _, t := range d.Trips {
+				if t.Meters > 0 || *sortBy != rankByEfficiency {
+					trips = append(trips, t)
+				}
+			}
+		}
+		sortTrips(trips, less, *desc)
+		notes, err := cfg.history.tripNotes()
+		if err != nil {
+			return err
+		}
+		printTripList(cfg, trips, cost, notes)
+		fmt.Println()
+		return nil
+	}
+
 	for i := 0; i < len(ms.Dates); i++ {
 		date := ms.Dates[i]
 		var distance int
-		var power float64
+		var power, dayCost float64
 		for j := 0; j < len(date.Trips); j++ {
 			t := date.Trips[j]
 			if j == 0 {
-				fmt.Printf("  Trips on %s\n", t.Started.Local().Format("2006-01-02 Monday"))
+				fmt.Printf(tr("  Trips on %s\n"), t.Started.Format("2006-01-02 Monday"))
 			}
 			distance += t.Meters
 			power += t.PowerConsumedTotal
 
-			fmt.Printf("    %5s %6.1f %s %5.1f %s %6.1f kWh\n", t.Started.Local().Format("15:04"),
+			fmt.Printf("    %5s %6.1f %s %5.1f %s %6.1f kWh", t.Started.Format("15:04"),
 				metersToUnits(cfg.units, t.Meters), cfg.units,
 				efficiencyToUnits(ms.EfficiencyScale, cfg.effunits, t.Efficiency),
 				cfg.effunits, t.PowerConsumedTotal/1000)
+			if cost != nil {
+				c := cost(t)
+				dayCost += c
+				fmt.Printf(" %8.2f", c)
+			}
+			fmt.Println()
 		}
 		if distance > 0 {
-			fmt.Printf("          =======%.*s ======%.*s ==========\n",
-				len(cfg.units), "====",
-				len(cfg.effunits), "=========")
+			if !plain {
+				fmt.Printf("          =======%.*s ======%.*s ==========",
+					len(cfg.units), "====",
+					len(cfg.effunits), "=========")
+				if cost != nil {
+					fmt.Print(" ========")
+				}
+				fmt.Println()
+			}
 			efficiency := power / float64(distance) // in Wh/m or kWh/km
-			fmt.Printf("          %6.1f %s %5.1f %s %6.1f kWh\n\n",
+			fmt.Printf("          %6.1f %s %5.1f %s %6.1f kWh",
 				metersToUnits(cfg.units, distance), cfg.units,
 				efficiencyToUnits("kWh/km", cfg.effunits, efficiency),
 				cfg.effunits, power/1000)
+			if cost != nil {
+				fmt.Printf(" %8.2f", dayCost)
+			}
+			fmt.Print("\n\n")
 		}
 	}
 
 	return nil
 }
 
-func runDaily(s *carwings.Session, cfg config, args []string) error {
-	fmt.Println("Sending daily statistics request...")
+func runDaily(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("daily").Parse(args)
+
+	progress(tr("Sending daily statistics request..."))
 
-	ds, err := s.GetDailyStatistics(time.Now().Local())
+	ds, err := s.GetDailyStatisticsContext(ctx, time.Now().Local())
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Daily Driving Statistics for %s\n", ds.TargetDate.Format("2006-01-02"))
-	fmt.Printf("  Driving efficiency: %5.1f %-10.10s %-5.5s\n",
+	fmt.Printf(tr("Daily Driving Statistics for %s\n"), ds.TargetDate.Format("2006-01-02"))
+	fmt.Printf(tr("  Driving efficiency: %5.1f %-10.10s %-5.5s\n"),
 		efficiencyToUnits(ds.EfficiencyScale, cfg.effunits, ds.Efficiency),
 		cfg.effunits, strings.Repeat("*", ds.EfficiencyLevel))
-	fmt.Printf("  Acceleration:     %7.1f %-10.10s %-5.5s\n",
+	fmt.Printf(tr("  Acceleration:     %7.1f %-10.10s %-5.5s\n"),
 		ds.PowerConsumedMotor, "kWh", strings.Repeat("*", ds.PowerConsumedMotorLevel))
-	fmt.Printf("  Regeneration:     %7.1f %-10.10s %-5.5s\n",
+	fmt.Printf(tr("  Regeneration:     %7.1f %-10.10s %-5.5s\n"),
 		ds.PowerRegeneration, "kWh", strings.Repeat("*", ds.PowerRegenerationLevel))
-	fmt.Printf("  Auxilliary usage: %7.1f %-10.10s %-5.5s\n",
+	fmt.Printf(tr("  Auxilliary usage: %7.1f %-10.10s %-5.5s\n"),
 		ds.PowerConsumedAUX, "Wh", strings.Repeat("*", ds.PowerConsumedAUXLevel))
 
 	return nil
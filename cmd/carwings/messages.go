@@ -0,0 +1,390 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// lang is the language used for command output.  It is set with the
+// -lang flag, and defaults to the language of the user's locale.
+var lang = "en"
+
+// defaultLang returns the language from the user's locale environment
+// variables, e.g. "ja" for "ja_JP.UTF-8".
+func defaultLang() string {
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := os.Getenv(v); l != "" && l != "C" && l != "POSIX" {
+			if i := strings.IndexAny(l, "_.@"); i >= 0 {
+				l = l[:i]
+			}
+			return strings.ToLower(l)
+		}
+	}
+	return "en"
+}
+
+// tr returns the translation of msg into the output language.  The
+// English message (which is usually a format string) is the key, and
+// it is returned unchanged if there is no translation available.
+func tr(msg string) string {
+	if t, ok := catalogs[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// catalogs holds message translations, keyed by language and then by
+// the English message.  Translations of format strings must keep the
+// same verbs in the same order.
+var catalogs = map[string]map[string]string{
+	"de": {
+		"  Time to %d%%:\n":                                                    "  Zeit bis %d%%:\n",
+		"  Charging power: ~%.1f kW\n":                                         "  Ladeleistung: ~%.1f kW\n",
+		"Getting latest vehicle status...":                                     "Aktuellen Fahrzeugstatus abrufen...",
+		"Battery status unavailable: %v\n":                                     "Batteriestatus nicht verfügbar: %v\n",
+		"Climate status unavailable: %v\n":                                     "Klimastatus nicht verfügbar: %v\n",
+		"Cabin temperature unavailable: %v\n":                                  "Innenraumtemperatur nicht verfügbar: %v\n",
+		"Location: %.5f, %.5f as of %s\n":                                      "Standort: %.5f, %.5f am %s\n",
+		"Location unavailable: %v\n":                                           "Standort nicht verfügbar: %v\n",
+		"Done after %d attempts in %s":                                         "Fertig nach %d Versuchen in %s",
+		"done after %d attempts in %s":                                         "fertig nach %d Versuchen in %s",
+		"failed":                                                               "fehlgeschlagen",
+		"[attempt %d, %s elapsed]":                                             "[Versuch %d, %s vergangen]",
+		"Sending monthly statistics requests...":                               "Monatsstatistiken anfordern...",
+		"Logging into Carwings...":                                             "Anmeldung bei Carwings...",
+		"Requesting update from Carwings...":                                   "Aktualisierung von Carwings anfordern...",
+		"Waiting for update to complete... ":                                   "Warten auf den Abschluss der Aktualisierung... ",
+		"Getting latest retrieved battery status...":                           "Letzten abgerufenen Batteriestatus laden...",
+		"Battery status as of %s:\n":                                           "Batteriestatus vom %s:\n",
+		"  Capacity: %d / %d (%d%%) %.1fkWh\n":                                 "  Kapazität: %d / %d (%d%%) %.1fkWh\n",
+		"  Capacity: %d / %d (%d%%)\n":                                         "  Kapazität: %d / %d (%d%%)\n",
+		"  Capacity: %.1fkWh\n":                                                "  Kapazität: %.1fkWh\n",
+		"  Cruising range: %s (%s with AC)\n":                                  "  Reichweite: %s (%s mit Klimaanlage)\n",
+		"  Plug-in state: %s\n":                                                "  Ladekabel: %s\n",
+		"  Charging status: %s\n":                                              "  Ladestatus: %s\n",
+		"  Charger: %s\n":                                                      "  Ladeart: %s\n",
+		"  Charger: %s (%dV)\n":                                                "  Ladeart: %s (%dV)\n",
+		"  Time to full:\n":                                                    "  Zeit bis voll:\n",
+		"    Level 1 charge: %s\n":                                             "    Level-1-Ladung: %s\n",
+		"    Level 2 charge: %s\n":                                             "    Level-2-Ladung: %s\n",
+		"    Level 2 at 6 kW: %s\n":                                            "    Level 2 mit 6 kW: %s\n",
+		"    (no time-to-full estimates available)\n":                          "    (keine Schätzungen verfügbar)\n",
+		"Sending charging request...":                                          "Ladeanforderung senden...",
+		"Charging request sent":                                                "Ladeanforderung gesendet",
+		"Sending charging stop request...":                                     "Anforderung zum Beenden des Ladevorgangs senden...",
+		"Charging stop request sent":                                           "Anforderung zum Beenden des Ladevorgangs gesendet",
+		"Getting latest retrieved climate control status...":                   "Letzten abgerufenen Klimastatus laden...",
+		"Climate status:\n":                                                    "Klimastatus:\n",
+		"  Running: %s\n":                                                      "  Aktiv: %s\n",
+		"  Will stop at: %s\n":                                                 "  Endet um: %s\n",
+		"  Temperature setting: %d %s\n":                                       "  Temperatureinstellung: %d %s\n",
+		"Sending climate control off request...":                               "Anforderung zum Ausschalten der Klimatisierung senden...",
+		"Waiting for climate control update to complete... ":                   "Warten auf die Klimatisierung... ",
+		"Climate control turned off":                                           "Klimatisierung ausgeschaltet",
+		"Sending climate control on request...":                                "Anforderung zum Einschalten der Klimatisierung senden...",
+		"Climate control turned on":                                            "Klimatisierung eingeschaltet",
+		"Getting latest cabin temperature...":                                  "Aktuelle Innenraumtemperatur abrufen...",
+		"Getting charge counters...":                                           "Ladezähler abrufen...",
+		"Charge counters as of %s:\n":                                          "Ladezähler vom %s:\n",
+		"  Quick charges: %d\n":                                                "  Schnellladungen: %d\n",
+		"  Level 1 and 2 charges: %d\n":                                        "  Ladungen mit Level 1 und 2: %d\n",
+		"Carwings is reachable and the session is valid.":                      "Carwings ist erreichbar und die Sitzung ist gültig.",
+		"Waiting for cabin temperature request to complete... ":                "Warten auf die Innenraumtemperatur... ",
+		"Cabin temperature: %d°\n":                                             "Innenraumtemperatur: %d°\n",
+		"Sending monthly statistics request...":                                "Monatsstatistik anfordern...",
+		"Monthly Driving Statistics for %s\n":                                  "Monatliche Fahrstatistik für %s\n",
+		"  Driving efficiency: %.4f %s over %s in %d trips\n":                  "  Fahreffizienz: %.4f %s über %s in %d Fahrten\n",
+		"  Driving cost: %.4f at a rate of %.4f/kWh for %.1f kWh => %.4f/%s\n": "  Fahrkosten: %.4f zu %.4f/kWh für %.1f kWh => %.4f/%s\n",
+		"  Trip cost: %.2f => %.4f/%s\n":                                       "  Fahrtkosten: %.2f => %.4f/%s\n",
+		"  Trips on %s\n":                                                      "  Fahrten am %s\n",
+		"Sending daily statistics request...":                                  "Tagesstatistik anfordern...",
+		"Daily Driving Statistics for %s\n":                                    "Tägliche Fahrstatistik für %s\n",
+		"  Driving efficiency: %5.1f %-10.10s %-5.5s\n":                        "  Fahreffizienz:    %5.1f %-10.10s %-5.5s\n",
+		"  Acceleration:     %7.1f %-10.10s %-5.5s\n":                          "  Beschleunigung: %7.1f %-10.10s %-5.5s\n",
+		"  Regeneration:     %7.1f %-10.10s %-5.5s\n":                          "  Rekuperation:   %7.1f %-10.10s %-5.5s\n",
+		"  Auxilliary usage: %7.1f %-10.10s %-5.5s\n":                          "  Nebenverbrauch: %7.1f %-10.10s %-5.5s\n",
+		"yes":                        "ja",
+		"no":                         "nein",
+		"not connected":              "nicht verbunden",
+		"connected":                  "verbunden",
+		"connected to quick charger": "mit Schnelllader verbunden",
+		"invalid":                    "ungültig",
+		"not charging":               "lädt nicht",
+		"charging":                   "lädt",
+		"rapidly charging":           "lädt schnell",
+		"level 1":                    "Level 1",
+		"level 2":                    "Level 2",
+		"quick charge":               "Schnellladung",
+
+		"just now":       "gerade eben",
+		"a minute ago":   "vor einer Minute",
+		"%d minutes ago": "vor %d Minuten",
+		"an hour ago":    "vor einer Stunde",
+		"%d hours ago":   "vor %d Stunden",
+		"a day ago":      "vor einem Tag",
+		"%d days ago":    "vor %d Tagen",
+		"in a minute":    "in einer Minute",
+		"in %d minutes":  "in %d Minuten",
+		"in an hour":     "in einer Stunde",
+		"in %d hours":    "in %d Stunden",
+		"in a day":       "in einem Tag",
+		"in %d days":     "in %d Tagen",
+
+		"Charging complete":                          "Laden abgeschlossen",
+		"Charging stopped early":                     "Laden vorzeitig beendet",
+		"Car plugged in":                             "Auto angesteckt",
+		"Battery low":                                "Batterie schwach",
+		"Charge level reached":                       "Ladestand erreicht",
+		"Not ready for departure":                    "Nicht bereit zur Abfahrt",
+		"WARNING: %s not archived: %v\n":             "WARNUNG: %s nicht archiviert: %v\n",
+		"Archived %d files to %s\n":                  "%d Dateien in %s archiviert\n",
+		"No driving history available":               "Kein Fahrverlauf verfügbar",
+		"Retrieved %d trips in %d months since %s\n": "%d Fahrten in %d Monaten seit %s abgerufen\n",
+		"Wrote %s\n":                                 "%s geschrieben\n",
+		"Exercising Carwings endpoints...":           "Carwings-Endpunkte werden aufgerufen...",
+		"WARNING: %s failed: %v\n":                   "WARNUNG: %s fehlgeschlagen: %v\n",
+		"Wrote %d requests to %s.  Check it for anything private before attaching it to an issue.\n": "%d Anfragen in %s geschrieben.  Prüfen Sie die Datei auf private Daten, bevor Sie sie an ein Issue anhängen.\n",
+		"Range as of %s:\n":                                                 "Reichweite vom %s:\n",
+		"  Vehicle estimate: %s (%s with AC)\n":                             "  Schätzung des Fahrzeugs: %s (%s mit Klimaanlage)\n",
+		"  Personalized estimate: %v\n":                                     "  Persönliche Schätzung: %v\n",
+		"  Personalized estimate: %s (%s with AC) at %.1f %s\n":             "  Persönliche Schätzung: %s (%s mit Klimaanlage) bei %.1f %s\n",
+		"Yes: %s is within range, even with AC.\n":                          "Ja: %s liegt in Reichweite, auch mit Klimaanlage.\n",
+		"No: %s is beyond the estimated range.\n":                           "Nein: %s liegt außerhalb der geschätzten Reichweite.\n",
+		"Maybe: %s is within range only without AC or by some estimates.\n": "Vielleicht: %s liegt nur ohne Klimaanlage oder nach einigen Schätzungen in Reichweite.\n",
+		"Currently %d%%, %d%% by %s\n":                                      "Derzeit %d%%, %d%% bis %s\n",
+	},
+
+	"fr": {
+		"  Time to %d%%:\n":                                                    "  Temps de charge à %d%% :\n",
+		"  Charging power: ~%.1f kW\n":                                         "  Puissance de charge : ~%.1f kW\n",
+		"Getting latest vehicle status...":                                     "Récupération de l'état du véhicule...",
+		"Battery status unavailable: %v\n":                                     "État de la batterie indisponible : %v\n",
+		"Climate status unavailable: %v\n":                                     "État de la climatisation indisponible : %v\n",
+		"Cabin temperature unavailable: %v\n":                                  "Température de l'habitacle indisponible : %v\n",
+		"Location: %.5f, %.5f as of %s\n":                                      "Position : %.5f, %.5f au %s\n",
+		"Location unavailable: %v\n":                                           "Position indisponible : %v\n",
+		"Done after %d attempts in %s":                                         "Terminé après %d tentatives en %s",
+		"done after %d attempts in %s":                                         "terminé après %d tentatives en %s",
+		"failed":                                                               "échec",
+		"[attempt %d, %s elapsed]":                                             "[tentative %d, %s écoulées]",
+		"Sending monthly statistics requests...":                               "Demande des statistiques mensuelles...",
+		"Logging into Carwings...":                                             "Connexion à Carwings...",
+		"Requesting update from Carwings...":                                   "Demande de mise à jour à Carwings...",
+		"Waiting for update to complete... ":                                   "En attente de la mise à jour... ",
+		"Getting latest retrieved battery status...":                           "Récupération du dernier état de la batterie...",
+		"Battery status as of %s:\n":                                           "État de la batterie au %s :\n",
+		"  Capacity: %d / %d (%d%%) %.1fkWh\n":                                 "  Capacité : %d / %d (%d%%) %.1fkWh\n",
+		"  Capacity: %d / %d (%d%%)\n":                                         "  Capacité : %d / %d (%d%%)\n",
+		"  Capacity: %.1fkWh\n":                                                "  Capacité : %.1fkWh\n",
+		"  Cruising range: %s (%s with AC)\n":                                  "  Autonomie : %s (%s avec climatisation)\n",
+		"  Plug-in state: %s\n":                                                "  Branchement : %s\n",
+		"  Charging status: %s\n":                                              "  État de charge : %s\n",
+		"  Charger: %s\n":                                                      "  Type de charge : %s\n",
+		"  Charger: %s (%dV)\n":                                                "  Type de charge : %s (%d V)\n",
+		"  Time to full:\n":                                                    "  Temps de charge complète :\n",
+		"    Level 1 charge: %s\n":                                             "    Charge niveau 1 : %s\n",
+		"    Level 2 charge: %s\n":                                             "    Charge niveau 2 : %s\n",
+		"    Level 2 at 6 kW: %s\n":                                            "    Niveau 2 à 6 kW : %s\n",
+		"    (no time-to-full estimates available)\n":                          "    (aucune estimation disponible)\n",
+		"Sending charging request...":                                          "Envoi de la demande de charge...",
+		"Charging request sent":                                                "Demande de charge envoyée",
+		"Sending charging stop request...":                                     "Envoi de la demande d'arrêt de charge...",
+		"Charging stop request sent":                                           "Demande d'arrêt de charge envoyée",
+		"Getting latest retrieved climate control status...":                   "Récupération du dernier état de la climatisation...",
+		"Climate status:\n":                                                    "État de la climatisation :\n",
+		"  Running: %s\n":                                                      "  En marche : %s\n",
+		"  Will stop at: %s\n":                                                 "  S'arrêtera à : %s\n",
+		"  Temperature setting: %d %s\n":                                       "  Température réglée : %d %s\n",
+		"Sending climate control off request...":                               "Envoi de la demande d'arrêt de la climatisation...",
+		"Waiting for climate control update to complete... ":                   "En attente de la climatisation... ",
+		"Climate control turned off":                                           "Climatisation arrêtée",
+		"Sending climate control on request...":                                "Envoi de la demande de mise en marche de la climatisation...",
+		"Climate control turned on":                                            "Climatisation en marche",
+		"Getting latest cabin temperature...":                                  "Récupération de la température de l'habitacle...",
+		"Getting charge counters...":                                           "Récupération des compteurs de charge...",
+		"Charge counters as of %s:\n":                                          "Compteurs de charge au %s :\n",
+		"  Quick charges: %d\n":                                                "  Charges rapides : %d\n",
+		"  Level 1 and 2 charges: %d\n":                                        "  Charges de niveau 1 et 2 : %d\n",
+		"Carwings is reachable and the session is valid.":                      "Carwings est joignable et la session est valide.",
+		"Waiting for cabin temperature request to complete... ":                "En attente de la température de l'habitacle... ",
+		"Cabin temperature: %d°\n":                                             "Température de l'habitacle : %d°\n",
+		"Sending monthly statistics request...":                                "Demande des statistiques mensuelles...",
+		"Monthly Driving Statistics for %s\n":                                  "Statistiques de conduite mensuelles pour %s\n",
+		"  Driving efficiency: %.4f %s over %s in %d trips\n":                  "  Efficacité : %.4f %s sur %s en %d trajets\n",
+		"  Driving cost: %.4f at a rate of %.4f/kWh for %.1f kWh => %.4f/%s\n": "  Coût : %.4f au tarif de %.4f/kWh pour %.1f kWh => %.4f/%s\n",
+		"  Trip cost: %.2f => %.4f/%s\n":                                       "  Coût des trajets : %.2f => %.4f/%s\n",
+		"  Trips on %s\n":                                                      "  Trajets du %s\n",
+		"Sending daily statistics request...":                                  "Demande des statistiques quotidiennes...",
+		"Daily Driving Statistics for %s\n":                                    "Statistiques de conduite quotidiennes pour %s\n",
+		"  Driving efficiency: %5.1f %-10.10s %-5.5s\n":                        "  Efficacité :       %5.1f %-10.10s %-5.5s\n",
+		"  Acceleration:     %7.1f %-10.10s %-5.5s\n":                          "  Accélération :   %7.1f %-10.10s %-5.5s\n",
+		"  Regeneration:     %7.1f %-10.10s %-5.5s\n":                          "  Régénération :   %7.1f %-10.10s %-5.5s\n",
+		"  Auxilliary usage: %7.1f %-10.10s %-5.5s\n":                          "  Auxiliaires :    %7.1f %-10.10s %-5.5s\n",
+		"yes":                        "oui",
+		"no":                         "non",
+		"not connected":              "non branché",
+		"connected":                  "branché",
+		"connected to quick charger": "branché sur une borne rapide",
+		"invalid":                    "invalide",
+		"not charging":               "pas en charge",
+		"charging":                   "en charge",
+		"rapidly charging":           "en charge rapide",
+		"level 1":                    "niveau 1",
+		"level 2":                    "niveau 2",
+		"quick charge":               "charge rapide",
+
+		"just now":       "à l'instant",
+		"a minute ago":   "il y a une minute",
+		"%d minutes ago": "il y a %d minutes",
+		"an hour ago":    "il y a une heure",
+		"%d hours ago":   "il y a %d heures",
+		"a day ago":      "il y a un jour",
+		"%d days ago":    "il y a %d jours",
+		"in a minute":    "dans une minute",
+		"in %d minutes":  "dans %d minutes",
+		"in an hour":     "dans une heure",
+		"in %d hours":    "dans %d heures",
+		"in a day":       "dans un jour",
+		"in %d days":     "dans %d jours",
+
+		"Charging complete":                          "Charge terminée",
+		"Charging stopped early":                     "Charge interrompue",
+		"Car plugged in":                             "Voiture branchée",
+		"Battery low":                                "Batterie faible",
+		"Charge level reached":                       "Niveau de charge atteint",
+		"Not ready for departure":                    "Pas prêt pour le départ",
+		"WARNING: %s not archived: %v\n":             "AVERTISSEMENT : %s non archivé : %v\n",
+		"Archived %d files to %s\n":                  "%d fichiers archivés dans %s\n",
+		"No driving history available":               "Aucun historique de conduite disponible",
+		"Retrieved %d trips in %d months since %s\n": "%d trajets récupérés sur %d mois depuis %s\n",
+		"Wrote %s\n":                                 "%s écrit\n",
+		"Exercising Carwings endpoints...":           "Appel des points de terminaison Carwings...",
+		"WARNING: %s failed: %v\n":                   "AVERTISSEMENT : échec de %s : %v\n",
+		"Wrote %d requests to %s.  Check it for anything private before attaching it to an issue.\n": "%d requêtes écrites dans %s.  Vérifiez qu'il ne contient rien de privé avant de le joindre à un ticket.\n",
+		"Range as of %s:\n":                                                 "Autonomie au %s :\n",
+		"  Vehicle estimate: %s (%s with AC)\n":                             "  Estimation du véhicule : %s (%s avec climatisation)\n",
+		"  Personalized estimate: %v\n":                                     "  Estimation personnalisée : %v\n",
+		"  Personalized estimate: %s (%s with AC) at %.1f %s\n":             "  Estimation personnalisée : %s (%s avec climatisation) à %.1f %s\n",
+		"Yes: %s is within range, even with AC.\n":                          "Oui : %s est à portée, même avec la climatisation.\n",
+		"No: %s is beyond the estimated range.\n":                           "Non : %s dépasse l'autonomie estimée.\n",
+		"Maybe: %s is within range only without AC or by some estimates.\n": "Peut-être : %s n'est à portée que sans climatisation ou selon certaines estimations.\n",
+		"Currently %d%%, %d%% by %s\n":                                      "Actuellement %d%%, %d%% d'ici %s\n",
+	},
+
+	"ja": {
+		"  Time to %d%%:\n":                                                    "  %d%% までの時間:\n",
+		"  Charging power: ~%.1f kW\n":                                         "  充電電力: 約 %.1f kW\n",
+		"Getting latest vehicle status...":                                     "最新の車両状態を取得しています...",
+		"Battery status unavailable: %v\n":                                     "バッテリー状態を取得できません: %v\n",
+		"Climate status unavailable: %v\n":                                     "エアコン状態を取得できません: %v\n",
+		"Cabin temperature unavailable: %v\n":                                  "車内温度を取得できません: %v\n",
+		"Location: %.5f, %.5f as of %s\n":                                      "位置: %.5f, %.5f (%s 時点)\n",
+		"Location unavailable: %v\n":                                           "位置を取得できません: %v\n",
+		"Done after %d attempts in %s":                                         "%d 回目の試行で完了 (%s)",
+		"done after %d attempts in %s":                                         "%d 回目の試行で完了 (%s)",
+		"failed":                                                               "失敗",
+		"[attempt %d, %s elapsed]":                                             "[試行 %d 回目, 経過 %s]",
+		"Sending monthly statistics requests...":                               "月間統計を要求しています...",
+		"Logging into Carwings...":                                             "Carwingsにログインしています...",
+		"Requesting update from Carwings...":                                   "Carwingsに更新を要求しています...",
+		"Waiting for update to complete... ":                                   "更新の完了を待っています... ",
+		"Getting latest retrieved battery status...":                           "最新のバッテリー状態を取得しています...",
+		"Battery status as of %s:\n":                                           "バッテリー状態 (%s 時点):\n",
+		"  Capacity: %d / %d (%d%%) %.1fkWh\n":                                 "  容量: %d / %d (%d%%) %.1fkWh\n",
+		"  Capacity: %d / %d (%d%%)\n":                                         "  容量: %d / %d (%d%%)\n",
+		"  Capacity: %.1fkWh\n":                                                "  容量: %.1fkWh\n",
+		"  Cruising range: %s (%s with AC)\n":                                  "  航続可能距離: %s (エアコン使用時 %s)\n",
+		"  Plug-in state: %s\n":                                                "  接続状態: %s\n",
+		"  Charging status: %s\n":                                              "  充電状態: %s\n",
+		"  Charger: %s\n":                                                      "  充電方式: %s\n",
+		"  Charger: %s (%dV)\n":                                                "  充電方式: %s (%dV)\n",
+		"  Time to full:\n":                                                    "  満充電までの時間:\n",
+		"    Level 1 charge: %s\n":                                             "    普通充電 (100V): %s\n",
+		"    Level 2 charge: %s\n":                                             "    普通充電 (200V): %s\n",
+		"    Level 2 at 6 kW: %s\n":                                            "    普通充電 (6kW): %s\n",
+		"    (no time-to-full estimates available)\n":                          "    (推定時間はありません)\n",
+		"Sending charging request...":                                          "充電要求を送信しています...",
+		"Charging request sent":                                                "充電要求を送信しました",
+		"Sending charging stop request...":                                     "充電停止要求を送信しています...",
+		"Charging stop request sent":                                           "充電停止要求を送信しました",
+		"Getting latest retrieved climate control status...":                   "最新のエアコン状態を取得しています...",
+		"Climate status:\n":                                                    "エアコン状態:\n",
+		"  Running: %s\n":                                                      "  作動中: %s\n",
+		"  Will stop at: %s\n":                                                 "  停止予定: %s\n",
+		"  Temperature setting: %d %s\n":                                       "  設定温度: %d %s\n",
+		"Sending climate control off request...":                               "エアコン停止要求を送信しています...",
+		"Waiting for climate control update to complete... ":                   "エアコンの応答を待っています... ",
+		"Climate control turned off":                                           "エアコンを停止しました",
+		"Sending climate control on request...":                                "エアコン作動要求を送信しています...",
+		"Climate control turned on":                                            "エアコンを作動しました",
+		"Getting latest cabin temperature...":                                  "車内温度を取得しています...",
+		"Getting charge counters...":                                           "充電回数を取得しています...",
+		"Charge counters as of %s:\n":                                          "%s 時点の充電回数:\n",
+		"  Quick charges: %d\n":                                                "  急速充電: %d回\n",
+		"  Level 1 and 2 charges: %d\n":                                        "  普通充電 (レベル1・2): %d回\n",
+		"Carwings is reachable and the session is valid.":                      "Carwingsに接続でき、セッションは有効です。",
+		"Waiting for cabin temperature request to complete... ":                "車内温度の応答を待っています... ",
+		"Cabin temperature: %d°\n":                                             "車内温度: %d°\n",
+		"Sending monthly statistics request...":                                "月間統計を要求しています...",
+		"Monthly Driving Statistics for %s\n":                                  "%s の月間走行統計\n",
+		"  Driving efficiency: %.4f %s over %s in %d trips\n":                  "  電費: %.4f %s (%s, %d 回の走行)\n",
+		"  Driving cost: %.4f at a rate of %.4f/kWh for %.1f kWh => %.4f/%s\n": "  電気代: %.4f (単価 %.4f/kWh, %.1f kWh) => %.4f/%s\n",
+		"  Trip cost: %.2f => %.4f/%s\n":                                       "  走行ごとの電気代合計: %.2f => %.4f/%s\n",
+		"  Trips on %s\n":                                                      "  %s の走行\n",
+		"Sending daily statistics request...":                                  "日間統計を要求しています...",
+		"Daily Driving Statistics for %s\n":                                    "%s の日間走行統計\n",
+		"  Driving efficiency: %5.1f %-10.10s %-5.5s\n":                        "  電費:       %5.1f %-10.10s %-5.5s\n",
+		"  Acceleration:     %7.1f %-10.10s %-5.5s\n":                          "  加速:     %7.1f %-10.10s %-5.5s\n",
+		"  Regeneration:     %7.1f %-10.10s %-5.5s\n":                          "  回生:     %7.1f %-10.10s %-5.5s\n",
+		"  Auxilliary usage: %7.1f %-10.10s %-5.5s\n":                          "  補機消費: %7.1f %-10.10s %-5.5s\n",
+		"yes":                        "はい",
+		"no":                         "いいえ",
+		"not connected":              "未接続",
+		"connected":                  "接続中",
+		"connected to quick charger": "急速充電器に接続中",
+		"invalid":                    "無効",
+		"not charging":               "充電していません",
+		"charging":                   "充電中",
+		"rapidly charging":           "急速充電中",
+		"level 1":                    "レベル1",
+		"level 2":                    "レベル2",
+		"quick charge":               "急速充電",
+
+		"just now":       "たった今",
+		"a minute ago":   "1分前",
+		"%d minutes ago": "%d分前",
+		"an hour ago":    "1時間前",
+		"%d hours ago":   "%d時間前",
+		"a day ago":      "1日前",
+		"%d days ago":    "%d日前",
+		"in a minute":    "1分後",
+		"in %d minutes":  "%d分後",
+		"in an hour":     "1時間後",
+		"in %d hours":    "%d時間後",
+		"in a day":       "1日後",
+		"in %d days":     "%d日後",
+
+		"Charging complete":                          "充電完了",
+		"Charging stopped early":                     "充電が途中で停止しました",
+		"Car plugged in":                             "充電プラグ接続",
+		"Battery low":                                "バッテリー残量低下",
+		"Charge level reached":                       "充電レベルに到達しました",
+		"Not ready for departure":                    "出発の準備ができていません",
+		"WARNING: %s not archived: %v\n":             "警告: %s はアーカイブされませんでした: %v\n",
+		"Archived %d files to %s\n":                  "%d 個のファイルを %s にアーカイブしました\n",
+		"No driving history available":               "走行履歴はありません",
+		"Retrieved %d trips in %d months since %s\n": "%d 件の走行を %d か月分取得しました（%s 以降）\n",
+		"Wrote %s\n":                                 "%s に書き込みました\n",
+		"Exercising Carwings endpoints...":           "Carwings のエンドポイントを呼び出しています...",
+		"WARNING: %s failed: %v\n":                   "警告: %s に失敗しました: %v\n",
+		"Wrote %d requests to %s.  Check it for anything private before attaching it to an issue.\n": "%d 件のリクエストを %s に書き込みました。issue に添付する前に、個人情報が含まれていないか確認してください。\n",
+		"Range as of %s:\n":                                                 "航続可能距離 (%s 時点):\n",
+		"  Vehicle estimate: %s (%s with AC)\n":                             "  車両の推定: %s (エアコン使用時 %s)\n",
+		"  Personalized estimate: %v\n":                                     "  個人向け推定: %v\n",
+		"  Personalized estimate: %s (%s with AC) at %.1f %s\n":             "  個人向け推定: %s (エアコン使用時 %s)、%.1f %s\n",
+		"Yes: %s is within range, even with AC.\n":                          "はい: %s はエアコン使用時でも航続可能距離内です。\n",
+		"No: %s is beyond the estimated range.\n":                           "いいえ: %s は推定航続可能距離を超えています。\n",
+		"Maybe: %s is within range only without AC or by some estimates.\n": "おそらく: %s はエアコン不使用時か一部の推定でのみ航続可能距離内です。\n",
+		"Currently %d%%, %d%% by %s\n":                                      "現在 %d%%、%[3]s までに %[2]d%%\n",
+	},
+}
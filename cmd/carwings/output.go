@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// plain indicates that output should be suitable for logs: progress
+// messages are printed on a single line with a timestamp, and
+// decorations like progress indicators and smiley faces are omitted.
+var plain bool
+
+// jsonOutput indicates that results are printed to stdout as JSON
+// Lines, one object per line, so that they can be piped into other
+// tools.  Progress and log messages go to stderr instead.
+var jsonOutput bool
+
+// stdoutMu serializes JSON lines written from multiple goroutines.
+var stdoutMu sync.Mutex
+
+// logOutput returns where progress and log messages are printed.
+func logOutput() io.Writer {
+	if jsonOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// printJSON prints v to stdout as a single line of JSON.
+func printJSON(v interface{}) error {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// progressMsg is the message of the operation currently in progress.
+var progressMsg string
+
+// isTerminal returns whether the log output is a terminal, where the progress
+// indicator can be redrawn in place.
+func isTerminal() bool {
+	f, ok := logOutput().(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progress prints a message about what the command is doing.
+func progress(msg string) {
+	if plain {
+		fmt.Fprintf(logOutput(), "%s %s\n", time.Now().Format(time.RFC3339), strings.TrimSpace(msg))
+		return
+	}
+	fmt.Fprintln(logOutput(), msg)
+}
+
+// progressStart prints a message about an operation whose outcome
+// will be printed on the same line with progressEnd.
+func progressStart(msg string) {
+	progressMsg = msg
+	if plain {
+		progress(msg)
+		return
+	}
+	fmt.Fprint(logOutput(), msg)
+}
+
+// progressTick updates the progress indicator of the operation.  It is
+// only drawn on terminals, since it redraws the current line.
+func progressTick(attempt int, elapsed time.Duration) {
+	if plain || !isTerminal() {
+		return
+	}
+	fmt.Fprintf(logOutput(), "\r%s"+tr("[attempt %d, %s elapsed]")+"\x1b[K", progressMsg, attempt, elapsed.Round(time.Second))
+}
+
+// progressEnd finishes the line started by progressStart.  Errors are
+// reported by the caller.
+func progressEnd(err error, attempts int, elapsed time.Duration) {
+	elapsed = elapsed.Round(time.Second)
+
+	switch {
+	case plain && err == nil:
+		progress(fmt.Sprintf(tr("Done after %d attempts in %s"), attempts, elapsed))
+	case plain:
+	default:
+		if isTerminal() {
+			fmt.Fprintf(logOutput(), "\r%s\x1b[K", progressMsg)
+		}
+		if err == nil {
+			fmt.Fprintf(logOutput(), tr("done after %d attempts in %s")+" :-)\n", attempts, elapsed)
+		} else {
+			fmt.Fprintln(logOutput(), tr("failed")+" :-(")
+		}
+	}
+}
+
+// timeFormatRelative is the -time-format that prints times relative to
+// now, like "2 hours ago".
+const timeFormatRelative = "relative"
+
+// timeFormat is the -time-format layout for printing timestamps, or
+// timeFormatRelative.
+var timeFormat string
+
+// formatTime formats t for display as set by -time-format, or with
+// layout if it isn't set, or like time.Time's String method if layout
+// is empty too.
+func formatTime(t time.Time, layout string) string {
+	switch {
+	case timeFormat == timeFormatRelative:
+		return relativeTime(time.Now(), t)
+	case timeFormat != "":
+		return t.Format(timeFormat)
+	case layout != "":
+		return t.Format(layout)
+	default:
+		return t.String()
+	}
+}
+
+// relativeTime describes t relative to now, in the largest whole unit.
+func relativeTime(now, t time.Time) string {
+	d := now.Sub(t)
+	past := d >= 0
+	if !past {
+		d = -d
+	}
+
+	var n int
+	var one, many string
+	switch {
+	case d < time.Minute:
+		return tr("just now")
+	case d < time.Hour:
+		n = int(d / time.Minute)
+		one, many = "a minute ago", "%d minutes ago"
+		if !past {
+			one, many = "in a minute", "in %d minutes"
+		}
+	case d < 24*time.Hour:
+		n = int(d / time.Hour)
+		one, many = "an hour ago", "%d hours ago"
+		if !past {
+			one, many = "in an hour", "in %d hours"
+		}
+	default:
+		n = int(d / (24 * time.Hour))
+		one, many = "a day ago", "%d days ago"
+		if !past {
+			one, many = "in a day", "in %d days"
+		}
+	}
+
+	if n == 1 {
+		return tr(one)
+	}
+	return fmt.Sprintf(tr(many), n)
+}
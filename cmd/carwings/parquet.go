@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Parquet physical and converted types used for trip columns
+const (
+	parquetInt64  = 2
+	parquetDouble = 5
+
+	parquetTimestampMillis = 9
+)
+
+// parquetColumn is a required column of either int64 or float64
+// values.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32 // 0 for none
+	ints      []int64
+	floats    []float64
+}
+
+// writeParquet writes the columns, which must all have the same number
+// of values, as a Parquet file with a single row group.  Values are
+// PLAIN encoded and uncompressed, which every reader supports.
+func writeParquet(w io.Writer, rows int, columns []parquetColumn) error {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(columns))
+
+	for i, c := range columns {
+		var data bytes.Buffer
+		for _, v := range c.ints {
+			binary.Write(&data, binary.LittleEndian, v)
+		}
+		for _, v := range c.floats {
+			binary.Write(&data, binary.LittleEndian, math.Float64bits(v))
+		}
+
+		var header thriftWriter
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(data.Len()))
+		header.i32(3, int32(data.Len()))
+		header.structBegin(5)
+		header.i32(1, int32(rows))
+		header.i32(2, 0) // PLAIN
+		header.i32(3, 3) // RLE
+		header.i32(4, 3) // RLE
+		header.structEnd()
+		header.stop()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.Len() + data.Len())}
+		file.Write(header.Bytes())
+		file.Write(data.Bytes())
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.listBegin(2, thriftStruct, len(columns)+1)
+	meta.elemBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.structEnd()
+	for _, c := range columns {
+		meta.elemBegin()
+		meta.i32(1, c.typ)
+		meta.i32(3, 0) // REQUIRED
+		meta.binary(4, c.name)
+		if c.converted != 0 {
+			meta.i32(6, c.converted)
+		}
+		meta.structEnd()
+	}
+	meta.i64(3, int64(rows))
+
+	var total int64
+	for _, ch := range chunks {
+		total += ch.size
+	}
+
+	meta.listBegin(4, thriftStruct, 1)
+	meta.elemBegin()
+	meta.listBegin(1, thriftStruct, len(columns))
+	for i, c := range columns {
+		meta.elemBegin()
+		meta.i64(2, chunks[i].offset)
+		meta.structBegin(3)
+		meta.i32(1, c.typ)
+		meta.listBegin(2, thriftI32, 2)
+		meta.varint(0) // PLAIN
+		meta.varint(3) // RLE
+		meta.listBegin(3, thriftBinary, 1)
+		meta.rawBinary(c.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(rows))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.structEnd()
+		meta.structEnd()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.structEnd()
+	meta.binary(6, "carwings")
+	meta.stop()
+
+	file.Write(meta.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.Len()))
+	file.WriteString("PAR1")
+
+	_, err := file.WriteTo(w)
+	return err
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes structs in the Thrift compact protocol, which is
+// how Parquet encodes its metadata.  It keeps track of the last field
+// ID in each nested struct, as field headers hold the difference.
+type thriftWriter struct {
+	bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) varint(v int64) {
+	// Signed values are zigzag encoded
+	u := uint64((v << 1) ^ (v >> 63))
+	var buf [binary.MaxVarintLen64]byte
+	t.Write(buf[:binary.PutUvarint(buf[:], u)])
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftWriter) rawBinary(s string) {
+	var buf [binary.MaxVarintLen64]byte
+	t.Write(buf[:binary.PutUvarint(buf[:], uint64(len(s)))])
+	t.WriteString(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.WriteByte(0xf0 | elem)
+		var buf [binary.MaxVarintLen64]byte
+		t.Write(buf[:binary.PutUvarint(buf[:], uint64(n))])
+	}
+}
+
+// structBegin starts a struct field; elemBegin starts a struct that
+// is an element of a list.
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop ends the outermost struct.
+func (t *thriftWriter) stop() {
+	t.WriteByte(0)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joeshaw/carwings"
+	"github.com/joeshaw/carwings/httpd"
+)
+
+const (
+	// planMargin is added to the predicted charging time, since
+	// charging slows as the battery fills.
+	planMargin = 30 * time.Minute
+
+	// planRecheck is how often the plan is revisited, to pick up
+	// changes to the state of charge and whether the car is plugged
+	// in.
+	planRecheck = 30 * time.Minute
+)
+
+// nextDeparture returns the first departure after t, or the zero time
+// if there are none.
+func nextDeparture(ds departures, t time.Time) time.Time {
+	var next time.Time
+	for _, d := range ds {
+		_, departs := d.next(t, 0)
+		if !departs.IsZero() && (next.IsZero() || departs.Before(next)) {
+			next = departs
+		}
+	}
+	return next
+}
+
+// planCharging starts charging the default vehicle of h in time to
+// reach the charge target by each departure, until ctx is done.  The
+// start time is predicted from the charging history and the vehicle's
+// time to full estimates for the charger level.  Charging is only
+// started if the vehicle is plugged in and not already charging.
+func planCharging(ctx context.Context, h *httpd.Server, cfg config, level carwings.ChargerLevel) {
+	var planned, startedFor time.Time
+
+	for {
+		wait := planRecheck
+
+		now := time.Now()
+		departs := nextDeparture(cfg.departures, now)
+		if departs.IsZero() {
+			return
+		}
+
+		bs, err := h.Session().BatteryStatusContext(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+
+		case err != nil:
+			fmt.Fprintf(logOutput(), "Error planning charging: %s\n", err)
+
+		case startedFor.Equal(departs), !isPluggedIn(bs), isCharging(bs), bs.StateOfCharge >= cfg.chargeTarget:
+			// Nothing to do for now
+
+		default:
+			p, err := newPredictor(cfg, bs, level)
+			if err != nil {
+				fmt.Fprintf(logOutput(), "Error planning charging: %s\n", err)
+				break
+			}
+
+			start, err := p.LatestStart(cfg.chargeTarget, departs)
+			if err != nil {
+				fmt.Fprintf(logOutput(), "Error planning charging: %s\n", err)
+				break
+			}
+			start = start.Add(-planMargin)
+
+			if now.Before(start) {
+				if !start.Equal(planned) {
+					fmt.Fprintf(logOutput(), "Planning to start charging at %s, to reach %d%% by %s\n",
+						start.Format("Mon 15:04"), cfg.chargeTarget, departs.Format("Mon 15:04"))
+					planned = start
+				}
+				if d := time.Until(start); d < wait {
+					wait = d
+				}
+				break
+			}
+
+			fmt.Fprintf(logOutput(), "Starting charging, to reach %d%% by %s\n", cfg.chargeTarget, departs.Format("Mon 15:04"))
+			err = h.Command(ctx, "", "Charging request", func(ctx context.Context, s *carwings.Session) error {
+				return s.ChargingRequestContext(ctx)
+			})
+			if err != nil {
+				fmt.Fprintf(logOutput(), "Error starting charging: %s\n", err)
+				break
+			}
+			startedFor = departs
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/joeshaw/carwings"
+)
+
+// pluginPrefix is the prefix of the executables on the PATH that are
+// run for commands carwings doesn't have itself.
+const pluginPrefix = "carwings-"
+
+// findPlugin returns the path of the executable for the command, or ""
+// if there isn't one.
+func findPlugin(cmd string) string {
+	if cmd == "" || strings.ContainsAny(cmd, `/\`) {
+		return ""
+	}
+	path, err := exec.LookPath(pluginPrefix + cmd)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// runPlugin returns a command that runs the plugin at path with its
+// arguments, once logged in.  The global flags are passed in the
+// environment the same way carwings reads them, such as
+// CARWINGS_USERNAME and CARWINGS_SESSION_FILE, so the plugin can
+// reuse the session.  -profile isn't passed, so the credentials of
+// other accounts stay out of it, and neither is -password-file, as
+// the password it held is passed instead.
+func runPlugin(path string, fs *flag.FlagSet) func(context.Context, *carwings.Session, config, []string) error {
+	return func(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+		env := os.Environ()
+		fs.VisitAll(func(f *flag.Flag) {
+			if f.Name == "profile" || f.Name == "password-file" {
+				return
+			}
+			// Expand paths like ~/.carwings-session, as not
+			// every plugin will know to
+			v := f.Value.String()
+			if strings.HasPrefix(v, "~/") {
+				v = os.Getenv("HOME") + v[1:]
+			}
+			env = append(env, envVar(f.Name)+"="+v)
+		})
+
+		// The vehicle actually in use, rather than -vin, which may
+		// be empty
+		env = append(env, envVar("vin")+"="+s.VIN)
+
+		cmd := exec.CommandContext(ctx, path, args...)
+		cmd.Env = env
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+		err := cmd.Run()
+		var xerr *exec.ExitError
+		if errors.As(err, &xerr) && xerr.ExitCode() > 0 {
+			return exitCode(xerr.ExitCode())
+		}
+		return err
+	}
+}
+
+// envVar returns the environment variable for the flag, as ff reads
+// them: -session-file is CARWINGS_SESSION_FILE.
+func envVar(flag string) string {
+	return "CARWINGS_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
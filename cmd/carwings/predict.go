@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// parseChargerLevel parses a -level flag value: 1, 2 or 6kw.
+func parseChargerLevel(s string) (carwings.ChargerLevel, error) {
+	switch strings.ToLower(s) {
+	case "1", "l1":
+		return carwings.Level1, nil
+	case "2", "l2":
+		return carwings.Level2, nil
+	case "6kw", "6", "l2-6kw":
+		return carwings.Level2At6kW, nil
+	default:
+		return 0, fmt.Errorf("unsupported charger level (%q) -- must be 1, 2 or 6kw", s)
+	}
+}
+
+// newPredictor returns a Predictor using the latest battery status and
+// the last day of history.
+func newPredictor(cfg config, bs carwings.BatteryStatus, level carwings.ChargerLevel) (*carwings.Predictor, error) {
+	statuses, err := cfg.history.batteryHistory(bs.Timestamp.Add(-24 * time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	return &carwings.Predictor{
+		Samples:    socSamples(append(statuses, bs)),
+		Level:      level,
+		TimeToFull: bs.TimeToFull,
+	}, nil
+}
+
+func runPredict(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("predict")
+	target := fs.Int("target", cfg.chargeTarget, "target state of charge, in percent. Defaults to -charge-target.")
+	levelName := fs.String("level", "2", "charger level: 1, 2 or 6kw")
+	fs.Parse(args)
+
+	level, err := parseChargerLevel(*levelName)
+	if err != nil {
+		return err
+	}
+
+	progress(tr("Getting latest retrieved battery status..."))
+
+	bs, err := s.BatteryStatusContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cfg.history.addBattery(bs); err != nil {
+		return err
+	}
+
+	p, err := newPredictor(cfg, bs, level)
+	if err != nil {
+		return err
+	}
+
+	t, err := p.Predict(*target)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("Currently %d%%, %d%% by %s\n"), bs.StateOfCharge, *target, formatTime(t.Local(), "Mon 15:04"))
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// recentMonths returns the current month and the n-1 months before it.
+func recentMonths(n int) []time.Time {
+	now := monthOf(time.Now())
+	return monthsBetween(now.AddDate(0, -(n-1), 0), now)
+}
+
+func runRange(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("range")
+	months := fs.Int("months", 3, "number of recent months of driving to base the estimate on")
+	to := fs.Float64("to", 0, "distance of a trip, in -units, to check against the range. Exits with status 2 if it's out of range, or 3 if it's only in range without AC or by some estimates.")
+	fs.Parse(args)
+
+	if *months < 1 {
+		return fmt.Errorf("-months must be at least 1")
+	}
+
+	progress(tr("Getting latest retrieved battery status..."))
+
+	var (
+		bs    carwings.BatteryStatus
+		stats = make([]carwings.MonthlyStatistics, *months)
+		fns   []func(context.Context) error
+	)
+	fns = append(fns, func(ctx context.Context) (err error) {
+		bs, err = s.BatteryStatusContext(ctx)
+		return err
+	})
+	for i, month := range recentMonths(*months) {
+		i, month := i, month
+		fns = append(fns, func(ctx context.Context) (err error) {
+			stats[i], err = s.GetMonthlyStatisticsContext(ctx, month)
+			return err
+		})
+	}
+
+	for _, err := range parallel(ctx, 3, fns...) {
+		if err != nil {
+			return err
+		}
+	}
+	if err := cfg.history.addBattery(bs); err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("Range as of %s:\n"), formatTime(bs.Timestamp, ""))
+	fmt.Printf(tr("  Vehicle estimate: %s (%s with AC)\n"),
+		prettyUnits(cfg.units, bs.CruisingRangeACOff), prettyUnits(cfg.units, bs.CruisingRangeACOn))
+
+	ranges := []int{bs.CruisingRangeACOff, bs.CruisingRangeACOn}
+
+	est, err := carwings.EstimateRange(bs, stats...)
+	if err != nil {
+		fmt.Printf(tr("  Personalized estimate: %v\n"), err)
+	} else {
+		fmt.Printf(tr("  Personalized estimate: %s (%s with AC) at %.1f %s\n"),
+			prettyUnits(cfg.units, est.ACOff), prettyUnits(cfg.units, est.ACOn),
+			efficiencyToUnits(unitskWhPerKm, cfg.effunits, est.WhPerKm/1000), cfg.effunits)
+		ranges = append(ranges, est.ACOff, est.ACOn)
+	}
+	fmt.Println()
+
+	if *to > 0 {
+		return rangeVerdict(cfg.units, *to, ranges)
+	}
+
+	return nil
+}
+
+// rangeVerdict prints whether a trip of the given distance, in units,
+// is within the range estimates, in meters.  It returns exitCode(2) if
+// the trip is beyond every estimate, and exitCode(3) if it's beyond
+// only some of them, like those with AC on.
+func rangeVerdict(units string, distance float64, ranges []int) error {
+	meters := distance * 1000
+	if units == unitsMiles {
+		const metersPerMile = 1609.344
+		meters = distance * metersPerMile
+	}
+
+	within := 0
+	for _, r := range ranges {
+		if float64(r) >= meters {
+			within++
+		}
+	}
+
+	trip := fmt.Sprintf("%g %s", distance, units)
+
+	switch within {
+	case len(ranges):
+		fmt.Printf(tr("Yes: %s is within range, even with AC.\n"), trip)
+		return nil
+	case 0:
+		fmt.Printf(tr("No: %s is beyond the estimated range.\n"), trip)
+		return exitCode(2)
+	default:
+		fmt.Printf(tr("Maybe: %s is within range only without AC or by some estimates.\n"), trip)
+		return exitCode(3)
+	}
+}
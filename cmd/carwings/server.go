@@ -2,88 +2,495 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/joeshaw/carwings"
+	"github.com/joeshaw/carwings/httpd"
 )
 
-func updateLoop(ctx context.Context, s *carwings.Session, interval time.Duration) {
-	_, err := s.UpdateStatus()
+// quietHours is a flag.Value for httpd.QuietHours in HH:MM-HH:MM
+// format.
+type quietHours httpd.QuietHours
+
+var _ flag.Value = (*quietHours)(nil)
+
+func (q *quietHours) String() string {
+	if q == nil || q.Start == q.End {
+		return ""
+	}
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(q.Start) + "-" + clock(q.End)
+}
+
+func (q *quietHours) Set(value string) error {
+	start, end, err := parseClockRange(value)
 	if err != nil {
-		fmt.Printf("Error updating status: %s\n", err)
+		return err
+	}
+	q.Start, q.End = start, end
+	return nil
+}
+
+// profile is a Carwings account, other than the one given with
+// -username and -password, whose vehicles the server serves under
+// /<name>/.
+type profile struct {
+	name, username, password, region string
+}
+
+// profiles is a flag.Value that collects profiles from repeated
+// -profile flags or config file lines.
+type profiles []profile
+
+var _ flag.Value = (*profiles)(nil)
+
+// reservedProfileNames would shadow the server's own endpoints.
+var reservedProfileNames = map[string]bool{
+	"admin":       true,
+	"alexa":       true,
+	"battery":     true,
+	"charging":    true,
+	"climate":     true,
+	"google-home": true,
+	"ha":          true,
+	"healthz":     true,
+	"jobs":        true,
+	"metrics":     true,
+	"predict":     true,
+	"range":       true,
+	"readyz":      true,
+	"slack":       true,
+	"stats":       true,
+	"status":      true,
+	"trips":       true,
+	"update":      true,
+	"vehicles":    true,
+}
+
+func (p *profiles) String() string {
+	if p == nil {
+		return ""
+	}
+	s := make([]string, len(*p))
+	for i, prof := range *p {
+		s[i] = prof.name
+	}
+	return strings.Join(s, ", ")
+}
+
+func (p *profiles) Set(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) != 3 && len(fields) != 4 {
+		return fmt.Errorf("profile must be in the format \"<name> <username> <password> [region]\"")
+	}
+
+	prof := profile{name: fields[0], username: fields[1], password: fields[2]}
+	if len(fields) == 4 {
+		prof.region = fields[3]
+	}
+
+	if reservedProfileNames[prof.name] || strings.Contains(prof.name, "/") {
+		return fmt.Errorf("invalid profile name %q", prof.name)
+	}
+	for _, other := range *p {
+		if other.name == prof.name {
+			return fmt.Errorf("duplicate profile name %q", prof.name)
+		}
+	}
+
+	*p = append(*p, prof)
+	return nil
+}
+
+// connectAccount connects a Session for every vehicle on the
+// profile's account.  If s is not nil, it is an already connected
+// Session for one of them.  With -relogin-interval, the Sessions keep
+// logging in again until ctx is done.
+func connectAccount(ctx context.Context, cfg config, prof profile, s *carwings.Session) ([]*carwings.Session, error) {
+	if prof.region == "" {
+		prof.region = cfg.region
+	}
+
+	connect := func(vin string) (*carwings.Session, error) {
+		opts := append(cfg.sessionOptions(), carwings.WithRegion(prof.region), carwings.WithVIN(vin))
+		if cfg.sessionFile != "" {
+			if vin != "" {
+				opts = append(opts, carwings.WithStore(carwings.FileStore(cfg.sessionFile+"-"+vin)))
+			} else {
+				opts = append(opts, carwings.WithStore(carwings.FileStore(cfg.sessionFile+"-"+prof.name)))
+			}
+		}
+		s, err := carwings.NewSessionContext(ctx, prof.username, prof.password, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to account %s: %w", prof.username, err)
+		}
+		return s, nil
+	}
+
+	if s == nil {
+		var err error
+		if s, err = connect(""); err != nil {
+			return nil, err
+		}
+	}
+
+	sessions := []*carwings.Session{s}
+	for _, vin := range s.Vehicles() {
+		if vin == s.VIN {
+			continue
+		}
+		vs, err := connect(vin)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, vs)
+	}
+
+	if cfg.reloginInterval > 0 {
+		for _, vs := range sessions {
+			go vs.KeepLoggedIn(ctx, cfg.reloginInterval)
+		}
+	}
+
+	return sessions, nil
+}
+
+// serverState is the configuration of a running server, which may be
+// reloaded.
+type serverState struct {
+	vin string          // of the vehicle the history is kept for
+	ctx context.Context // of the server, which the notifiers run under
+
+	mu         sync.Mutex
+	cfg        config
+	servers    map[string]*httpd.Server // by profile name; "" is the default
+	homebridge *homebridge
+	triggers   *triggers
+	stop       context.CancelFunc // stops the homebridge and triggers
+}
+
+func (st *serverState) config() config {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.cfg
+}
+
+// setNotifiers replaces the homebridge and triggers, and the departure
+// reminders, with ones for cfg, and stops the old ones.  Triggers pick
+// up where the old ones left off, so changing them doesn't repeat or
+// lose alerts.
+func (st *serverState) setNotifiers(cfg config) error {
+	ctx, stop := context.WithCancel(st.ctx)
+	hb := newHomebridge(ctx, cfg, st.vin)
+	trig, err := newTriggers(ctx, cfg)
+	if err != nil {
+		stop()
+		return err
+	}
+
+	st.mu.Lock()
+	old, oldStop := st.triggers, st.stop
+	st.homebridge, st.triggers, st.stop = hb, trig, stop
+	st.mu.Unlock()
+
+	if oldStop != nil {
+		oldStop()
+	}
+	if trig != nil {
+		if old != nil {
+			old.mu.Lock()
+			for vin, bs := range old.last {
+				trig.last[vin] = bs
+			}
+			for th, armed := range old.armed {
+				trig.armed[th] = armed
+			}
+			old.mu.Unlock()
+		}
+		go trig.runDepartures(ctx, cfg.departures, cfg.departureLead)
+	}
+	return nil
+}
+
+// options returns the httpd options for the named profile under the
+// current configuration.
+func (st *serverState) options(name string) httpd.Options {
+	st.mu.Lock()
+	cfg, hb, trig := st.cfg, st.homebridge, st.triggers
+	st.mu.Unlock()
+
+	opts := httpd.Options{
+		UpdateInterval:       cfg.serverUpdateInterval,
+		ActiveUpdateInterval: cfg.serverActiveInterval,
+		IdleUpdateInterval:   cfg.serverIdleInterval,
+		ReadyTimeout:         cfg.serverReadyTimeout,
+		JobRetention:         cfg.serverJobRetention,
+		QuietHours:           httpd.QuietHours(cfg.quietHours),
+		UpdateTimeout:        cfg.timeout,
+		Units:                cfg.units,
+		AlexaToken:           cfg.alexaToken,
+		GoogleHomeToken:      cfg.googleHomeToken,
+		AdminToken:           cfg.adminToken,
+		SlackSigningSecret:   cfg.slackSigningSecret,
+		Logger:               log.New(logOutput(), "", 0),
+		Upstream:             cfg.upstream,
+	}
+
+	if name != "" {
+		opts.Logger = log.New(logOutput(), name+": ", 0)
+	}
+
+	// The history is kept for the vehicle given on the command line,
+	// which is also the one served at the top level.
+	opts.OnBatteryStatus = func(vin string, bs carwings.BatteryStatus) {
+		if jsonOutput {
+			printJSON(batteryEvent{Time: time.Now(), VIN: vin, Battery: &bs})
+		}
+		if hb != nil && name == "" {
+			hb.batteryStatus(vin, bs)
+		}
+		if trig != nil && name == "" {
+			trig.batteryStatus(vin, bs)
+		}
+		if name != "" || vin != st.vin {
+			return
+		}
+		if err := st.config().history.addBattery(bs); err != nil {
+			fmt.Fprintf(logOutput(), "Error saving battery history: %s\n", err)
+		}
+	}
+
+	if hb != nil && name == "" {
+		opts.OnClimateStatus = hb.climateStatus
+	}
+
+	return opts
+}
+
+// reload rereads the configuration and applies it to the servers and
+// notifiers.
+// Accounts and the listen address can't be changed without a restart.
+func (st *serverState) reload() error {
+	var next config
+	fs := newFlagSet(&next, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := parseFlags(fs); err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	cur := st.cfg
+	st.mu.Unlock()
+
+	// Standard input can only be read once, at startup
+	if next.password == "-" {
+		next.password = cur.password
+	} else if err := next.readPassword(nil); err != nil {
+		return err
+	}
+
+	if next.username != cur.username || next.password != cur.password || next.region != cur.region ||
+		next.vin != cur.vin || next.serverAddr != cur.serverAddr || next.basePath != cur.basePath || next.pprofAddr != cur.pprofAddr || fmt.Sprint(next.profiles) != fmt.Sprint(cur.profiles) {
+		fmt.Fprintf(logOutput(), "Account, profile and address changes will take effect after a restart\n")
+	}
+	next.username, next.password, next.region = cur.username, cur.password, cur.region
+	next.vin, next.serverAddr, next.profiles = cur.vin, cur.serverAddr, cur.profiles
+	next.basePath, next.pprofAddr = cur.basePath, cur.pprofAddr
+
+	next.setDefaultUnits()
+	if next.units != unitsMiles && next.units != unitsKM {
+		return fmt.Errorf("unsupported units (%q) -- must be miles or km", next.units)
+	}
+
+	next.history, next.upstream = cur.history, cur.upstream
+	if next.historyDir != cur.historyDir {
+		h, err := openHistory(next.historyDir)
+		if err != nil {
+			return err
+		}
+		next.history = h
+	}
+
+	if err := st.setNotifiers(next); err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	st.cfg = next
+	servers := st.servers
+	st.mu.Unlock()
+
+	for name, srv := range servers {
+		srv.SetOptions(st.options(name))
+	}
+
+	return nil
+}
+
+// togglePaused pauses the updates of every server, or resumes them if
+// they're all paused already.
+func (st *serverState) togglePaused() {
+	st.mu.Lock()
+	servers := st.servers
+	st.mu.Unlock()
+
+	paused := true
+	for _, srv := range servers {
+		paused = paused && srv.Paused()
 	}
 
-	t := time.NewTicker(interval)
-	defer t.Stop()
+	if paused {
+		fmt.Fprintf(logOutput(), "Resuming updates\n")
+	} else {
+		fmt.Fprintf(logOutput(), "Pausing updates\n")
+	}
+	for _, srv := range servers {
+		srv.SetPaused(!paused)
+	}
+}
+
+// handleSignals reloads the configuration on SIGHUP and pauses or
+// resumes updates on SIGUSR1, until ctx is done.
+func (st *serverState) handleSignals(ctx context.Context) {
+	hup, pause := make(chan os.Signal, 1), make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	if len(pauseSignals) > 0 {
+		signal.Notify(pause, pauseSignals...)
+		defer signal.Stop(pause)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
-		case <-t.C:
-			_, err := s.UpdateStatus()
-			if err != nil {
-				fmt.Printf("Error updating status: %s\n", err)
+		case <-hup:
+			fmt.Fprintf(logOutput(), "Reloading configuration\n")
+			if err := st.reload(); err != nil {
+				fmt.Fprintf(logOutput(), "Error reloading configuration: %s\n", err)
 			}
+
+		case <-pause:
+			st.togglePaused()
 		}
 	}
 }
 
-func runServer(s *carwings.Session, cfg config, args []string) error {
-	var srv http.Server
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+func runServer(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	commandFlags("server").Parse(args)
 
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	var srv http.Server
 
 	go func() {
-		<-ch
-		cancel()
+		<-ctx.Done()
 		srv.Shutdown(context.Background())
 	}()
 
-	if cfg.serverUpdateInterval > 0 {
-		go updateLoop(ctx, s, cfg.serverUpdateInterval)
+	if cfg.pprofAddr != "" {
+		go servePprof(ctx, cfg.pprofAddr)
 	}
 
-	const timeout = 5 * time.Second
+	st := &serverState{
+		vin:     s.VIN,
+		ctx:     ctx,
+		cfg:     cfg,
+		servers: map[string]*httpd.Server{},
+	}
+	if err := st.setNotifiers(cfg); err != nil {
+		return err
+	}
+
+	h := httpd.New(s, st.options(""))
+	st.servers[""] = h
+
+	if cfg.planCharging {
+		level, err := parseChargerLevel(cfg.chargerLevel)
+		if err != nil {
+			return err
+		}
+		if len(cfg.departures) == 0 {
+			return fmt.Errorf("-plan-charging needs at least one -departure")
+		}
+		go planCharging(ctx, h, cfg, level)
+	}
+
+	if cfg.calendarURL != "" {
+		go precondition(ctx, h, cfg)
+	}
+
+	h.HandleFunc("/predict", func(w http.ResponseWriter, r *http.Request) {
+		cfg := st.config()
 
-	http.HandleFunc("/battery", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
-			status, err := s.BatteryStatus()
+			target := cfg.chargeTarget
+			if t := r.FormValue("target"); t != "" {
+				var err error
+				target, err = strconv.Atoi(t)
+				if err != nil {
+					http.Error(w, "invalid target", http.StatusBadRequest)
+					return
+				}
+			}
+
+			level := carwings.Level2
+			if l := r.FormValue("level"); l != "" {
+				var err error
+				level, err = parseChargerLevel(l)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			// In turn with the server's commands for the vehicle
+			var status carwings.BatteryStatus
+			err := h.Command(r.Context(), "", "Battery status request", func(ctx context.Context, s *carwings.Session) error {
+				var err error
+				status, err = s.BatteryStatusContext(ctx)
+				return partial(err)
+			})
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				h.Error(w, err)
 				return
 			}
+			if err := cfg.history.addBattery(status); err != nil {
+				fmt.Fprintf(logOutput(), "Error saving battery history: %s\n", err)
+			}
 
-			json.NewEncoder(w).Encode(status)
-
-		default:
-			http.NotFound(w, r)
-			return
-		}
-	})
+			p, err := newPredictor(cfg, status, level)
+			if err != nil {
+				h.Error(w, err)
+				return
+			}
 
-	http.HandleFunc("/climate", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			status, err := s.ClimateControlStatus()
+			t, err := p.Predict(target)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				h.Error(w, err)
 				return
 			}
 
-			json.NewEncoder(w).Encode(status)
+			httpd.WriteJSON(w, r, struct {
+				StateOfCharge int
+				Target        int
+				Level         string
+				Time          time.Time
+			}{status.StateOfCharge, target, level.String(), t}, "")
 
 		default:
 			http.NotFound(w, r)
@@ -91,88 +498,76 @@ func runServer(s *carwings.Session, cfg config, args []string) error {
 		}
 	})
 
-	http.HandleFunc("/charging/on", func(w http.ResponseWriter, r *http.Request) {
+	if cfg.alexaToken != "" {
+		h.HandleFunc("/alexa", h.ServeAlexa)
+	}
+	if cfg.googleHomeToken != "" {
+		h.HandleFunc("/google-home", h.ServeGoogleHome)
+	}
+	if cfg.slackSigningSecret != "" {
+		h.HandleFunc("/slack", h.ServeSlack)
+	}
+
+	reload := func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
-			fmt.Println("Charging request")
-
-			ch := make(chan error, 1)
-			go func() {
-				ch <- s.ChargingRequest()
-			}()
-
-			select {
-			case err := <-ch:
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-				}
+			fmt.Fprintf(logOutput(), "Reloading configuration\n")
 
-			case <-time.After(timeout):
-				w.WriteHeader(http.StatusAccepted)
+			if err := st.reload(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
 
 		default:
 			http.NotFound(w, r)
 			return
 		}
-	})
-
-	http.HandleFunc("/climate/on", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "POST":
-			fmt.Println("Climate control on request")
-
-			ch := make(chan error, 1)
-			go func() {
-				_, err := s.ClimateOnRequest()
-				ch <- err
-			}()
+	}
+	h.HandleFunc("/admin/reload", h.Admin(reload))
 
-			select {
-			case err := <-ch:
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-				}
+	// Serve the rest of the vehicles on this account
+	sessions, err := connectAccount(ctx, cfg, profile{username: cfg.username, password: cfg.password}, s)
+	if err != nil {
+		return err
+	}
+	for _, vs := range sessions {
+		h.Add(vs)
+	}
 
-			case <-time.After(timeout):
-				w.WriteHeader(http.StatusAccepted)
-			}
+	// Each profile gets its own server, with its own update loop,
+	// under /<name>/.
+	for _, prof := range cfg.profiles {
+		fmt.Fprintf(logOutput(), "Logging into Carwings for profile %s...\n", prof.name)
 
-		default:
-			http.NotFound(w, r)
-			return
+		sessions, err := connectAccount(ctx, cfg, prof, nil)
+		if err != nil {
+			return err
 		}
-	})
 
-	http.HandleFunc("/climate/off", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "POST":
-			fmt.Println("Climate control off request")
-
-			ch := make(chan error, 1)
-			go func() {
-				_, err := s.ClimateOffRequest()
-				ch <- err
-			}()
+		ph := httpd.New(sessions[0], st.options(prof.name))
+		st.servers[prof.name] = ph
+		for _, vs := range sessions[1:] {
+			ph.Add(vs)
+		}
+		h.Handle("/"+prof.name+"/", http.StripPrefix("/"+prof.name, ph))
 
-			select {
-			case err := <-ch:
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-				}
+		go ph.Run(ctx)
+	}
 
-			case <-time.After(timeout):
-				w.WriteHeader(http.StatusAccepted)
-			}
+	go h.Run(ctx)
 
-		default:
-			http.NotFound(w, r)
-			return
-		}
-	})
+	go st.handleSignals(ctx)
 
 	srv.Addr = cfg.serverAddr
-	srv.Handler = nil
-	fmt.Printf("Starting HTTP server on %s...\n", srv.Addr)
-	return srv.ListenAndServe()
+	srv.Handler = h
+	if cfg.basePath != "" {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.basePath+"/", http.StripPrefix(cfg.basePath, h))
+		srv.Handler = mux
+	}
+	fmt.Fprintf(logOutput(), "Starting HTTP server on %s%s...\n", srv.Addr, cfg.basePath)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals pause or resume a server's updates.
+var pauseSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+// pauseSignals pause or resume a server's updates.  Windows has no
+// SIGUSR1, so only the /admin endpoints can.
+var pauseSignals []os.Signal
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/joeshaw/carwings"
+)
+
+// parallel runs the functions concurrently, with at most limit of them
+// running at once, and returns their errors in the same order.
+func parallel(ctx context.Context, limit int, fns ...func(context.Context) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, limit)
+		errs = make([]error, len(fns))
+	)
+	for i, fn := range fns {
+		wg.Add(1)
+		go func(i int, fn func(context.Context) error) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			errs[i] = fn(ctx)
+		}(i, fn)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func runStatus(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("status")
+	fs.Parse(args)
+
+	progress(tr("Getting latest vehicle status..."))
+
+	// Bound how long we wait for the vehicle to report its position
+	// and cabin temperature
+	vctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	vs, err := s.VehicleStatus(vctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && err != context.DeadlineExceeded {
+		return err
+	}
+
+	if vs.BatteryErr = partial(vs.BatteryErr); vs.BatteryErr == nil {
+		bs := vs.Battery
+		if err := cfg.history.addBattery(bs); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Battery status as of %s:\n"), formatTime(bs.Timestamp, ""))
+		printCapacity(bs)
+		fmt.Printf(tr("  Plug-in state: %s\n"), tr(bs.PluginState.String()))
+		fmt.Printf(tr("  Charging status: %s\n"), tr(bs.ChargingStatus.String()))
+	} else {
+		fmt.Printf(tr("Battery status unavailable: %v\n"), vs.BatteryErr)
+	}
+
+	if vs.ClimateErr = partial(vs.ClimateErr); vs.ClimateErr == nil {
+		cs := vs.Climate
+		running := tr("no")
+		if cs.Running {
+			running = tr("yes")
+		}
+		fmt.Print(tr("Climate status:\n"))
+		fmt.Printf(tr("  Running: %s\n"), running)
+		fmt.Printf(tr("  Cruising range: %s (%s with AC)\n"), prettyUnits(cfg.units, cs.CruisingRangeACOff), prettyUnits(cfg.units, cs.CruisingRangeACOn))
+	} else {
+		fmt.Printf(tr("Climate status unavailable: %v\n"), vs.ClimateErr)
+	}
+
+	if vs.LocationErr == nil {
+		fmt.Printf(tr("Location: %.5f, %.5f as of %s\n"), vs.Location.Latitude, vs.Location.Longitude, formatTime(vs.Location.Timestamp, ""))
+	} else {
+		fmt.Printf(tr("Location unavailable: %v\n"), vs.LocationErr)
+	}
+
+	if vs.CabinTempErr == nil {
+		fmt.Printf(tr("Cabin temperature: %d°\n"), vs.CabinTemp)
+	} else {
+		fmt.Printf(tr("Cabin temperature unavailable: %v\n"), vs.CabinTempErr)
+	}
+	fmt.Println()
+
+	if vs.BatteryErr != nil || vs.ClimateErr != nil || vs.LocationErr != nil || vs.CabinTempErr != nil {
+		return fmt.Errorf("vehicle status incomplete")
+	}
+
+	return nil
+}
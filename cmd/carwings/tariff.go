@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// tariffWindow is a named time-of-use window with its own electricity
+// rate, such as "off-peak 23:00-07:00 0.08".  Windows may wrap around
+// midnight.
+type tariffWindow struct {
+	name       string
+	start, end time.Duration // offset from midnight
+	rate       float64       // per kWh
+}
+
+func (w tariffWindow) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.start <= w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+func (w tariffWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%s %s-%s %g", w.name, clock(w.start), clock(w.end), w.rate)
+}
+
+// parseClockRange parses a daily period in HH:MM-HH:MM format into
+// offsets from midnight.
+func parseClockRange(s string) (start, end time.Duration, err error) {
+	parseClock := func(s string) (time.Duration, error) {
+		c, err := time.Parse("15:04", s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q -- must be HH:MM", s)
+		}
+		return time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute, nil
+	}
+
+	span := strings.SplitN(s, "-", 2)
+	if len(span) != 2 {
+		return 0, 0, fmt.Errorf("invalid period %q -- must be HH:MM-HH:MM", s)
+	}
+	if start, err = parseClock(span[0]); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(span[1]); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// tariffs is a flag.Value that collects time-of-use windows from
+// repeated -tariff flags or config file lines.
+type tariffs []tariffWindow
+
+var _ flag.Value = (*tariffs)(nil)
+
+func (t *tariffs) String() string {
+	if t == nil {
+		return ""
+	}
+	s := make([]string, len(*t))
+	for i, w := range *t {
+		s[i] = w.String()
+	}
+	return strings.Join(s, "; ")
+}
+
+func (t *tariffs) Set(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return fmt.Errorf("tariff %q must be in the format \"<name> <HH:MM>-<HH:MM> <rate>\"", value)
+	}
+
+	start, end, err := parseClockRange(fields[1])
+	if err != nil {
+		return err
+	}
+
+	rate, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return fmt.Errorf("invalid tariff rate %q", fields[2])
+	}
+
+	*t = append(*t, tariffWindow{name: fields[0], start: start, end: end, rate: rate})
+	return nil
+}
+
+// lookup returns the first tariff window containing t.
+func (t tariffs) lookup(tm time.Time) (tariffWindow, bool) {
+	for _, w := range t {
+		if w.contains(tm) {
+			return w, true
+		}
+	}
+	return tariffWindow{}, false
+}
+
+// defaultRate returns the electricity rate for energy used outside of
+// any -tariff window: the -electricity-rate, or else apiRate, the rate
+// configured with Carwings.
+func (cfg config) defaultRate(apiRate float64) float64 {
+	if cfg.electricityRate > 0 {
+		return cfg.electricityRate
+	}
+	return apiRate
+}
+
+// tripRate returns the electricity rate for the energy used by t: that
+// of the -tariff window it started in, or else defaultRate.
+func (cfg config) tripRate(t carwings.TripDetail, defaultRate float64) float64 {
+	if w, ok := cfg.tariffs.lookup(t.Started); ok {
+		return w.rate
+	}
+	return defaultRate
+}
+
+type tariffUsage struct {
+	name   string
+	rate   float64
+	trips  int
+	meters int
+	power  float64 // Wh
+}
+
+func runCost(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("cost")
+	period := periodFlags(fs)
+	fs.Parse(args)
+
+	months, err := period()
+	if err != nil {
+		return err
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	// Energy used outside of any configured window is billed at
+	// the -electricity-rate, or else the rate configured with
+	// Carwings.
+	stats, err := s.GetMonthlyStatisticsRangeContext(ctx, months[0], months[len(months)-1])
+	if err != nil {
+		return err
+	}
+
+	var defaultRate float64
+	var trips []carwings.TripDetail
+	for _, ms := range stats {
+		if rate := cfg.defaultRate(ms.ElectricityRate); rate > 0 {
+			defaultRate = rate
+		}
+		for _, d := range ms.Dates {
+			trips = append(trips, d.Trips...)
+		}
+	}
+
+	usage := map[string]*tariffUsage{}
+	for _, t := range trips {
+		w, ok := cfg.tariffs.lookup(t.Started)
+		if !ok {
+			w = tariffWindow{name: "standard", rate: defaultRate}
+		}
+
+		u := usage[w.name]
+		if u == nil {
+			u = &tariffUsage{name: w.name, rate: w.rate}
+			usage[w.name] = u
+		}
+		u.trips++
+		u.meters += t.Meters
+		u.power += t.PowerConsumedTotal
+	}
+
+	windows := make([]*tariffUsage, 0, len(usage))
+	for _, u := range usage {
+		windows = append(windows, u)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].name < windows[j].name })
+
+	var (
+		totalCost   float64
+		totalMeters int
+		totalPower  float64
+	)
+
+	fmt.Printf("Driving cost from %s to %s\n",
+		months[0].Format("January 2006"), months[len(months)-1].Format("January 2006"))
+	for _, u := range windows {
+		cost := u.power / 1000 * u.rate
+		totalCost += cost
+		totalMeters += u.meters
+		totalPower += u.power
+
+		fmt.Printf("  %-10s %4d trips %7.1f %s %7.1f kWh at %.4f/kWh => %.2f\n",
+			u.name, u.trips, metersToUnits(cfg.units, u.meters), cfg.units,
+			u.power/1000, u.rate, cost)
+	}
+
+	if totalMeters > 0 {
+		fmt.Printf("  Total: %.2f for %.1f kWh over %s => %.4f/%s\n",
+			totalCost, totalPower/1000, prettyUnits(cfg.units, totalMeters),
+			totalCost/metersToUnits(cfg.units, totalMeters), cfg.units)
+	} else {
+		fmt.Printf("  (no trips in this period)\n")
+	}
+	fmt.Println()
+
+	return nil
+}
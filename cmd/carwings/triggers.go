@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+const iftttURL = "https://maker.ifttt.com/trigger/{event}/with/key/{key}"
+
+// Trigger events
+const (
+	eventChargeComplete = "charge_complete"
+	eventChargeStopped  = "charging_stopped_early"
+	eventPluggedIn      = "plugged_in"
+	eventLowSOC         = "low_soc"
+	eventSOCReached     = "soc_reached"
+	eventDeparture      = "departure_reminder"
+)
+
+// eventTitles describe the events to people; they are translated.
+var eventTitles = map[string]string{
+	eventChargeComplete: "Charging complete",
+	eventChargeStopped:  "Charging stopped early",
+	eventPluggedIn:      "Car plugged in",
+	eventLowSOC:         "Battery low",
+	eventSOCReached:     "Charge level reached",
+	eventDeparture:      "Not ready for departure",
+}
+
+// percentages is a flag.Value for a comma-separated list of
+// percentages, like "80,100".
+type percentages []int
+
+var _ flag.Value = (*percentages)(nil)
+
+func (p *percentages) String() string {
+	if p == nil {
+		return ""
+	}
+	s := make([]string, len(*p))
+	for i, v := range *p {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+func (p *percentages) Set(value string) error {
+	var list percentages
+	for _, f := range strings.Split(value, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSuffix(f, "%"))
+		if err != nil || v < 0 || v > 100 {
+			return fmt.Errorf("invalid percentage %q", f)
+		}
+		list = append(list, v)
+	}
+	*p = list
+	return nil
+}
+
+// notification is an event for a vehicle, passed to notifiers.
+type notification struct {
+	Event     string
+	Title     string
+	Time      time.Time
+	VIN       string
+	Battery   carwings.BatteryStatus
+	Range     string // cruising range with climate control off, in the configured units
+	Threshold int    // for the soc_reached, low_soc and departure_reminder events
+	Departure time.Time
+}
+
+// A notifier delivers notifications somewhere.
+type notifier interface {
+	notify(ctx context.Context, n notification) error
+}
+
+// triggers fires named events when the battery status changes in a
+// way worth knowing about, and passes them to the configured
+// notifiers.
+type triggers struct {
+	units      string
+	target     int
+	above      []int // thresholds for soc_reached
+	below      []int // thresholds for low_soc
+	hysteresis int
+	notifiers  []notifier
+	queue      chan notification // for deliver
+
+	mu    sync.Mutex
+	last  map[string]carwings.BatteryStatus // by VIN
+	armed map[threshold]bool
+}
+
+// notifyQueueSize is how many notifications can wait for slow
+// notifiers before more are dropped.
+const notifyQueueSize = 32
+
+// threshold identifies a state of charge alert for a vehicle.
+type threshold struct {
+	vin     string
+	percent int
+	below   bool
+}
+
+// newTriggers returns triggers that deliver notifications until ctx is
+// done, or nil if no notifiers are configured.
+func newTriggers(ctx context.Context, cfg config) (*triggers, error) {
+	t := &triggers{
+		units:      cfg.units,
+		target:     cfg.chargeTarget,
+		above:      cfg.socAlerts,
+		below:      cfg.lowSOC,
+		hysteresis: cfg.socHysteresis,
+		queue:      make(chan notification, notifyQueueSize),
+		last:       map[string]carwings.BatteryStatus{},
+		armed:      map[threshold]bool{},
+	}
+
+	u := cfg.triggerURL
+	if u == "" && cfg.iftttKey != "" {
+		u = strings.Replace(iftttURL, "{key}", url.PathEscape(cfg.iftttKey), 1)
+	}
+	if u != "" {
+		t.notifiers = append(t.notifiers, webhook{url: u})
+	}
+
+	if cfg.smtpHost != "" {
+		e, err := newEmailNotifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		t.notifiers = append(t.notifiers, e)
+	}
+
+	if len(t.notifiers) == 0 {
+		return nil, nil
+	}
+	go t.deliver(ctx)
+	return t, nil
+}
+
+func isCharging(bs carwings.BatteryStatus) bool {
+	return bs.ChargingStatus == carwings.NormalCharging || bs.ChargingStatus == carwings.RapidlyCharging
+}
+
+func isPluggedIn(bs carwings.BatteryStatus) bool {
+	return bs.PluginState == carwings.Connected || bs.PluginState == carwings.QCConnected
+}
+
+// batteryStatus compares bs with the previous status for the vehicle
+// and fires any events.  Nothing fires for the first status seen.
+func (t *triggers) batteryStatus(vin string, bs carwings.BatteryStatus) {
+	t.mu.Lock()
+	prev, ok := t.last[vin]
+	t.last[vin] = bs
+	crossed := t.thresholds(vin, bs.StateOfCharge, !ok)
+	t.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// Charging that stops short of the target while the car is still
+	// plugged in points to a fault with the charger or a misconfigured
+	// charging timer.
+	if isCharging(prev) && !isCharging(bs) && isPluggedIn(bs) {
+		if bs.StateOfCharge < t.target {
+			t.fire(notification{Event: eventChargeStopped, VIN: vin, Battery: bs})
+		} else {
+			t.fire(notification{Event: eventChargeComplete, VIN: vin, Battery: bs})
+		}
+	}
+	if prev.PluginState == carwings.NotConnected && isPluggedIn(bs) {
+		t.fire(notification{Event: eventPluggedIn, VIN: vin, Battery: bs})
+	}
+
+	for _, th := range crossed {
+		if th.below {
+			t.fire(notification{Event: eventLowSOC, VIN: vin, Battery: bs, Threshold: th.percent})
+		} else {
+			t.fire(notification{Event: eventSOCReached, VIN: vin, Battery: bs, Threshold: th.percent})
+		}
+	}
+}
+
+// thresholds returns the state of charge thresholds that soc has
+// crossed.  Each fires once, and only fires again after the state of
+// charge has moved back past it by the hysteresis, so readings that
+// hover around a threshold don't repeat the alert.  On the first
+// reading, thresholds already crossed are considered to have fired.
+// t.mu must be held.
+func (t *triggers) thresholds(vin string, soc int, first bool) []threshold {
+	var crossed []threshold
+
+	check := func(th threshold, past, rearm bool) {
+		armed, ok := t.armed[th]
+		switch {
+		case first || !ok:
+			t.armed[th] = !past
+		case armed && past:
+			t.armed[th] = false
+			crossed = append(crossed, th)
+		case !armed && rearm:
+			t.armed[th] = true
+		}
+	}
+
+	for _, p := range t.above {
+		check(threshold{vin, p, false}, soc >= p, soc < p-t.hysteresis)
+	}
+	for _, p := range t.below {
+		check(threshold{vin, p, true}, soc < p, soc >= p+t.hysteresis)
+	}
+
+	return crossed
+}
+
+// fire fills in the rest of the notification and queues it for the
+// notifiers, without waiting for them, so a slow webhook or mail server
+// doesn't hold up the updates that fire events.  If too many are
+// already queued, it is dropped.
+func (t *triggers) fire(n notification) {
+	fmt.Fprintf(logOutput(), "Triggering %s for %s\n", n.Event, n.VIN)
+
+	n.Title = tr(eventTitles[n.Event])
+	n.Time = time.Now()
+	n.Range = prettyUnits(t.units, n.Battery.CruisingRangeACOff)
+
+	select {
+	case t.queue <- n:
+	default:
+		fmt.Fprintf(logOutput(), "Error triggering %s: too many notifications pending\n", n.Event)
+	}
+}
+
+// deliver passes each queued notification to each notifier, in order.
+// Errors are logged rather than returned, so an unreachable notifier
+// doesn't interrupt anything else.  It returns when ctx is done.
+func (t *triggers) deliver(ctx context.Context) {
+	for {
+		var n notification
+		select {
+		case <-ctx.Done():
+			return
+		case n = <-t.queue:
+		}
+
+		for _, nt := range t.notifiers {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := nt.notify(ctx, n); err != nil {
+				fmt.Fprintf(logOutput(), "Error triggering %s: %s\n", n.Event, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// webhook posts events to a URL, in which {event}, {vin}, {soc} and
+// {threshold} are replaced with the event name, the VIN, the state of
+// charge and the threshold crossed, if any.
+// The body is JSON in the form IFTTT Webhooks expects: value1 is the
+// state of charge, value2 the cruising range and value3 the VIN.
+type webhook struct {
+	url string
+}
+
+func (wh webhook) notify(ctx context.Context, n notification) error {
+	u := strings.NewReplacer(
+		"{event}", url.PathEscape(n.Event),
+		"{vin}", url.PathEscape(n.VIN),
+		"{soc}", strconv.Itoa(n.Battery.StateOfCharge),
+		"{threshold}", strconv.Itoa(n.Threshold),
+	).Replace(wh.url)
+
+	body, err := json.Marshal(map[string]string{
+		"value1": strconv.Itoa(n.Battery.StateOfCharge),
+		"value2": n.Range,
+		"value3": n.VIN,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+const (
+	rankByEfficiency = "efficiency"
+	rankByDistance   = "distance"
+	rankByEnergy     = "energy"
+	rankByTime       = "time"
+)
+
+const (
+	groupByWeekday     = "weekday"
+	groupByHour        = "hour"
+	groupByWeekdayHour = "weekday-hour"
+)
+
+// monthOf returns a time in the middle of the month of t.  Months are
+// represented this way because the library converts them to the
+// account's time zone, which would turn midnight on the first of the
+// month into the previous month for accounts west of us.
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 15, 12, 0, 0, 0, time.Local)
+}
+
+// parseMonth parses a month argument: YYYY-MM, this-month, last-month,
+// or the name of a month, like may or May, meaning the most recent
+// one that isn't in the future.
+func parseMonth(s string) (time.Time, error) {
+	now := monthOf(time.Now())
+
+	switch strings.ToLower(s) {
+	case "this-month":
+		return now, nil
+	case "last-month":
+		return now.AddDate(0, -1, 0), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01", s, time.Local); err == nil {
+		return monthOf(t), nil
+	}
+
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if l := strings.ToLower(s); l == name || l == name[:3] {
+			t := time.Date(now.Year(), m, 15, 12, 0, 0, 0, time.Local)
+			if t.After(now) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid month %q -- must be YYYY-MM, this-month, last-month or a month name", s)
+}
+
+// monthArgs returns the month given by the positional arguments of a
+// command like monthly: nothing for the current month, a month for
+// parseMonth, or the older year and month numbers, like 2024 5.
+func monthArgs(args []string) (time.Time, error) {
+	switch len(args) {
+	case 0:
+		return monthOf(time.Now()), nil
+	case 1:
+		if _, err := strconv.Atoi(args[0]); err == nil {
+			return time.Time{}, fmt.Errorf("missing month after year %s -- try %s-05", args[0], args[0])
+		}
+		return parseMonth(args[0])
+	case 2:
+		y, err := strconv.Atoi(args[0])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid year %q", args[0])
+		}
+		m, err := strconv.Atoi(args[1])
+		if err != nil || m < 1 || m > 12 {
+			return time.Time{}, fmt.Errorf("invalid month %q -- must be 1 to 12", args[1])
+		}
+		return monthOf(time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.Local)), nil
+	default:
+		return time.Time{}, fmt.Errorf("too many arguments: %q", args)
+	}
+}
+
+// monthsBetween returns each month from "from" to "to", inclusive.
+func monthsBetween(from, to time.Time) []time.Time {
+	var months []time.Time
+	m := monthOf(from)
+	for !m.After(monthOf(to)) {
+		months = append(months, m)
+		m = m.AddDate(0, 1, 0)
+	}
+	return months
+}
+
+// periodFlags registers -from and -to flags on fs and returns a
+// function that resolves them to a list of months.  Both default to
+// the current month.
+func periodFlags(fs *flag.FlagSet) func() ([]time.Time, error) {
+	from := fs.String("from", "", "first month of the period (YYYY-MM, last-month, may, ...). Defaults to the current month.")
+	to := fs.String("to", "", "last month of the period (YYYY-MM, this-month, may, ...). Defaults to the current month.")
+
+	return func() ([]time.Time, error) {
+		start := monthOf(time.Now())
+		end := start
+
+		var err error
+		if *from != "" {
+			if start, err = parseMonth(*from); err != nil {
+				return nil, err
+			}
+		}
+		if *to != "" {
+			if end, err = parseMonth(*to); err != nil {
+				return nil, err
+			}
+		}
+
+		if end.Before(start) {
+			return nil, fmt.Errorf("-to month is before -from month")
+		}
+
+		return monthsBetween(start, end), nil
+	}
+}
+
+// fetchTrips retrieves all trips in the given months, which must be
+// consecutive.
+func fetchTrips(ctx context.Context, s *carwings.Session, months []time.Time) ([]carwings.TripDetail, error) {
+	if len(months) == 0 {
+		return nil, nil
+	}
+
+	stats, err := s.GetMonthlyStatisticsRangeContext(ctx, months[0], months[len(months)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	var trips []carwings.TripDetail
+	for _, ms := range stats {
+		for _, d := range ms.Dates {
+			trips = append(trips, d.Trips...)
+		}
+	}
+	return trips, nil
+}
+
+// tripEfficiency returns the energy used by the trip in kWh/km.
+func tripEfficiency(t carwings.TripDetail) float64 {
+	return t.PowerConsumedTotal / float64(t.Meters)
+}
+
+func runTrips(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	if len(args) > 0 && args[0] == "export" {
+		return runTripsExport(ctx, s, cfg, args[1:])
+	}
+	if len(args) > 0 && args[0] == "tag" {
+		return runTripsTag(ctx, s, cfg, args[1:])
+	}
+
+	fs := commandFlags("trips")
+	top := fs.Int("top", 10, "number of trips to show")
+	by := fs.String("by", rankByEfficiency, "rank trips by efficiency, distance, energy or time")
+	reverse := fs.Bool("reverse", false, "reverse the ranking (most efficient, shortest, least energy or oldest first)")
+	group := fs.String("group", "", "summarize trips by weekday, hour or weekday-hour instead of ranking them")
+	output := fs.String("output", outputText, "output format: text or xlsx")
+	file := fs.String("file", "", "file to write xlsx output to. Defaults to carwings-trips-<from>-<to>.xlsx.")
+	tag := fs.String("tag", "", "only include trips tagged with this tag, with trips tag")
+	period := periodFlags(fs)
+	fs.Parse(args)
+
+	months, err := period()
+	if err != nil {
+		return err
+	}
+
+	switch *group {
+	case "", groupByWeekday, groupByHour, groupByWeekdayHour:
+	default:
+		return fmt.Errorf("unsupported grouping (%q) -- must be weekday, hour or weekday-hour", *group)
+	}
+
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if *output == outputXLSX && *group != "" {
+		return fmt.Errorf("-group can't be written as xlsx")
+	}
+
+	less, err := tripOrder(*by)
+	if err != nil {
+		return err
+	}
+
+	notes, err := cfg.history.tripNotes()
+	if err != nil {
+		return err
+	}
+	if *tag != "" && cfg.history == nil {
+		return fmt.Errorf("-tag needs -history-dir, where trips are tagged")
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	all, err := fetchTrips(ctx, s, months)
+	if err != nil {
+		return err
+	}
+
+	// Trips without any distance have no meaningful efficiency
+	trips := all[:0]
+	for _, t := range all {
+		if t.Meters > 0 && (*tag == "" || notes[tripKey(t)].hasTag(*tag)) {
+			trips = append(trips, t)
+		}
+	}
+
+	if *group != "" {
+		printTripGroups(cfg, *group, months, trips)
+		return nil
+	}
+
+	// Ranked worst (or longest, or most recent) first
+	sortTrips(trips, less, !*reverse)
+
+	if *top > 0 && len(trips) > *top {
+		trips = trips[:*top]
+	}
+
+	title := fmt.Sprintf("Top %d trips by %s from %s to %s", len(trips), *by,
+		months[0].Format("January 2006"), months[len(months)-1].Format("January 2006"))
+	if *tag != "" {
+		title += fmt.Sprintf(" tagged %s", *tag)
+	}
+
+	if *output == outputXLSX {
+		if *file == "" {
+			*file = "carwings-trips-" + months[0].Format("2006-01") + "-" + months[len(months)-1].Format("2006-01") + ".xlsx"
+		}
+		return writeStatisticsXLSX(cfg, *file, title, trips, 0)
+	}
+
+	fmt.Println(title)
+	printTripList(cfg, trips, nil, notes)
+	fmt.Println()
+
+	return nil
+}
+
+// tripOrder returns the function ordering trips by efficiency (most
+// efficient first), distance, energy or start time, in ascending
+// order.
+func tripOrder(by string) (func(a, b carwings.TripDetail) bool, error) {
+	switch by {
+	case rankByEfficiency:
+		return func(a, b carwings.TripDetail) bool { return tripEfficiency(a) < tripEfficiency(b) }, nil
+	case rankByDistance:
+		return func(a, b carwings.TripDetail) bool { return a.Meters < b.Meters }, nil
+	case rankByEnergy:
+		return func(a, b carwings.TripDetail) bool { return a.PowerConsumedTotal < b.PowerConsumedTotal }, nil
+	case rankByTime:
+		return func(a, b carwings.TripDetail) bool { return a.Started.Before(b.Started) }, nil
+	default:
+		return nil, fmt.Errorf("unsupported ordering (%q) -- must be efficiency, distance, energy or time", by)
+	}
+}
+
+// sortTrips sorts trips with less, keeping trips that compare equal in
+// order.
+func sortTrips(trips []carwings.TripDetail, less func(a, b carwings.TripDetail) bool, desc bool) {
+	sort.SliceStable(trips, func(i, j int) bool {
+		if desc {
+			return less(trips[j], trips[i])
+		}
+		return less(trips[i], trips[j])
+	})
+}
+
+// printTripList prints trips as a numbered list, with the cost of
+// each if cost isn't nil, and their tags and notes.
+func printTripList(cfg config, trips []carwings.TripDetail, cost func(carwings.TripDetail) float64, notes map[string]tripNote) {
+	for i, t := range trips {
+		fmt.Printf("  %3d. %s %6.1f %s %5.1f %s %6.1f kWh", i+1,
+			t.Started.Format("2006-01-02 15:04"),
+			metersToUnits(cfg.units, t.Meters), cfg.units,
+			efficiencyToUnits(unitskWhPerKm, cfg.effunits, tripEfficiency(t)),
+			cfg.effunits, t.PowerConsumedTotal/1000)
+		if cost != nil {
+			fmt.Printf(" %8.2f", cost(t))
+		}
+		if n, ok := notes[tripKey(t)]; ok {
+			fmt.Printf("  %s", formatTripNote(n))
+		}
+		fmt.Println()
+	}
+}
+
+// formatTripNote returns the trip's tags and note for a trip list.
+func formatTripNote(n tripNote) string {
+	var parts []string
+	if len(n.Tags) > 0 {
+		parts = append(parts, "["+strings.Join(n.Tags, ", ")+"]")
+	}
+	if n.Note != "" {
+		parts = append(parts, n.Note)
+	}
+	return strings.Join(parts, " ")
+}
+
+// runTripsTag tags a trip, or notes something about it, for filtering
+// reports with -tag, such as commutes for an expense claim.
+func runTripsTag(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("trips tag")
+	note := fs.String("note", "", "note to keep with the trip, replacing any earlier one. \"-\" removes it.")
+	remove := fs.Bool("remove", false, "remove the given tags instead of adding them")
+	fs.Parse(args)
+
+	if cfg.history == nil {
+		return fmt.Errorf("trips tag needs -history-dir to keep tags in")
+	}
+
+	// Trip times are two arguments when the date and time aren't
+	// quoted together
+	args = fs.Args()
+	if len(args) >= 2 && strings.Contains(args[1], ":") {
+		args = append([]string{args[0] + " " + args[1]}, args[2:]...)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("trips tag needs the start time of a trip, such as 2024-05-01 08:15")
+	}
+
+	started, err := time.ParseInLocation(tripKeyLayout, strings.Replace(args[0], "T", " ", 1), time.UTC)
+	if err != nil {
+		return fmt.Errorf("invalid trip start time (%q) -- must be YYYY-MM-DD HH:MM, as trips are listed", args[0])
+	}
+	key := started.Format(tripKeyLayout)
+
+	progress(tr("Sending monthly statistics request..."))
+
+	trips, err := fetchTrips(ctx, s, []time.Time{monthOf(started)})
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, t := range trips {
+		found = found || tripKey(t) == key
+	}
+	if !found {
+		return fmt.Errorf("no trip started at %s", key)
+	}
+
+	n, err := cfg.history.updateTripNote(key, func(n *tripNote) {
+		for _, tag := range args[1:] {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" {
+				continue
+			}
+
+			tags := n.Tags[:0]
+			for _, t := range n.Tags {
+				if t != tag {
+					tags = append(tags, t)
+				}
+			}
+			if !*remove {
+				tags = append(tags, tag)
+			}
+			n.Tags = tags
+		}
+		sort.Strings(n.Tags)
+
+		switch *note {
+		case "":
+		case "-":
+			n.Note = ""
+		default:
+			n.Note = *note
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s  %s\n", key, formatTripNote(n))
+	return nil
+}
+
+type tripGroup struct {
+	weekday time.Weekday
+	hour    int
+	trips   int
+	meters  int
+	power   float64 // Wh
+}
+
+// groupTrips aggregates trips by the weekday and/or hour they started.
+// The returned groups are ordered by weekday (starting on Monday) and
+// then by hour.
+func groupTrips(group string, trips []carwings.TripDetail) []*tripGroup {
+	type key struct {
+		weekday time.Weekday
+		hour    int
+	}
+
+	groups := map[key]*tripGroup{}
+	for _, t := range trips {
+		started := t.Started
+
+		var k key
+		switch group {
+		case groupByWeekday:
+			k = key{weekday: started.Weekday(), hour: -1}
+		case groupByHour:
+			k = key{weekday: -1, hour: started.Hour()}
+		case groupByWeekdayHour:
+			k = key{weekday: started.Weekday(), hour: started.Hour()}
+		}
+
+		g := groups[k]
+		if g == nil {
+			g = &tripGroup{weekday: k.weekday, hour: k.hour}
+			groups[k] = g
+		}
+		g.trips++
+		g.meters += t.Meters
+		g.power += t.PowerConsumedTotal
+	}
+
+	// Weeks start on Monday for commuting purposes
+	order := func(wd time.Weekday) int { return (int(wd) + 6) % 7 }
+
+	result := make([]*tripGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].weekday != result[j].weekday {
+			return order(result[i].weekday) < order(result[j].weekday)
+		}
+		return result[i].hour < result[j].hour
+	})
+
+	return result
+}
+
+func printTripGroups(cfg config, group string, months []time.Time, trips []carwings.TripDetail) {
+	fmt.Printf("Trips by %s from %s to %s\n", group,
+		months[0].Format("January 2006"), months[len(months)-1].Format("January 2006"))
+	for _, g := range groupTrips(group, trips) {
+		var label string
+		switch group {
+		case groupByWeekday:
+			label = g.weekday.String()
+		case groupByHour:
+			label = fmt.Sprintf("%02d:00", g.hour)
+		case groupByWeekdayHour:
+			label = fmt.Sprintf("%.3s %02d:00", g.weekday, g.hour)
+		}
+
+		fmt.Printf("  %-10s %4d trips, avg %6.1f %s %6.1f kWh, %5.1f %s\n", label, g.trips,
+			metersToUnits(cfg.units, g.meters)/float64(g.trips), cfg.units,
+			g.power/1000/float64(g.trips),
+			efficiencyToUnits(unitskWhPerKm, cfg.effunits, g.power/float64(g.meters)),
+			cfg.effunits)
+	}
+	fmt.Println()
+}
+
+// maxEmptyMonths is the number of consecutive months without any
+// driving data after which we assume we've reached the beginning of
+// the vehicle's history.
+const maxEmptyMonths = 3
+
+// hasDates reports whether ms has any days with driving data.
+func hasDates(ms carwings.MonthlyStatistics) bool {
+	return len(ms.Dates) > 0
+}
+
+// drivingHistory retrieves the statistics of each month from first to
+// the current month, or if first is zero, from the beginning of the
+// vehicle's history: the earliest month hasData reports data for
+// before maxEmpty months in a row without any.  They are returned in
+// order, with the months they're for.  A year is requested at a time.
+func drivingHistory(ctx context.Context, s *carwings.Session, first time.Time, maxEmpty int, hasData func(carwings.MonthlyStatistics) bool) ([]time.Time, []carwings.MonthlyStatistics, error) {
+	var (
+		months []time.Time
+		stats  []carwings.MonthlyStatistics
+		empty  int
+	)
+	if !first.IsZero() {
+		first = monthOf(first)
+	}
+
+	for end := monthOf(time.Now()); ; end = end.AddDate(0, -12, 0) {
+		start := end.AddDate(0, -11, 0)
+		if !first.IsZero() && start.Before(first) {
+			start = first
+		}
+
+		chunk, err := s.GetMonthlyStatisticsRangeContext(ctx, start, end)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		done := false
+		for i := len(chunk) - 1; i >= 0 && !done; i-- {
+			months = append(months, start.AddDate(0, i, 0))
+			stats = append(stats, chunk[i])
+
+			if hasData(chunk[i]) {
+				empty = 0
+			} else {
+				empty++
+			}
+			if first.IsZero() && empty >= maxEmpty {
+				// Leave out the months before the history began
+				months, stats = months[:len(months)-empty], stats[:len(stats)-empty]
+				done = true
+			}
+		}
+		if done || (!first.IsZero() && !start.After(first)) {
+			break
+		}
+	}
+
+	// Oldest first
+	for i, j := 0, len(months)-1; i < j; i, j = i+1, j-1 {
+		months[i], months[j] = months[j], months[i]
+		stats[i], stats[j] = stats[j], stats[i]
+	}
+	return months, stats, nil
+}
+
+func runOdometer(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("odometer")
+	start := fs.Float64("start", 0, "odometer reading (in -units) at the beginning of the -since month")
+	since := fs.String("since", "", "month (YYYY-MM) of the -start reading. Defaults to the earliest month with data.")
+	fs.Parse(args)
+
+	var first time.Time
+	if *since != "" {
+		var err error
+		if first, err = parseMonth(*since); err != nil {
+			return err
+		}
+	}
+
+	progress(tr("Sending monthly statistics requests..."))
+
+	months, stats, err := drivingHistory(ctx, s, first, maxEmptyMonths, func(ms carwings.MonthlyStatistics) bool {
+		return ms.Total.MetersTravelled > 0
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		meters   int
+		earliest time.Time
+	)
+	for i, ms := range stats {
+		if ms.Total.MetersTravelled > 0 && earliest.IsZero() {
+			earliest = months[i]
+		}
+		meters += ms.Total.MetersTravelled
+	}
+
+	if !first.IsZero() {
+		earliest = first
+	}
+
+	total := *start + metersToUnits(cfg.units, meters)
+
+	fmt.Printf("Estimated odometer: %.0f %s\n", total, cfg.units)
+	if earliest.IsZero() {
+		fmt.Printf("  (no driving history available)\n")
+	} else {
+		fmt.Printf("  %.0f %s driven since %s\n", metersToUnits(cfg.units, meters), cfg.units, earliest.Format("January 2006"))
+	}
+	fmt.Println()
+
+	return nil
+}
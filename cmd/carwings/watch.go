@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// batteryEvent is printed as a line of JSON for each battery status
+// retrieved by the watch and server commands in -json mode.
+type batteryEvent struct {
+	Time    time.Time
+	VIN     string
+	Battery *carwings.BatteryStatus `json:",omitempty"`
+	Error   string                  `json:",omitempty"`
+}
+
+func runWatch(ctx context.Context, s *carwings.Session, cfg config, args []string) error {
+	fs := commandFlags("watch")
+	interval := fs.Duration("interval", cfg.serverUpdateInterval, "time between updates")
+	update := fs.Bool("update", true, "ask the vehicle for new data each time, instead of printing the latest retrieved status")
+	jitter := fs.Duration("jitter", 0, "random delay of up to this much added to each interval")
+	fs.Parse(args)
+
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+
+	if cfg.reloginInterval > 0 {
+		go s.KeepLoggedIn(ctx, cfg.reloginInterval)
+	}
+
+	hb := newHomebridge(ctx, cfg, s.VIN)
+	trig, err := newTriggers(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if trig != nil {
+		go trig.runDepartures(ctx, cfg.departures, cfg.departureLead)
+	}
+
+	report := func(bs carwings.BatteryStatus, err error) {
+		switch {
+		case jsonOutput && err != nil:
+			printJSON(batteryEvent{Time: time.Now(), VIN: s.VIN, Error: err.Error()})
+		case jsonOutput:
+			printJSON(batteryEvent{Time: time.Now(), VIN: s.VIN, Battery: &bs})
+		case err != nil:
+			fmt.Printf("ERROR: %v\n", err)
+		default:
+			fmt.Printf("%s: %d%%, %s, %s, %s\n", formatTime(bs.Timestamp, "2006-01-02 15:04"), bs.StateOfCharge,
+				prettyUnits(cfg.units, bs.CruisingRangeACOff), tr(bs.PluginState.String()), tr(bs.ChargingStatus.String()))
+		}
+	}
+
+	p := carwings.Poller{
+		Session:       s,
+		Update:        *update,
+		UpdateTimeout: cfg.timeout,
+		Interval:      *interval,
+		Jitter:        *jitter,
+		OnBatteryStatus: func(bs carwings.BatteryStatus) {
+			if hb != nil {
+				hb.batteryStatus(s.VIN, bs)
+			}
+			if trig != nil {
+				trig.batteryStatus(s.VIN, bs)
+			}
+			report(bs, nil)
+
+			// The status is still good if it can't be saved
+			if err := cfg.history.addBattery(bs); err != nil {
+				fmt.Fprintf(logOutput(), "Error saving battery history: %s\n", err)
+			}
+		},
+		OnError: func(err error) {
+			// The status of a partial error has already been
+			// reported, so just warn about it
+			if err := partial(err); err != nil {
+				report(carwings.BatteryStatus{}, err)
+			}
+		},
+	}
+	p.Run(ctx)
+	return nil
+}
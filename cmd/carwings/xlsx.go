@@ -0,0 +1,263 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// Output formats for the statistics commands
+const (
+	outputText = "text"
+	outputXLSX = "xlsx"
+)
+
+// checkOutput returns an error if output isn't a supported format.
+func checkOutput(output string) error {
+	switch output {
+	case outputText, outputXLSX:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format (%q) -- must be text or xlsx", output)
+	}
+}
+
+// xlsxStyle is an index into the cellXfs in the workbook's styles.
+type xlsxStyle int
+
+const (
+	styleGeneral xlsxStyle = iota
+	styleDateTime
+	styleDecimal
+	styleHeader
+)
+
+// xlsxCell is a cell of a worksheet: a string, a number or a formula.
+type xlsxCell struct {
+	text    string
+	number  float64
+	formula string
+	isText  bool
+	style   xlsxStyle
+}
+
+func textCell(s string) xlsxCell        { return xlsxCell{text: s, isText: true} }
+func headerCell(s string) xlsxCell      { return xlsxCell{text: s, isText: true, style: styleHeader} }
+func numberCell(n float64) xlsxCell     { return xlsxCell{number: n, style: styleDecimal} }
+func formulaCell(f string) xlsxCell     { return xlsxCell{formula: f, style: styleDecimal} }
+func dateTimeCell(t time.Time) xlsxCell { return xlsxCell{number: excelTime(t), style: styleDateTime} }
+
+// excelTime returns the spreadsheet serial number of t's local wall
+// clock time: days since 30 December 1899.
+func excelTime(t time.Time) float64 {
+	t = t.Local()
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+// cellRef returns the A1-style reference of the cell at the zero-based
+// row and column.
+func cellRef(row, col int) string {
+	var name string
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row+1)
+}
+
+type xlsxSheet struct {
+	name string
+	rows [][]xlsxCell
+}
+
+func (sh *xlsxSheet) addRow(cells ...xlsxCell) {
+	sh.rows = append(sh.rows, cells)
+}
+
+// xlsxWorkbook is a minimal Office Open XML spreadsheet writer, enough
+// for exporting statistics without depending on a spreadsheet library.
+type xlsxWorkbook struct {
+	sheets []*xlsxSheet
+}
+
+func (wb *xlsxWorkbook) addSheet(name string) *xlsxSheet {
+	sh := &xlsxSheet{name: name}
+	wb.sheets = append(wb.sheets, sh)
+	return sh
+}
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+</styleSheet>
+`
+
+// write writes the workbook as an xlsx file to w.
+func (wb *xlsxWorkbook) write(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	var types, rels, sheets strings.Builder
+	for i, sh := range wb.sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sh.name), n, n)
+	}
+	styles := len(wb.sheets) + 1
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+` + types.String() + `
+</Types>
+`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`},
+		// Formulas are written without values, so have them
+		// calculated when the workbook is opened.
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>` + sheets.String() + `</sheets>
+<calcPr fullCalcOnLoad="1"/>
+</workbook>
+`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+` + rels.String() + fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, styles) + `
+</Relationships>
+`},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, sh := range wb.sheets {
+		parts = append(parts, struct{ name, body string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sh.xml()})
+	}
+
+	for _, p := range parts {
+		f, err := z.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+func (sh *xlsxSheet) xml() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<cols><col min="1" max="1" width="22" customWidth="1"/><col min="2" max="16" width="16" customWidth="1"/></cols>
+<sheetData>
+`)
+	for r, row := range sh.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := cellRef(r, c)
+			switch {
+			case cell.formula != "":
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><f>%s</f></c>`, ref, cell.style, xmlEscape(cell.formula))
+			case cell.isText:
+				fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, cell.style, xmlEscape(cell.text))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, cell.style, strconv.FormatFloat(cell.number, 'f', -1, 64))
+			}
+		}
+		b.WriteString("</row>\n")
+	}
+	b.WriteString("</sheetData>\n</worksheet>\n")
+	return b.String()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeStatisticsXLSX writes a workbook to filename with a sheet of
+// the trips and a summary sheet totalling them with formulas.  The
+// cost is included if rate, the electricity rate per kWh, is known.
+func writeStatisticsXLSX(cfg config, filename, title string, trips []carwings.TripDetail, rate float64) error {
+	wb := &xlsxWorkbook{}
+
+	// Efficiency in -effunits from kWh and distance in -units
+	factor := efficiencyToUnits(unitskWhPerKm, cfg.effunits, 1) * metersToUnits(cfg.units, 1000)
+
+	ts := wb.addSheet("Trips")
+	ts.addRow(
+		headerCell("Started"),
+		headerCell(fmt.Sprintf("Distance (%s)", cfg.units)),
+		headerCell("Energy used (kWh)"),
+		headerCell("Motor (kWh)"),
+		headerCell("Regenerated (kWh)"),
+		headerCell(fmt.Sprintf("Efficiency (%s)", cfg.effunits)),
+	)
+	for _, t := range trips {
+		row := len(ts.rows)
+		ts.addRow(
+			dateTimeCell(t.Started),
+			numberCell(metersToUnits(cfg.units, t.Meters)),
+			numberCell(t.PowerConsumedTotal/1000),
+			numberCell(t.PowerConsumedMotor/1000),
+			numberCell(t.PowerRegenerated/1000),
+			formulaCell(fmt.Sprintf("IF(%s>0,%s/%s*%g,0)", cellRef(row, 1), cellRef(row, 2), cellRef(row, 1), factor)),
+		)
+	}
+
+	// Whole-column ranges, so totals stay right if rows are added
+	ss := wb.addSheet("Summary")
+	ss.addRow(headerCell(title))
+	ss.addRow(textCell("Trips"), formulaCell("COUNT(Trips!A:A)"))
+	ss.addRow(textCell("Distance"), formulaCell("SUM(Trips!B:B)"), textCell(cfg.units))
+	ss.addRow(textCell("Energy used"), formulaCell("SUM(Trips!C:C)"), textCell("kWh"))
+	ss.addRow(textCell("Regenerated"), formulaCell("SUM(Trips!E:E)"), textCell("kWh"))
+	ss.addRow(textCell("Efficiency"), formulaCell(fmt.Sprintf("IF(B3>0,B4/B3*%g,0)", factor)), textCell(cfg.effunits))
+	if rate > 0 {
+		ss.addRow(textCell("Electricity rate"), numberCell(rate), textCell("per kWh"))
+		ss.addRow(textCell("Cost"), formulaCell("B4*B7"))
+		ss.addRow(textCell("Cost per distance"), formulaCell("IF(B3>0,B8/B3,0)"), textCell("per "+strings.TrimSuffix(cfg.units, "s")))
+	}
+	ss.rows[1][1].style = styleGeneral
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := wb.write(f); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("Wrote %s\n"), filename)
+	return nil
+}
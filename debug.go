@@ -0,0 +1,50 @@
+package carwings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+)
+
+var (
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// reportUnknownFields logs the fields in data, part of a response from
+// endpoint, that have nowhere to go when it is decoded into v.  Field
+// names are prefixed with prefix.
+func (s *Session) reportUnknownFields(endpoint, prefix string, data []byte, v interface{}) {
+	found := map[string]string{}
+	inspectFields(data, reflect.TypeOf(v), prefix, found)
+
+	fields := make([]string, 0, len(found))
+	for f, what := range found {
+		if what == unknownField {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+
+	for _, f := range fields {
+		s.logf("carwings: unknown field %s in %s response", f, endpoint)
+	}
+}
+
+// debug reports whether requests and responses should be logged,
+// either because Debug is set or the session has a logger.
+func (s *Session) debug() bool {
+	return Debug || s.logger != nil
+}
+
+// logf logs a message to the session's logger, or to stderr if it
+// doesn't have one.
+func (s *Session) logf(format string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
@@ -0,0 +1,193 @@
+package carwings
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTransport matches errors talking to the Carwings service,
+	// such as network failures, with errors.Is.
+	ErrTransport = errors.New("carwings: transport error")
+
+	// ErrDecode matches errors parsing responses from the Carwings
+	// service with errors.Is.  They usually mean the API has
+	// changed.
+	ErrDecode = errors.New("carwings: invalid response")
+
+	// ErrVehicleUnreachable matches OperationErrors for commands the
+	// vehicle didn't respond to, usually because it is asleep or
+	// out of range of the mobile network, with errors.Is.
+	ErrVehicleUnreachable = errors.New("carwings: vehicle unreachable")
+
+	// ErrUnsupported matches errors for requests to endpoints the
+	// Carwings service doesn't have, such as StopChargingRequest in
+	// regions whose portal can't stop charging, with errors.Is.
+	ErrUnsupported = errors.New("carwings: not supported by the service")
+)
+
+// transportError wraps an error talking to the Carwings service.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string        { return e.err.Error() }
+func (e *transportError) Unwrap() error        { return e.err }
+func (e *transportError) Is(target error) bool { return target == ErrTransport }
+
+// unsupportedError is returned for an endpoint the Carwings service
+// responds to with 404 Not Found.
+type unsupportedError struct {
+	endpoint string
+}
+
+func (e *unsupportedError) Error() string {
+	return fmt.Sprintf("%s not supported by the carwings service", e.endpoint)
+}
+func (e *unsupportedError) Is(target error) bool { return target == ErrUnsupported }
+
+// decodeError wraps an error parsing a response from the Carwings
+// service.
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string        { return fmt.Sprintf("invalid response: %v", e.err) }
+func (e *decodeError) Unwrap() error        { return e.err }
+func (e *decodeError) Is(target error) bool { return target == ErrDecode }
+
+// PartialError is returned along with the fields that could be
+// parsed when a Session's ParseMode is ParseLenient and some fields
+// of a response couldn't be.  It matches ErrDecode with errors.Is.
+type PartialError struct {
+	// The fields that couldn't be parsed, by the dotted path of
+	// their names in the response
+	Fields []FieldError
+}
+
+// FieldError describes a field in a response that couldn't be parsed.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *PartialError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = fmt.Sprintf("%s: %v", f.Field, f.Err)
+	}
+	return fmt.Sprintf("invalid response: cannot parse %s", strings.Join(msgs, "; "))
+}
+
+func (e *PartialError) Is(target error) bool { return target == ErrDecode }
+
+// APIStatusError is returned when the Carwings service rejects a
+// request with a status code other than those that have their own
+// errors, ErrNotLoggedIn and ServiceError.
+type APIStatusError struct {
+	// The status code in the response
+	Code int
+
+	// The message from the service, if any.  Some regions, such as
+	// Japan, send it in the local language.
+	Message string
+}
+
+// statusDescriptions are stable English descriptions of the status
+// codes the Carwings service is known to respond with, since the
+// messages that come with them vary by region.
+var statusDescriptions = map[int]string{
+	http.StatusBadRequest:      "invalid request",
+	http.StatusForbidden:       "access denied",
+	http.StatusNotFound:        "no data available",
+	http.StatusConflict:        "another request for the vehicle is in progress",
+	http.StatusTooManyRequests: "too many requests",
+}
+
+// Description returns a stable English description of the status
+// code, or the message from the service if the code isn't known.
+func (e *APIStatusError) Description() string {
+	if d, ok := statusDescriptions[e.Code]; ok {
+		return d
+	}
+	return e.Message
+}
+
+func (e *APIStatusError) Error() string {
+	if d := e.Description(); d != "" {
+		return fmt.Sprintf("received status code %d (%s)", e.Code, d)
+	}
+	return fmt.Sprintf("received status code %d", e.Code)
+}
+
+// OperationError is returned when the vehicle reports that a remote
+// command, such as turning on climate control, failed.
+type OperationError struct {
+	// The command, like "climate control on"
+	Operation string
+
+	// The operationResult reported by the Carwings service, like
+	// "ELECTRIC_WAVE_ABNORMAL"
+	Result string
+}
+
+// operationErrors are the errors that known operation results match.
+var operationErrors = map[string]error{
+	electricWaveAbnormal: ErrVehicleUnreachable,
+}
+
+func (e *OperationError) Error() string {
+	if err, ok := operationErrors[e.Result]; ok {
+		return fmt.Sprintf("%s failed: %s (%s)", e.Operation, e.Result, strings.TrimPrefix(err.Error(), "carwings: "))
+	}
+	return fmt.Sprintf("%s failed: %s", e.Operation, e.Result)
+}
+
+func (e *OperationError) Is(target error) bool {
+	return target != nil && operationErrors[e.Result] == target
+}
+
+// operationResult returns an OperationError if result isn't one of the
+// results the service reports for successful commands.
+func operationResult(op, result string) error {
+	if result == "" || strings.HasPrefix(result, start) {
+		return nil
+	}
+	return &OperationError{Operation: op, Result: result}
+}
+
+// ServiceError is returned when the Carwings service is down for
+// maintenance or otherwise unavailable.
+type ServiceError struct {
+	// The HTTP or API status code
+	StatusCode int
+
+	// The message from the service, if any
+	Message string
+
+	// How long to wait before trying again
+	RetryAfter time.Duration
+}
+
+func (e *ServiceError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("carwings service unavailable: status code %d (%s)", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("carwings service unavailable: status code %d", e.StatusCode)
+}
+
+// retryAfter parses a Retry-After header value in seconds or as an
+// HTTP date.
+func retryAfter(h string) time.Duration {
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && time.Until(t) > 0 {
+		return time.Until(t)
+	}
+	return DefaultRetryAfter
+}
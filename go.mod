@@ -1,6 +1,6 @@
 module github.com/joeshaw/carwings
 
-go 1.12
+go 1.18
 
 require (
 	github.com/peterbourgon/ff v1.2.0
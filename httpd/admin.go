@@ -0,0 +1,165 @@
+package httpd
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Admin endpoints, for operating the server unattended.  They require
+// the AdminToken option as a bearer token.
+
+// Admin returns handler wrapped so that it requires the AdminToken
+// option as a bearer token, like the server's own /admin endpoints,
+// for extra admin handlers registered with Handle or HandleFunc.
+func (srv *Server) Admin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := srv.options().AdminToken
+		auth := r.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Paused reports whether updates by Run are paused.
+func (srv *Server) Paused() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.paused
+}
+
+// SetPaused pauses or resumes the updates made by Run.  Requests to
+// the endpoints are still served while paused.
+func (srv *Server) SetPaused(paused bool) {
+	srv.mu.Lock()
+	srv.paused = paused
+	srv.mu.Unlock()
+}
+
+// setError remembers err as the last error updating v.
+func (v *vehicle) setError(err error) {
+	v.mu.Lock()
+	v.lastErr, v.lastErrTime = err.Error(), time.Now()
+	v.mu.Unlock()
+}
+
+type adminSession struct {
+	VIN           string
+	Region        string
+	LoggedIn      time.Time
+	LastContact   time.Time
+	Expiry        time.Time
+	LastUpdate    *time.Time `json:",omitempty"`
+	LastError     string     `json:",omitempty"`
+	LastErrorTime *time.Time `json:",omitempty"`
+}
+
+// optionalTime returns a pointer to t, or nil if it is the zero time,
+// so it is left out of the JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (srv *Server) adminSessions() interface{} {
+	srv.mu.Lock()
+	vehicles := append([]*vehicle(nil), srv.vehicles...)
+	srv.mu.Unlock()
+
+	resp := struct {
+		Paused   bool
+		Sessions []adminSession
+	}{Paused: srv.Paused()}
+
+	for _, v := range vehicles {
+		as := adminSession{
+			VIN:         v.s.VIN,
+			Region:      v.s.Region,
+			LoggedIn:    v.s.LoggedIn(),
+			LastContact: v.s.LastContact(),
+			Expiry:      v.s.Expiry(),
+		}
+		v.mu.Lock()
+		as.LastUpdate, as.LastError, as.LastErrorTime = optionalTime(v.fetched), v.lastErr, optionalTime(v.lastErrTime)
+		v.mu.Unlock()
+		resp.Sessions = append(resp.Sessions, as)
+	}
+
+	return resp
+}
+
+// handleAdminSession serves the state of each vehicle's session: when
+// it logged in and last heard from Carwings, and the last error
+// updating it.
+func (srv *Server) handleAdminSession(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		WriteJSON(w, r, srv.adminSessions(), "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// handleAdminRelogin logs every vehicle's session in again, or just
+// that of the vehicle given with vin.
+func (srv *Server) handleAdminRelogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		vin := r.FormValue("vin")
+		if vin != "" && srv.lookup(vin) == nil {
+			http.Error(w, "unknown vehicle", http.StatusNotFound)
+			return
+		}
+
+		srv.mu.Lock()
+		vehicles := append([]*vehicle(nil), srv.vehicles...)
+		srv.mu.Unlock()
+
+		for _, v := range vehicles {
+			if vin != "" && v.s.VIN != vin {
+				continue
+			}
+			srv.options().Logger.Printf("Logging %s in again", v.s.VIN)
+			if err := v.s.LoginContext(r.Context()); err != nil {
+				v.setError(err)
+				srv.error(w, err)
+				return
+			}
+		}
+
+		WriteJSON(w, r, srv.adminSessions(), "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// handleAdminPause pauses or resumes updates.
+func (srv *Server) handleAdminPause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			if paused {
+				srv.options().Logger.Printf("Pausing updates")
+			} else {
+				srv.options().Logger.Printf("Resuming updates")
+			}
+			srv.SetPaused(paused)
+			WriteJSON(w, r, srv.adminSessions(), "")
+
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package httpd
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdminToken(t *testing.T) {
+	ts := newTestService(t, nil)
+
+	tests := []struct {
+		token         string // AdminToken option
+		authorization string
+		status        int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"", "Bearer ", http.StatusUnauthorized},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		{"secret", "Basic secret", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		srv := newTestServer(t, ts, Options{AdminToken: tt.token})
+
+		var header http.Header
+		if tt.authorization != "" {
+			header = http.Header{"Authorization": {tt.authorization}}
+		}
+		resp := serve(srv, "POST", "/admin/pause", header)
+		if resp.StatusCode != tt.status {
+			t.Errorf("token %q, Authorization %q: status %d, want %d", tt.token, tt.authorization, resp.StatusCode, tt.status)
+		}
+		if paused := resp.StatusCode == http.StatusOK; srv.Paused() != paused {
+			t.Errorf("token %q, Authorization %q: paused %v, want %v", tt.token, tt.authorization, srv.Paused(), paused)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package httpd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The Alexa Smart Home API, version 3.  Only what's needed for
+// discovery and turning climate control on and off is implemented.
+
+type alexaHeader struct {
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	PayloadVersion   string `json:"payloadVersion"`
+	MessageID        string `json:"messageId"`
+	CorrelationToken string `json:"correlationToken,omitempty"`
+}
+
+type alexaScope struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+type alexaEndpoint struct {
+	EndpointID string      `json:"endpointId"`
+	Scope      *alexaScope `json:"scope,omitempty"`
+}
+
+type alexaDirective struct {
+	Directive struct {
+		Header   alexaHeader    `json:"header"`
+		Endpoint *alexaEndpoint `json:"endpoint"`
+		Payload  struct {
+			Scope *alexaScope `json:"scope"` // for discovery
+		} `json:"payload"`
+	} `json:"directive"`
+}
+
+type alexaEvent struct {
+	Header   alexaHeader    `json:"header"`
+	Endpoint *alexaEndpoint `json:"endpoint,omitempty"`
+	Payload  interface{}    `json:"payload"`
+}
+
+type alexaProperty struct {
+	Namespace                 string      `json:"namespace"`
+	Name                      string      `json:"name"`
+	Value                     interface{} `json:"value"`
+	TimeOfSample              time.Time   `json:"timeOfSample"`
+	UncertaintyInMilliseconds int64       `json:"uncertaintyInMilliseconds"`
+}
+
+type alexaContext struct {
+	Properties []alexaProperty `json:"properties"`
+}
+
+type alexaResponse struct {
+	Event   alexaEvent    `json:"event"`
+	Context *alexaContext `json:"context,omitempty"`
+}
+
+type alexaCapability struct {
+	Type       string                `json:"type"`
+	Interface  string                `json:"interface"`
+	Version    string                `json:"version"`
+	Properties *alexaCapabilityProps `json:"properties,omitempty"`
+}
+
+type alexaCapabilityProps struct {
+	Supported           []map[string]string `json:"supported"`
+	ProactivelyReported bool                `json:"proactivelyReported"`
+	Retrievable         bool                `json:"retrievable"`
+}
+
+type alexaDiscoveredEndpoint struct {
+	EndpointID        string            `json:"endpointId"`
+	ManufacturerName  string            `json:"manufacturerName"`
+	FriendlyName      string            `json:"friendlyName"`
+	Description       string            `json:"description"`
+	DisplayCategories []string          `json:"displayCategories"`
+	Capabilities      []alexaCapability `json:"capabilities"`
+}
+
+func alexaMessageID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ServeAlexa handles Alexa Smart Home directives, so the server can
+// back a private smart home skill.  The skill's Lambda function is
+// expected to forward directives to it unchanged.  Each vehicle is
+// discovered as an endpoint whose power state is its climate control.
+func (srv *Server) ServeAlexa(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var d alexaDirective
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "invalid directive", http.StatusBadRequest)
+		return
+	}
+
+	h := d.Directive.Header
+	scope := d.Directive.Payload.Scope
+	if d.Directive.Endpoint != nil {
+		scope = d.Directive.Endpoint.Scope
+	}
+
+	token := srv.options().AlexaToken
+	if token == "" || scope == nil || subtle.ConstantTimeCompare([]byte(scope.Token), []byte(token)) != 1 {
+		srv.alexaError(w, h, d.Directive.Endpoint, "INVALID_AUTHORIZATION_CREDENTIAL", "invalid token")
+		return
+	}
+
+	srv.options().Logger.Printf("Alexa %s.%s directive", h.Namespace, h.Name)
+
+	switch h.Namespace + "." + h.Name {
+	case "Alexa.Discovery.Discover":
+		srv.alexaDiscover(w)
+
+	case "Alexa.ReportState":
+		v := srv.alexaVehicle(w, h, d.Directive.Endpoint)
+		if v == nil {
+			return
+		}
+		srv.alexaRespond(w, h, d.Directive.Endpoint, "StateReport", v)
+
+	case "Alexa.PowerController.TurnOn", "Alexa.PowerController.TurnOff":
+		v := srv.alexaVehicle(w, h, d.Directive.Endpoint)
+		if v == nil {
+			return
+		}
+
+		if serr, _ := srv.breaker.open(); serr != nil {
+			srv.alexaError(w, h, d.Directive.Endpoint, "ENDPOINT_UNREACHABLE", serr.Error())
+			return
+		}
+
+		name, fn := "Climate control on request", srv.climateOn
+		if h.Name == "TurnOff" {
+			name, fn = "Climate control off request", srv.climateOff
+		}
+
+		// Alexa gives up after 8 seconds, so if the vehicle hasn't
+		// responded by the command timeout, optimistically report
+		// the state it was asked for.
+		j, err := srv.startJob(detach(r), v, name, srv.options().CommandTimeout, func(ctx context.Context) error {
+			return fn(ctx, v)
+		})
+		if err != nil {
+			srv.alexaError(w, h, d.Directive.Endpoint, "ENDPOINT_UNREACHABLE", err.Error())
+			return
+		}
+		if j.State == JobPending {
+			v.mu.Lock()
+			v.climate = h.Name == "TurnOn"
+			v.mu.Unlock()
+		}
+		srv.alexaRespond(w, h, d.Directive.Endpoint, "Response", v)
+
+	default:
+		srv.alexaError(w, h, d.Directive.Endpoint, "INVALID_DIRECTIVE", "unsupported directive "+h.Namespace+"."+h.Name)
+	}
+}
+
+func (srv *Server) alexaDiscover(w http.ResponseWriter) {
+	srv.mu.Lock()
+	vehicles := append([]*vehicle(nil), srv.vehicles...)
+	srv.mu.Unlock()
+
+	endpoints := []alexaDiscoveredEndpoint{}
+	for i, v := range vehicles {
+		endpoints = append(endpoints, alexaDiscoveredEndpoint{
+			EndpointID:        v.s.VIN,
+			ManufacturerName:  "Nissan",
+			FriendlyName:      vehicleName(i) + " climate",
+			Description:       "Climate control of vehicle " + v.s.VIN,
+			DisplayCategories: []string{"OTHER"},
+			Capabilities: []alexaCapability{
+				{Type: "AlexaInterface", Interface: "Alexa", Version: "3"},
+				{
+					Type:      "AlexaInterface",
+					Interface: "Alexa.PowerController",
+					Version:   "3",
+					Properties: &alexaCapabilityProps{
+						Supported:   []map[string]string{{"name": "powerState"}},
+						Retrievable: true,
+					},
+				},
+			},
+		})
+	}
+
+	json.NewEncoder(w).Encode(alexaResponse{
+		Event: alexaEvent{
+			Header: alexaHeader{
+				Namespace:      "Alexa.Discovery",
+				Name:           "Discover.Response",
+				PayloadVersion: "3",
+				MessageID:      alexaMessageID(),
+			},
+			Payload: map[string]interface{}{"endpoints": endpoints},
+		},
+	})
+}
+
+// alexaVehicle returns the vehicle the directive is for, or responds
+// with an error and returns nil.
+func (srv *Server) alexaVehicle(w http.ResponseWriter, h alexaHeader, ep *alexaEndpoint) *vehicle {
+	if ep == nil {
+		srv.alexaError(w, h, ep, "INVALID_DIRECTIVE", "missing endpoint")
+		return nil
+	}
+
+	v := srv.lookup(ep.EndpointID)
+	if v == nil {
+		srv.alexaError(w, h, ep, "NO_SUCH_ENDPOINT", "no vehicle "+ep.EndpointID)
+		return nil
+	}
+
+	return v
+}
+
+// alexaRespond responds with an event carrying the state of v's
+// climate control.
+func (srv *Server) alexaRespond(w http.ResponseWriter, h alexaHeader, ep *alexaEndpoint, name string, v *vehicle) {
+	v.mu.Lock()
+	climate, fetched := v.climate, v.fetched
+	v.mu.Unlock()
+
+	state := "OFF"
+	if climate {
+		state = "ON"
+	}
+
+	var uncertainty int64
+	if !fetched.IsZero() {
+		uncertainty = int64(time.Since(fetched) / time.Millisecond)
+	}
+
+	json.NewEncoder(w).Encode(alexaResponse{
+		Event: alexaEvent{
+			Header: alexaHeader{
+				Namespace:        "Alexa",
+				Name:             name,
+				PayloadVersion:   "3",
+				MessageID:        alexaMessageID(),
+				CorrelationToken: h.CorrelationToken,
+			},
+			Endpoint: &alexaEndpoint{EndpointID: ep.EndpointID},
+			Payload:  struct{}{},
+		},
+		Context: &alexaContext{
+			Properties: []alexaProperty{{
+				Namespace:                 "Alexa.PowerController",
+				Name:                      "powerState",
+				Value:                     state,
+				TimeOfSample:              time.Now().UTC(),
+				UncertaintyInMilliseconds: uncertainty,
+			}},
+		},
+	})
+}
+
+// alexaError responds with an ErrorResponse event.  Alexa expects
+// these with a 200 OK status.
+func (srv *Server) alexaError(w http.ResponseWriter, h alexaHeader, ep *alexaEndpoint, typ, message string) {
+	var endpoint *alexaEndpoint
+	if ep != nil {
+		endpoint = &alexaEndpoint{EndpointID: ep.EndpointID}
+	}
+
+	json.NewEncoder(w).Encode(alexaResponse{
+		Event: alexaEvent{
+			Header: alexaHeader{
+				Namespace:        "Alexa",
+				Name:             "ErrorResponse",
+				PayloadVersion:   "3",
+				MessageID:        alexaMessageID(),
+				CorrelationToken: h.CorrelationToken,
+			},
+			Endpoint: endpoint,
+			Payload: map[string]string{
+				"type":    typ,
+				"message": message,
+			},
+		},
+	})
+}
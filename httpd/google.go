@@ -0,0 +1,271 @@
+package httpd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/joeshaw/carwings"
+)
+
+// Google Smart Home fulfillment.  Each vehicle is synced as two
+// devices: a battery, which can only be queried, and its climate
+// control, which can be turned on and off.
+
+const (
+	googleBatterySuffix = "-battery"
+	googleClimateSuffix = "-climate"
+)
+
+type googleRequest struct {
+	RequestID string `json:"requestId"`
+	Inputs    []struct {
+		Intent  string `json:"intent"`
+		Payload struct {
+			Devices  []googleDeviceRef `json:"devices"`  // QUERY
+			Commands []googleCommand   `json:"commands"` // EXECUTE
+		} `json:"payload"`
+	} `json:"inputs"`
+}
+
+type googleDeviceRef struct {
+	ID string `json:"id"`
+}
+
+type googleCommand struct {
+	Devices   []googleDeviceRef `json:"devices"`
+	Execution []struct {
+		Command string `json:"command"`
+		Params  struct {
+			On bool `json:"on"`
+		} `json:"params"`
+	} `json:"execution"`
+}
+
+type googleResponse struct {
+	RequestID string      `json:"requestId"`
+	Payload   interface{} `json:"payload"`
+}
+
+type googleDevice struct {
+	ID              string                 `json:"id"`
+	Type            string                 `json:"type"`
+	Traits          []string               `json:"traits"`
+	Name            map[string]string      `json:"name"`
+	WillReportState bool                   `json:"willReportState"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type googleCommandResult struct {
+	IDs       []string               `json:"ids"`
+	Status    string                 `json:"status"`
+	States    map[string]interface{} `json:"states,omitempty"`
+	ErrorCode string                 `json:"errorCode,omitempty"`
+}
+
+// googleCapacity describes a state of charge in the terms of the
+// EnergyStorage trait.
+func googleCapacity(soc int) string {
+	switch {
+	case soc >= 100:
+		return "FULL"
+	case soc >= 75:
+		return "HIGH"
+	case soc >= 25:
+		return "MEDIUM"
+	case soc >= 10:
+		return "LOW"
+	default:
+		return "CRITICALLY_LOW"
+	}
+}
+
+// ServeGoogleHome handles Google Smart Home fulfillment requests
+// (the SYNC, QUERY, EXECUTE and DISCONNECT intents), so the server can
+// back a Google Assistant smart home action.  States come from the
+// last update, so queries never wait on the vehicle.
+func (srv *Server) ServeGoogleHome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	token := srv.options().GoogleHomeToken
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var req googleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Inputs) == 0 {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	input := req.Inputs[0]
+	srv.options().Logger.Printf("Google Home %s intent", input.Intent)
+
+	var payload interface{}
+	switch input.Intent {
+	case "action.devices.SYNC":
+		payload = srv.googleSync()
+
+	case "action.devices.QUERY":
+		devices := map[string]interface{}{}
+		for _, d := range input.Payload.Devices {
+			devices[d.ID] = srv.googleQuery(d.ID)
+		}
+		payload = map[string]interface{}{"devices": devices}
+
+	case "action.devices.EXECUTE":
+		var results []googleCommandResult
+		for _, cmd := range input.Payload.Commands {
+			for _, d := range cmd.Devices {
+				for _, e := range cmd.Execution {
+					results = append(results, srv.googleExecute(detach(r), d.ID, e.Command, e.Params.On))
+				}
+			}
+		}
+		payload = map[string]interface{}{"commands": results}
+
+	case "action.devices.DISCONNECT":
+		json.NewEncoder(w).Encode(struct{}{})
+		return
+
+	default:
+		payload = map[string]string{"errorCode": "notSupported"}
+	}
+
+	json.NewEncoder(w).Encode(googleResponse{RequestID: req.RequestID, Payload: payload})
+}
+
+func (srv *Server) googleSync() interface{} {
+	srv.mu.Lock()
+	vehicles := append([]*vehicle(nil), srv.vehicles...)
+	srv.mu.Unlock()
+
+	devices := []googleDevice{}
+	for i, v := range vehicles {
+		name := vehicleName(i)
+		devices = append(devices,
+			googleDevice{
+				ID:     v.s.VIN + googleBatterySuffix,
+				Type:   "action.devices.types.CHARGER",
+				Traits: []string{"action.devices.traits.EnergyStorage"},
+				Name:   map[string]string{"name": name + " battery"},
+				Attributes: map[string]interface{}{
+					"isRechargeable":         true,
+					"queryOnlyEnergyStorage": true,
+				},
+			},
+			googleDevice{
+				ID:     v.s.VIN + googleClimateSuffix,
+				Type:   "action.devices.types.AC_UNIT",
+				Traits: []string{"action.devices.traits.OnOff"},
+				Name:   map[string]string{"name": name + " climate"},
+			},
+		)
+	}
+
+	var agentUserID string
+	if len(vehicles) > 0 {
+		agentUserID = vehicles[0].s.VIN
+	}
+
+	return map[string]interface{}{
+		"agentUserId": agentUserID,
+		"devices":     devices,
+	}
+}
+
+// googleVehicle returns the vehicle a device ID belongs to, and
+// whether the device is its battery or its climate control.
+func (srv *Server) googleVehicle(id string) (v *vehicle, climate bool) {
+	switch {
+	case strings.HasSuffix(id, googleBatterySuffix):
+		return srv.lookup(strings.TrimSuffix(id, googleBatterySuffix)), false
+	case strings.HasSuffix(id, googleClimateSuffix):
+		return srv.lookup(strings.TrimSuffix(id, googleClimateSuffix)), true
+	default:
+		return nil, false
+	}
+}
+
+func (srv *Server) googleQuery(id string) map[string]interface{} {
+	v, climate := srv.googleVehicle(id)
+	if v == nil {
+		return map[string]interface{}{"status": "ERROR", "errorCode": "deviceNotFound"}
+	}
+
+	online := true
+	if serr, _ := srv.breaker.open(); serr != nil {
+		online = false
+	}
+
+	v.mu.Lock()
+	bs, running := v.battery, v.climate
+	v.mu.Unlock()
+
+	if climate {
+		return map[string]interface{}{
+			"status": "SUCCESS",
+			"online": online,
+			"on":     running,
+		}
+	}
+
+	return map[string]interface{}{
+		"status":                       "SUCCESS",
+		"online":                       online,
+		"descriptiveCapacityRemaining": googleCapacity(bs.StateOfCharge),
+		"capacityRemaining": []map[string]interface{}{
+			{"rawValue": bs.StateOfCharge, "unit": "PERCENTAGE"},
+		},
+		"isCharging":  bs.ChargingStatus == carwings.NormalCharging || bs.ChargingStatus == carwings.RapidlyCharging,
+		"isPluggedIn": bs.PluginState == carwings.Connected || bs.PluginState == carwings.QCConnected,
+	}
+}
+
+func (srv *Server) googleExecute(ctx context.Context, id, command string, on bool) googleCommandResult {
+	result := googleCommandResult{IDs: []string{id}}
+
+	v, climate := srv.googleVehicle(id)
+	switch {
+	case v == nil:
+		result.Status, result.ErrorCode = "ERROR", "deviceNotFound"
+		return result
+
+	case !climate || command != "action.devices.commands.OnOff":
+		result.Status, result.ErrorCode = "ERROR", "functionNotSupported"
+		return result
+	}
+
+	if serr, _ := srv.breaker.open(); serr != nil {
+		result.Status, result.ErrorCode = "OFFLINE", "deviceOffline"
+		return result
+	}
+
+	name, fn := "Climate control on request", srv.climateOn
+	if !on {
+		name, fn = "Climate control off request", srv.climateOff
+	}
+
+	j, err := srv.startJob(ctx, v, name, srv.options().CommandTimeout, func(ctx context.Context) error {
+		return fn(ctx, v)
+	})
+	switch {
+	case j.State == JobPending:
+		result.Status = "PENDING"
+	case err != nil:
+		result.Status, result.ErrorCode = "ERROR", "transientError"
+		return result
+	default:
+		result.Status = "SUCCESS"
+	}
+
+	result.States = map[string]interface{}{"on": on, "online": true}
+	return result
+}
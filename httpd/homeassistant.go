@@ -0,0 +1,144 @@
+package httpd
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Home Assistant configuration for the server's vehicles, to paste
+// into configuration.yaml.  Each vehicle gets REST sensors for its
+// battery and climate control status, and a template switch turning
+// its climate control on and off through rest_command.
+
+// haScanInterval is how often Home Assistant polls the server.  Reading
+// the statuses only gets what the vehicle last reported to Carwings,
+// so this doesn't wake the vehicle up.
+const haScanInterval = 5 * time.Minute
+
+type haVehicle struct {
+	Name  string // as shown in Home Assistant
+	Slug  string // the object ID Home Assistant derives from Name
+	ID    string // prefix for unique IDs and commands
+	URL   string // of the vehicle's endpoints, ending in /
+	Range string // value_template expression converting meters
+	Units string
+	Scan  int
+}
+
+var haTemplate = template.Must(template.New("ha").Parse(`# Generated by carwings from {{ .Base }}ha/discovery
+rest:
+{{- range .Vehicles }}
+  - resource: {{ .URL }}battery
+    scan_interval: {{ .Scan }}
+    sensor:
+      - name: "{{ .Name }} battery"
+        unique_id: {{ .ID }}_soc
+        device_class: battery
+        unit_of_measurement: "%"
+        value_template: "{{ "{{" }} value_json.StateOfCharge {{ "}}" }}"
+      - name: "{{ .Name }} range"
+        unique_id: {{ .ID }}_range
+        device_class: distance
+        unit_of_measurement: {{ .Units }}
+        value_template: "{{ "{{" }} {{ .Range }} {{ "}}" }}"
+    binary_sensor:
+      - name: "{{ .Name }} plugged in"
+        unique_id: {{ .ID }}_plugged_in
+        device_class: plug
+        value_template: "{{ "{{" }} value_json.PluginState != 'NOT_CONNECTED' {{ "}}" }}"
+      - name: "{{ .Name }} charging"
+        unique_id: {{ .ID }}_charging
+        device_class: battery_charging
+        value_template: "{{ "{{" }} value_json.ChargingStatus in ['NORMAL_CHARGING', 'RAPIDLY_CHARGING'] {{ "}}" }}"
+  - resource: {{ .URL }}climate
+    scan_interval: {{ .Scan }}
+    binary_sensor:
+      - name: "{{ .Name }} climate control"
+        unique_id: {{ .ID }}_climate_running
+        device_class: running
+        value_template: "{{ "{{" }} value_json.Running {{ "}}" }}"
+{{- end }}
+
+rest_command:
+{{- range .Vehicles }}
+  {{ .ID }}_climate_on:
+    url: {{ .URL }}climate/on
+    method: POST
+  {{ .ID }}_climate_off:
+    url: {{ .URL }}climate/off
+    method: POST
+{{- end }}
+
+switch:
+  - platform: template
+    switches:
+{{- range .Vehicles }}
+      {{ .ID }}_climate:
+        unique_id: {{ .ID }}_climate
+        friendly_name: "{{ .Name }} climate control"
+        value_template: "{{ "{{" }} is_state('binary_sensor.{{ .Slug }}_climate_control', 'on') {{ "}}" }}"
+        turn_on:
+          service: rest_command.{{ .ID }}_climate_on
+        turn_off:
+          service: rest_command.{{ .ID }}_climate_off
+{{- end }}
+`))
+
+// ServeHomeAssistant serves the Home Assistant configuration for the
+// server's vehicles.  The URLs in it are based on the one it was
+// requested at, so they work wherever the server is mounted.
+func (srv *Server) ServeHomeAssistant(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		base := requestBase(r, "ha/discovery")
+
+		units, rng := "mi", "(value_json.CruisingRangeACOff / 1609.344) | round(1)"
+		if srv.options().Units == unitsKM {
+			units, rng = "km", "(value_json.CruisingRangeACOff / 1000) | round(1)"
+		}
+
+		var data struct {
+			Base     string
+			Vehicles []haVehicle
+		}
+		data.Base = base
+		for i, s := range srv.Sessions() {
+			name := vehicleName(i)
+			data.Vehicles = append(data.Vehicles, haVehicle{
+				Name:  name,
+				Slug:  strings.ToLower(strings.ReplaceAll(name, " ", "_")),
+				ID:    "carwings_" + strings.ToLower(s.VIN),
+				URL:   base + "vehicles/" + url.PathEscape(s.VIN) + "/",
+				Range: rng,
+				Units: units,
+				Scan:  int(haScanInterval / time.Second),
+			})
+		}
+
+		w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+		haTemplate.Execute(w, data)
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// requestBase returns the absolute URL r was made to, up to the given
+// path.  It uses the original request URI, since any prefix the
+// server is mounted under has been stripped from r.URL.
+func requestBase(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	p := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		p = u.Path
+	}
+	return scheme + "://" + r.Host + strings.TrimSuffix(p, path)
+}
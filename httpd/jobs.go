@@ -0,0 +1,136 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// Job states
+const (
+	JobPending   = "pending"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a command sent to a vehicle, such as turning on climate
+// control, which the vehicle can take minutes to carry out.  POSTs to
+// the command endpoints respond with the Job, and GET /jobs/{id}
+// reports on it until it has been finished for the JobRetention
+// option.
+type Job struct {
+	ID       string
+	Name     string
+	VIN      string
+	State    string
+	Result   string `json:",omitempty"` // operationResult of a command the vehicle failed
+	Error    string `json:",omitempty"`
+	Created  time.Time
+	Finished *time.Time `json:",omitempty"`
+
+	done chan struct{} // closed when finished
+	err  error         // of the command, once finished
+}
+
+// jobs are the jobs of a Server, by ID.
+type jobs struct {
+	mu   sync.Mutex
+	byID map[string]*Job
+}
+
+// add starts keeping track of a new pending job, and forgets the jobs
+// that finished longer than retention ago.
+func (js *jobs) add(name, vin string, retention time.Duration) *Job {
+	now := time.Now()
+	j := &Job{ID: newRequestID(), Name: name, VIN: vin, State: JobPending, Created: now, done: make(chan struct{})}
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if js.byID == nil {
+		js.byID = map[string]*Job{}
+	}
+	for id, old := range js.byID {
+		if old.Finished != nil && now.Sub(*old.Finished) > retention {
+			delete(js.byID, id)
+		}
+	}
+	js.byID[j.ID] = j
+	return j
+}
+
+// finish records the outcome of the job.
+func (js *jobs) finish(j *Job, err error) {
+	now := time.Now()
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	defer close(j.done)
+
+	j.Finished, j.err = &now, err
+	if err == nil {
+		j.State = JobSucceeded
+		return
+	}
+
+	j.State, j.Error = JobFailed, err.Error()
+	var oerr *carwings.OperationError
+	if errors.As(err, &oerr) {
+		j.Result = oerr.Result
+	}
+}
+
+// get returns a copy of the job with the given ID, if it is still
+// kept.
+func (js *jobs) get(id string) (Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j, ok := js.byID[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// startJob runs fn with queueCommand as a new job.  It waits up to
+// wait for fn, and returns the job as it is then, with fn's error if
+// it has finished.  Every command the server sends a vehicle is a job,
+// so they can all be followed with GET /jobs/{id}.
+func (srv *Server) startJob(ctx context.Context, v *vehicle, name string, wait time.Duration, fn func(context.Context) error) (Job, error) {
+	j := srv.jobs.add(name, v.s.VIN, srv.options().JobRetention)
+	go func() {
+		srv.jobs.finish(j, <-srv.queueCommand(ctx, v, name, fn))
+	}()
+
+	select {
+	case <-j.done:
+	case <-time.After(wait):
+	}
+
+	job, _ := srv.jobs.get(j.ID)
+	return job, job.err
+}
+
+// ServeJobs serves GET /jobs/{id}, the state of a command job.
+func (srv *Server) ServeJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		j, ok := srv.jobs.get(strings.TrimPrefix(r.URL.Path, "/jobs/"))
+		if !ok {
+			http.Error(w, "unknown job", http.StatusNotFound)
+			return
+		}
+
+		WriteJSON(w, r, j, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
@@ -0,0 +1,134 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// getJob returns the job at the Location of resp.
+func getJob(t *testing.T, srv *Server, resp *http.Response) (Job, int) {
+	t.Helper()
+
+	u, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing Location: %v", err)
+	}
+	jr := serve(srv, "GET", u.Path, nil)
+	if jr.StatusCode != http.StatusOK {
+		return Job{}, jr.StatusCode
+	}
+	var j Job
+	decode(t, jr, &j)
+	return j, jr.StatusCode
+}
+
+// waitJob returns the job at the Location of resp once it has
+// finished.
+func waitJob(t *testing.T, srv *Server, resp *http.Response) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, status := getJob(t, srv, resp)
+		if status != http.StatusOK {
+			t.Fatalf("GET job: status %d", status)
+		}
+		if j.State != JobPending || time.Now().After(deadline) {
+			return j
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	// The vehicle responds to the first command once release is
+	// closed, and rejects the second
+	release := make(chan struct{})
+	var ts *testService
+	ts = newTestService(t, map[string]testHandler{
+		"BatteryRemoteChargingRequest": func(url.Values) string {
+			if ts.count("BatteryRemoteChargingRequest") == 1 {
+				<-release
+				return `{"status":200}`
+			}
+			return `{"status":400,"message":"rejected"}`
+		},
+	})
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	srv := newTestServer(t, ts, Options{
+		CommandTimeout: 10 * time.Millisecond,
+		JobRetention:   time.Millisecond,
+	})
+
+	resp := serve(srv, "POST", "/charging/on", nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /charging/on: status %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	var started Job
+	decode(t, resp, &started)
+	if started.State != JobPending || started.VIN != "VIN123" {
+		t.Errorf("started job %+v, want a pending job for VIN123", started)
+	}
+
+	j, status := getJob(t, srv, resp)
+	if status != http.StatusOK || j.ID != started.ID || j.State != JobPending {
+		t.Fatalf("GET job: %d %+v, want pending job %s", status, j, started.ID)
+	}
+
+	close(release)
+	if j := waitJob(t, srv, resp); j.State != JobSucceeded || j.Finished == nil {
+		t.Fatalf("job after the vehicle responded: %+v, want succeeded", j)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	failed := serve(srv, "POST", "/vehicles/VIN456/charging/on", nil)
+	if j := waitJob(t, srv, failed); j.State != JobFailed || j.VIN != "VIN456" || j.Error == "" {
+		t.Errorf("failed job %+v, want a failed job for VIN456 with an error", j)
+	}
+
+	// Starting that job forgot the first, which finished longer than
+	// JobRetention ago
+	if _, status := getJob(t, srv, resp); status != http.StatusNotFound {
+		t.Errorf("GET expired job: status %d, want %d", status, http.StatusNotFound)
+	}
+	if resp := serve(srv, "GET", "/jobs/nonexistent", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET unknown job: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestCommandIsJob(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{})
+	srv := newTestServer(t, ts, Options{})
+
+	// The commands of Alexa, Google Home, Slack and the program
+	// embedding the server can be followed like the server's own
+	err := srv.Command(context.Background(), "VIN456", "Test request", func(context.Context, *carwings.Session) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+
+	srv.jobs.mu.Lock()
+	defer srv.jobs.mu.Unlock()
+
+	if len(srv.jobs.byID) != 1 {
+		t.Fatalf("%d jobs, want 1", len(srv.jobs.byID))
+	}
+	for _, j := range srv.jobs.byID {
+		if j.Name != "Test request" || j.VIN != "VIN456" || j.State != JobSucceeded {
+			t.Errorf("job %+v, want a succeeded Test request for VIN456", *j)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// metric is a Prometheus metric family.
+type metric struct {
+	name, help string
+	typ        string // gauge if empty
+	samples    []sample
+}
+
+type sample struct {
+	suffix string // of the name, like _bucket for histograms
+	labels string // already formatted, like `vin="X"`
+	value  float64
+}
+
+func (m *metric) add(labels string, value float64) {
+	m.samples = append(m.samples, sample{"", labels, value})
+}
+
+func (m *metric) addSuffix(suffix, labels string, value float64) {
+	m.samples = append(m.samples, sample{suffix, labels, value})
+}
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of
+// the request duration histogram.  The Carwings service is slow, so
+// they go up to a minute.
+var latencyBuckets = []float64{0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// UpstreamStats counts the requests made to the Carwings service, by
+// endpoint and result, and how long they took.  Pass its Observe
+// method to carwings.WithRequestHook for each Session, and the stats
+// to the server with Options.Upstream, to include them in
+// ServeMetrics.  The results distinguish problems reaching the service
+// (transport) from the service itself failing (unavailable, invalid)
+// and from slow responses.
+type UpstreamStats struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+	relogins  int
+}
+
+type endpointStats struct {
+	results map[string]int
+	buckets []int // counts for latencyBuckets, not cumulative
+	count   int
+	sum     float64
+}
+
+// NewUpstreamStats returns empty UpstreamStats.
+func NewUpstreamStats() *UpstreamStats {
+	return &UpstreamStats{endpoints: map[string]*endpointStats{}}
+}
+
+// Observe records a request to endpoint that took elapsed and failed
+// with err, if not nil.  It is a carwings.RequestHook.
+func (u *UpstreamStats) Observe(endpoint string, elapsed time.Duration, err error) {
+	endpoint = strings.TrimSuffix(endpoint, ".php")
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	es := u.endpoints[endpoint]
+	if es == nil {
+		es = &endpointStats{results: map[string]int{}, buckets: make([]int, len(latencyBuckets))}
+		u.endpoints[endpoint] = es
+	}
+
+	result := requestResult(err)
+	es.results[result]++
+	if result == "not_logged_in" {
+		u.relogins++
+	}
+
+	secs := elapsed.Seconds()
+	es.count++
+	es.sum += secs
+	for i, le := range latencyBuckets {
+		if secs <= le {
+			es.buckets[i]++
+			break
+		}
+	}
+}
+
+// requestResult classifies the error a request failed with for the
+// result label.
+func requestResult(err error) string {
+	var serr *carwings.ServiceError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, carwings.ErrNotLoggedIn):
+		return "not_logged_in"
+	case errors.Is(err, carwings.ErrTransport):
+		return "transport"
+	case errors.As(err, &serr):
+		return "unavailable"
+	case errors.Is(err, carwings.ErrDecode):
+		return "invalid"
+	default:
+		return "error"
+	}
+}
+
+// metrics returns the stats as metric families.
+func (u *UpstreamStats) metrics() []*metric {
+	var (
+		requests = &metric{name: "carwings_upstream_requests_total", help: "Requests to the Carwings service, by endpoint and result.", typ: "counter"}
+		duration = &metric{name: "carwings_upstream_request_duration_seconds", help: "How long requests to the Carwings service took.", typ: "histogram"}
+		relogins = &metric{name: "carwings_upstream_relogins_total", help: "Times the session expired and had to log in again.", typ: "counter"}
+	)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	names := make([]string, 0, len(u.endpoints))
+	for name := range u.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		es := u.endpoints[name]
+		label := fmt.Sprintf("endpoint=%q", name)
+
+		results := make([]string, 0, len(es.results))
+		for r := range es.results {
+			results = append(results, r)
+		}
+		sort.Strings(results)
+		for _, r := range results {
+			requests.add(fmt.Sprintf("%s,result=%q", label, r), float64(es.results[r]))
+		}
+
+		var cumulative int
+		for i, le := range latencyBuckets {
+			cumulative += es.buckets[i]
+			duration.addSuffix("_bucket", fmt.Sprintf("%s,le=%q", label, strconv.FormatFloat(le, 'g', -1, 64)), float64(cumulative))
+		}
+		duration.addSuffix("_bucket", label+`,le="+Inf"`, float64(es.count))
+		duration.addSuffix("_sum", label, es.sum)
+		duration.addSuffix("_count", label, float64(es.count))
+	}
+	relogins.add("", float64(u.relogins))
+
+	return []*metric{requests, duration, relogins}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ServeMetrics serves the data from the last update of each vehicle in
+// the Prometheus text exposition format.  It never makes requests to
+// the Carwings service, so it is cheap to scrape often.
+func (srv *Server) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	var (
+		soc        = &metric{name: "carwings_battery_state_of_charge_percent", help: "Battery state of charge."}
+		remaining  = &metric{name: "carwings_battery_remaining_wh", help: "Energy remaining in the battery."}
+		capacity   = &metric{name: "carwings_battery_capacity", help: "Battery capacity, as reported by the vehicle."}
+		cruising   = &metric{name: "carwings_cruising_range_meters", help: "Estimated cruising range."}
+		plugged    = &metric{name: "carwings_plugged_in", help: "Whether the vehicle is plugged in."}
+		charging   = &metric{name: "carwings_charging", help: "Whether the vehicle is charging."}
+		power      = &metric{name: "carwings_charging_power_kw", help: "Estimated charging power."}
+		voltage    = &metric{name: "carwings_charge_voltage_volts", help: "Supply voltage of the charger, for vehicles that report it."}
+		climate    = &metric{name: "carwings_climate_running", help: "Whether climate control is running."}
+		timestamp  = &metric{name: "carwings_battery_status_timestamp_seconds", help: "When the vehicle reported its battery status."}
+		lastUpdate = &metric{name: "carwings_last_update_timestamp_seconds", help: "When the battery status was last retrieved."}
+		upstreamUp = &metric{name: "carwings_upstream_up", help: "Whether the Carwings service is available."}
+		paused     = &metric{name: "carwings_updates_paused", help: "Whether background updates are paused."}
+		loggedIn   = &metric{name: "carwings_session_login_timestamp_seconds", help: "When the session logged in."}
+		contact    = &metric{name: "carwings_last_contact_timestamp_seconds", help: "When the Carwings service last answered a request successfully."}
+		allMetrics = []*metric{soc, remaining, capacity, cruising, plugged, charging, power, voltage, climate, timestamp, lastUpdate, upstreamUp, paused, loggedIn, contact}
+	)
+
+	srv.mu.Lock()
+	vehicles := append([]*vehicle(nil), srv.vehicles...)
+	srv.mu.Unlock()
+
+	sort.Slice(vehicles, func(i, j int) bool { return vehicles[i].s.VIN < vehicles[j].s.VIN })
+
+	for _, v := range vehicles {
+		v.mu.Lock()
+		bs, fetched, climateRunning := v.battery, v.fetched, v.climate
+		v.mu.Unlock()
+
+		vin := fmt.Sprintf("vin=%q", v.s.VIN)
+		if t := v.s.LoggedIn(); !t.IsZero() {
+			loggedIn.add(vin, float64(t.Unix()))
+		}
+		if t := v.s.LastContact(); !t.IsZero() {
+			contact.add(vin, float64(t.Unix()))
+		}
+
+		if fetched.IsZero() {
+			continue
+		}
+
+		soc.add(vin, float64(bs.StateOfCharge))
+		remaining.add(vin, float64(bs.RemainingWH))
+		capacity.add(vin, float64(bs.Capacity))
+		cruising.add(vin+`,ac="off"`, float64(bs.CruisingRangeACOff))
+		cruising.add(vin+`,ac="on"`, float64(bs.CruisingRangeACOn))
+		plugged.add(vin, boolValue(bs.PluginState != carwings.NotConnected))
+		charging.add(vin, boolValue(bs.ChargingStatus == carwings.NormalCharging || bs.ChargingStatus == carwings.RapidlyCharging))
+		power.add(vin, bs.ChargingPower)
+		if bs.ChargeVoltage > 0 {
+			voltage.add(vin, float64(bs.ChargeVoltage))
+		}
+		climate.add(vin, boolValue(climateRunning))
+		timestamp.add(vin, float64(bs.Timestamp.Unix()))
+		lastUpdate.add(vin, float64(fetched.Unix()))
+	}
+
+	serr, _ := srv.breaker.open()
+	upstreamUp.add("", boolValue(serr == nil))
+	paused.add("", boolValue(srv.Paused()))
+
+	if u := srv.options().Upstream; u != nil {
+		allMetrics = append(allMetrics, u.metrics()...)
+	}
+
+	var buf bytes.Buffer
+	for _, m := range allMetrics {
+		if len(m.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "# HELP %s %s\n", m.name, m.help)
+		typ := m.typ
+		if typ == "" {
+			typ = "gauge"
+		}
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", m.name, typ)
+		for _, smp := range m.samples {
+			if smp.labels == "" {
+				fmt.Fprintf(&buf, "%s%s %g\n", m.name, smp.suffix, smp.value)
+			} else {
+				fmt.Fprintf(&buf, "%s%s{%s} %g\n", m.name, smp.suffix, smp.labels, smp.value)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
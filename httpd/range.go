@@ -0,0 +1,135 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+const (
+	unitsMiles = "miles"
+	unitsKM    = "km"
+)
+
+func metersToUnits(units string, meters int) float64 {
+	switch units {
+	case unitsMiles:
+		const milesPerMeter = 0.000621371
+		return float64(meters) * milesPerMeter
+
+	case unitsKM:
+		return float64(meters) / 1000
+	}
+
+	panic("should not be reached")
+}
+
+// efficiencyCache holds recent monthly statistics for personalized
+// range estimates, which change slowly and are slow to fetch.
+type efficiencyCache struct {
+	mu      sync.Mutex
+	fetched time.Time
+	stats   []carwings.MonthlyStatistics
+}
+
+func (c *efficiencyCache) get(ctx context.Context, s *carwings.Session) ([]carwings.MonthlyStatistics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.fetched) < time.Hour {
+		return c.stats, nil
+	}
+
+	// The current month and the two before it
+	now := time.Now()
+	stats := make([]carwings.MonthlyStatistics, 3)
+	errs := make([]error, len(stats))
+
+	var wg sync.WaitGroup
+	for i := range stats {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			month := time.Date(now.Year(), now.Month()-time.Month(i), 15, 12, 0, 0, 0, now.Location())
+			stats[i], errs[i] = s.GetMonthlyStatisticsContext(ctx, month)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.stats, c.fetched = stats, time.Now()
+	return stats, nil
+}
+
+func (srv *Server) handleRange(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		units := srv.options().Units
+		if u := r.FormValue("units"); u != "" {
+			units = u
+		}
+		if units != unitsMiles && units != unitsKM {
+			http.Error(w, "units must be miles or km", http.StatusBadRequest)
+			return
+		}
+
+		ac := r.FormValue("ac")
+		if ac == "" {
+			ac = "off"
+		}
+		if ac != "on" && ac != "off" {
+			http.Error(w, "ac must be on or off", http.StatusBadRequest)
+			return
+		}
+
+		status, err := srv.batteryStatus(r.Context(), v)
+		if err != nil {
+			srv.error(w, err)
+			return
+		}
+
+		stats, err := v.effs.get(r.Context(), v.s)
+		if err != nil {
+			srv.error(w, err)
+			return
+		}
+
+		resp := struct {
+			Timestamp         time.Time
+			Units             string
+			AC                string
+			CruisingRange     float64
+			PersonalizedRange float64 `json:",omitempty"`
+		}{
+			Timestamp: status.Timestamp,
+			Units:     units,
+			AC:        ac,
+		}
+
+		resp.CruisingRange = metersToUnits(units, status.CruisingRangeACOff)
+		if ac == "on" {
+			resp.CruisingRange = metersToUnits(units, status.CruisingRangeACOn)
+		}
+
+		if est, err := carwings.EstimateRange(status, stats...); err == nil {
+			resp.PersonalizedRange = metersToUnits(units, est.ACOff)
+			if ac == "on" {
+				resp.PersonalizedRange = metersToUnits(units, est.ACOn)
+			}
+		}
+
+		WriteJSON(w, r, resp, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
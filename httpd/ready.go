@@ -0,0 +1,89 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// readyRetryAfter is how long load balancers are told to wait before
+// checking again whether a server that isn't ready yet is.
+const readyRetryAfter = 10 * time.Second
+
+// readiness is closed once the server is ready to serve requests.
+type readiness struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func newReadiness() *readiness {
+	return &readiness{ch: make(chan struct{})}
+}
+
+func (rd *readiness) set() {
+	rd.once.Do(func() { close(rd.ch) })
+}
+
+// ready reports whether the server is ready: once it has retrieved a
+// battery status, or straight away if it doesn't update the vehicles
+// itself, as there's nothing to wait for.
+func (srv *Server) ready() bool {
+	if srv.options().UpdateInterval <= 0 {
+		return true
+	}
+	select {
+	case <-srv.readiness.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitReady waits up to the ReadyTimeout option for the server to be
+// ready.
+func (srv *Server) waitReady(ctx context.Context) {
+	timeout := srv.options().ReadyTimeout
+	if timeout <= 0 || srv.ready() {
+		return
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-srv.readiness.ch:
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// ServeReady responds with 200 OK once the server has logged in and
+// retrieved its first battery status, and with 503 Service
+// Unavailable until then, so load balancers don't send it requests
+// it would have to make Carwings answer first.  With ping=1, it also
+// checks that Carwings still accepts each vehicle's session.
+func (srv *Server) ServeReady(w http.ResponseWriter, r *http.Request) {
+	if !srv.ready() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(readyRetryAfter/time.Second)))
+		http.Error(w, "waiting for the first battery status", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.FormValue("ping") == "1" {
+		for _, s := range srv.Sessions() {
+			if err := s.PingContext(r.Context()); err != nil {
+				http.Error(w, s.VIN+": "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+	}
+
+	w.Write([]byte("ok\n"))
+}
+
+// ServeHealth responds with 200 OK as long as the server is running.
+func (srv *Server) ServeHealth(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
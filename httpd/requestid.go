@@ -0,0 +1,109 @@
+package httpd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/joeshaw/carwings"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// traceHeaders are the headers of incoming requests passed on to the
+// Carwings service: W3C Trace Context and Zipkin's B3.
+var traceHeaders = []string{
+	"Traceparent",
+	"Tracestate",
+	"B3",
+	"X-B3-Traceid",
+	"X-B3-Spanid",
+	"X-B3-Parentspanid",
+	"X-B3-Sampled",
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request being served with ctx, or
+// "" if there isn't one.  It is taken from the request's X-Request-ID
+// header if it has a usable one, such as from a reverse proxy, and
+// generated otherwise.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID gives r a request ID, which is sent back in the
+// response's X-Request-ID header, and arranges for it and any trace
+// headers to be passed on with requests to the Carwings service.  A
+// request already given one by a server this one is mounted in keeps
+// it.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	if RequestID(r.Context()) != "" {
+		return r
+	}
+
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+
+	return r.WithContext(requestContext(r.Context(), r, id))
+}
+
+// requestContext returns a copy of parent carrying the request ID and
+// trace headers of r.
+func requestContext(parent context.Context, r *http.Request, id string) context.Context {
+	h := http.Header{}
+	h.Set(requestIDHeader, id)
+	for _, name := range traceHeaders {
+		if v := r.Header.Values(name); len(v) > 0 {
+			h[name] = v
+		}
+	}
+
+	ctx := context.WithValue(parent, requestIDKey{}, id)
+	return carwings.ContextWithHeader(ctx, h)
+}
+
+// detach returns a context with the request ID and trace headers of r
+// that isn't canceled when r is, for commands that carry on after the
+// response is sent.
+func detach(r *http.Request) context.Context {
+	return requestContext(context.Background(), r, RequestID(r.Context()))
+}
+
+// validRequestID reports whether id is safe to log and pass on: up to
+// 128 printable ASCII characters without spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// requestPrefix returns the prefix for log messages about the request
+// being served with ctx.
+func requestPrefix(ctx context.Context) string {
+	return logPrefix(RequestID(ctx))
+}
+
+func logPrefix(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "[" + id + "] "
+}
@@ -0,0 +1,94 @@
+package httpd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// WriteJSON writes v as the JSON response to r, like the Server's own
+// endpoints, for handlers registered with Handle or HandleFunc.  The response has an
+// ETag, which is etag if it isn't empty or otherwise derived from the
+// body, and requests with a matching If-None-Match get a 304 Not
+// Modified without one.  The fields and naming query parameters
+// reshape the body, as described for shape.  Bodies are gzip
+// compressed for clients that accept it.
+func WriteJSON(w http.ResponseWriter, r *http.Request, v interface{}, etag string) {
+	// The ETag is known without encoding v, so a poller that's
+	// already up to date costs nothing more than the check.
+	if etag != "" {
+		etag = `W/"` + etag + `"`
+		if notModified(w, r, etag) {
+			return
+		}
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err = shape(append(body, '\n'), r.FormValue("fields"), r.FormValue("naming"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = `W/"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`
+		if notModified(w, r, etag) {
+			return
+		}
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Add("Vary", "Accept-Encoding")
+
+	if acceptsGzip(r) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+
+		h.Set("Content-Encoding", "gzip")
+		body = buf.Bytes()
+	}
+
+	w.Write(body)
+}
+
+// notModified sets the ETag of the response, and writes a 304 Not
+// Modified and returns true if it matches the request's If-None-Match.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		// Weak comparison, as all our ETags are weak
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the client accepts gzip compressed
+// responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		// gzip;q=0 means anything but gzip
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package httpd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNotModified(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"BatteryStatusRecordsRequest": respond(batteryResponse(91)),
+	})
+	srv := newTestServer(t, ts, Options{})
+
+	// The battery status has an ETag from its timestamps, and
+	// /vehicles one from its body
+	for _, target := range []string{"/battery", "/vehicles"} {
+		resp := serve(srv, "GET", target, nil)
+		etag := resp.Header.Get("ETag")
+		if resp.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("GET %s: status %d with ETag %q, want 200 with an ETag", target, resp.StatusCode, etag)
+		}
+
+		tests := []struct {
+			ifNoneMatch string
+			status      int
+		}{
+			{etag, http.StatusNotModified},
+			{`"other", ` + etag, http.StatusNotModified},
+			{etag[len("W/"):], http.StatusNotModified},
+			{"*", http.StatusNotModified},
+			{`W/"other"`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			resp := serve(srv, "GET", target, http.Header{"If-None-Match": {tt.ifNoneMatch}})
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Errorf("GET %s with If-None-Match %s: status %d, want %d", target, tt.ifNoneMatch, resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get("ETag"); got != etag {
+				t.Errorf("GET %s with If-None-Match %s: ETag %q, want %q", target, tt.ifNoneMatch, got, etag)
+			}
+			if tt.status == http.StatusNotModified && len(body) != 0 {
+				t.Errorf("GET %s with If-None-Match %s: body %q, want none", target, tt.ifNoneMatch, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,941 @@
+// Package httpd provides an HTTP interface to a vehicle through a
+// carwings.Session.  It serves endpoints for retrieving battery and
+// climate info, starting charging, and toggling the climate control
+// system, and periodically asks the vehicle for updated data.
+//
+// A Server can serve multiple vehicles, from one or more accounts.
+// Each vehicle's endpoints are available under /vehicles/{vin}/, and
+// /vehicles lists them.  The endpoints are also served at the top level
+// for the first vehicle added.
+//
+// A Server is an http.Handler, so it can be run on its own or embedded
+// into another web application.  Extra handlers and middleware can be
+// registered on it to extend the endpoints it serves.  Each Server has
+// its own mux and registers nothing on http.DefaultServeMux, so any
+// number of them can run in one program, such as in tests.
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// Options configures a Server.
+type Options struct {
+	// How often to ask the vehicle for updated data while Run is
+	// running.  Zero disables updates.
+	UpdateInterval time.Duration
+
+	// If not zero, ActiveUpdateInterval is used instead of
+	// UpdateInterval for a vehicle while it is charging or running
+	// its climate control, and IdleUpdateInterval while it is
+	// unplugged with its climate control off.
+	ActiveUpdateInterval time.Duration
+	IdleUpdateInterval   time.Duration
+
+	// No updates are made by Run during QuietHours, so the vehicle's
+	// telematics unit isn't woken up, draining its 12V battery.
+	// POST /update requests are refused too, unless they pass
+	// override=1.
+	QuietHours QuietHours
+
+	// How long to wait for the vehicle to respond to an update
+	// request before giving up.  Defaults to 1 minute.
+	UpdateTimeout time.Duration
+
+	// How long to wait for a command like turning on climate
+	// control to finish before responding with 202 Accepted and the
+	// job to follow it with.  Defaults to 5 seconds.
+	CommandTimeout time.Duration
+
+	// How long to keep command jobs for GET /jobs/{id} after they
+	// finish.  Defaults to 1 hour.
+	JobRetention time.Duration
+
+	// Default units for distances, "miles" or "km".  Defaults to
+	// miles.
+	Units string
+
+	// OnBatteryStatus, if not nil, is called with every battery
+	// status the server retrieves, along with the VIN of the vehicle
+	// it is for.
+	OnBatteryStatus func(vin string, bs carwings.BatteryStatus)
+
+	// OnClimateStatus, if not nil, is called with the climate control
+	// status retrieved by every update, along with the VIN of the
+	// vehicle it is for.
+	OnClimateStatus func(vin string, cs carwings.ClimateStatus)
+
+	// MonthlyStatistics, if not nil, retrieves the monthly
+	// statistics behind /trips instead of the Session's
+	// GetMonthlyStatisticsContext, so they can come from a local
+	// store.
+	MonthlyStatistics func(ctx context.Context, s *carwings.Session, month time.Time) (carwings.MonthlyStatistics, error)
+
+	// Upstream, if not nil, has the stats of the requests the
+	// Sessions make to the Carwings service, which ServeMetrics
+	// includes.
+	Upstream *UpstreamStats
+
+	// AlexaToken is the token Alexa Smart Home directives to
+	// ServeAlexa must carry, as set up by the skill's account
+	// linking.  If empty, ServeAlexa refuses all directives.
+	AlexaToken string
+
+	// GoogleHomeToken is the bearer token Google Smart Home
+	// fulfillment requests to ServeGoogleHome must carry, as set up
+	// by the action's account linking.  If empty, ServeGoogleHome
+	// refuses all requests.
+	GoogleHomeToken string
+
+	// AdminToken is the bearer token requests to the /admin
+	// endpoints must carry.  If empty, they refuse all requests.
+	AdminToken string
+
+	// SlackSigningSecret is the signing secret of the Slack app whose
+	// slash command posts to ServeSlack.  If empty, ServeSlack
+	// refuses all requests.
+	SlackSigningSecret string
+
+	// If not zero, requests to the vehicle endpoints that arrive
+	// before the server is ready, as reported by ServeReady, wait up
+	// to ReadyTimeout for it instead of going straight to Carwings.
+	ReadyTimeout time.Duration
+
+	// Logger for requests and errors.  If nil, nothing is logged.
+	Logger *log.Logger
+}
+
+// QuietHours is a daily period, as offsets from midnight in the local
+// time zone.  It may wrap around midnight.  The zero value is an empty
+// period.
+type QuietHours struct {
+	Start, End time.Duration
+}
+
+// Contains reports whether t is within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	if q.Start == q.End {
+		return false
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// Server serves HTTP endpoints for one or more vehicles.
+type Server struct {
+	mux     *http.ServeMux
+	handler http.Handler
+
+	optsMu  sync.Mutex
+	opts    Options
+	changed chan struct{} // signals Run that opts or vehicles changed
+
+	// vehicles are ordered by when they were added; the first is
+	// served at the top level.
+	mu       sync.Mutex
+	vehicles []*vehicle
+	paused   bool // whether Run skips updates
+
+	breaker   breaker
+	readiness *readiness
+	jobs      jobs
+}
+
+// vehicle is a vehicle served by a Server.
+type vehicle struct {
+	s    *carwings.Session
+	effs efficiencyCache
+
+	// The battery and climate status from the last update, and
+	// the Poller updating the vehicle while Run is running
+	mu      sync.Mutex
+	poller  *carwings.Poller
+	battery carwings.BatteryStatus
+	fetched time.Time
+	climate bool // whether climate control is running
+
+	// The last error updating the vehicle, for /admin/session
+	lastErr     string
+	lastErrTime time.Time
+
+	// cmdMu queues commands, so they run one at a time
+	cmdMu sync.Mutex
+}
+
+// vehicleName is the name voice assistants know the i'th vehicle by.
+func vehicleName(i int) string {
+	if i == 0 {
+		return "Car"
+	}
+	return "Car " + strconv.Itoa(i+1)
+}
+
+type vehicleHandler func(v *vehicle, w http.ResponseWriter, r *http.Request)
+
+// New creates a Server for the vehicle of the Session, which must
+// already be connected.  More vehicles can be added with Add.
+func New(s *carwings.Session, opts Options) *Server {
+	srv := &Server{
+		mux:       http.NewServeMux(),
+		changed:   make(chan struct{}, 1),
+		readiness: newReadiness(),
+	}
+	srv.handler = srv.mux
+	srv.SetOptions(opts)
+	srv.Add(s)
+
+	for path, h := range srv.routes() {
+		srv.mux.HandleFunc(path, srv.defaultVehicle(h))
+	}
+	srv.mux.HandleFunc("/metrics", srv.ServeMetrics)
+	srv.mux.HandleFunc("/healthz", srv.ServeHealth)
+	srv.mux.HandleFunc("/readyz", srv.ServeReady)
+	srv.mux.HandleFunc("/ha/discovery", srv.ServeHomeAssistant)
+	srv.mux.HandleFunc("/admin/session", srv.Admin(srv.handleAdminSession))
+	srv.mux.HandleFunc("/admin/relogin", srv.Admin(srv.handleAdminRelogin))
+	srv.mux.HandleFunc("/admin/pause", srv.Admin(srv.handleAdminPause(true)))
+	srv.mux.HandleFunc("/admin/resume", srv.Admin(srv.handleAdminPause(false)))
+	srv.mux.HandleFunc("/jobs/", srv.ServeJobs)
+	srv.mux.HandleFunc("/vehicles", srv.handleVehicles)
+	srv.mux.HandleFunc("/vehicles/", srv.handleVehicle)
+
+	return srv
+}
+
+// SetOptions replaces the server's options, such as after reloading
+// its configuration.  It is safe to call while the server is running;
+// a new UpdateInterval takes effect immediately.
+func (srv *Server) SetOptions(opts Options) {
+	if opts.UpdateTimeout == 0 {
+		opts.UpdateTimeout = time.Minute
+	}
+	if opts.CommandTimeout == 0 {
+		opts.CommandTimeout = 5 * time.Second
+	}
+	if opts.JobRetention == 0 {
+		opts.JobRetention = time.Hour
+	}
+	if opts.Units == "" {
+		opts.Units = unitsMiles
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	srv.optsMu.Lock()
+	srv.opts = opts
+	srv.optsMu.Unlock()
+
+	select {
+	case srv.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (srv *Server) options() Options {
+	srv.optsMu.Lock()
+	defer srv.optsMu.Unlock()
+
+	return srv.opts
+}
+
+// routes returns the endpoints served for each vehicle, relative to
+// the vehicle's root.
+func (srv *Server) routes() map[string]vehicleHandler {
+	return map[string]vehicleHandler{
+		"/battery":       srv.handleBattery,
+		"/range":         srv.handleRange,
+		"/climate":       srv.handleClimate,
+		"/status":        srv.handleStatus,
+		"/trips":         srv.handleTrips,
+		"/stats/monthly": srv.handleMonthlyStatistics,
+		"/charging/on":   srv.handleChargingOn,
+		"/climate/on":    srv.handleClimateOn,
+		"/climate/off":   srv.handleClimateOff,
+		"/update":        srv.handleUpdate,
+	}
+}
+
+// Add serves another vehicle through the Session, which must already
+// be connected.  Adding a vehicle that is already served replaces its
+// Session.
+func (srv *Server) Add(s *carwings.Session) {
+	srv.add(s)
+
+	select {
+	case srv.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (srv *Server) add(s *carwings.Session) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for i, v := range srv.vehicles {
+		if v.s.VIN == s.VIN {
+			srv.vehicles[i] = &vehicle{s: s}
+			return
+		}
+	}
+	srv.vehicles = append(srv.vehicles, &vehicle{s: s})
+}
+
+// Session returns the Session of the first vehicle, which is served at
+// the top level.
+func (srv *Server) Session() *carwings.Session {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.vehicles[0].s
+}
+
+// Sessions returns the Sessions of all vehicles, in the order they
+// were added.
+func (srv *Server) Sessions() []*carwings.Session {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	sessions := make([]*carwings.Session, len(srv.vehicles))
+	for i, v := range srv.vehicles {
+		sessions[i] = v.s
+	}
+	return sessions
+}
+
+func (srv *Server) lookup(vin string) *vehicle {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for _, v := range srv.vehicles {
+		if v.s.VIN == vin {
+			return v
+		}
+	}
+	return nil
+}
+
+// defaultVehicle adapts h to serve the first vehicle.
+func (srv *Server) defaultVehicle(h vehicleHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		v := srv.vehicles[0]
+		srv.mu.Unlock()
+
+		if srv.unavailable(w) {
+			return
+		}
+
+		srv.waitReady(r.Context())
+		h(v, w, r)
+	}
+}
+
+func (srv *Server) handleVehicles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		type vehicleLink struct {
+			VIN   string
+			Model carwings.Model
+			URL   string
+		}
+
+		vehicles := []vehicleLink{}
+		for _, s := range srv.Sessions() {
+			vehicles = append(vehicles, vehicleLink{
+				VIN:   s.VIN,
+				Model: s.Model(),
+				// Relative, so it works wherever the server is mounted
+				URL: "vehicles/" + url.PathEscape(s.VIN) + "/",
+			})
+		}
+
+		WriteJSON(w, r, vehicles, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// handleVehicle dispatches /vehicles/{vin}/... requests to the
+// vehicle's endpoints.
+func (srv *Server) handleVehicle(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/vehicles/")
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		// Relative, so it works wherever the server is mounted.
+		// http.Redirect would resolve it against the path after
+		// any prefix was stripped.
+		w.Header().Set("Location", rest+"/")
+		w.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+	vin, path := rest[:i], rest[i:]
+
+	v := srv.lookup(vin)
+	if v == nil {
+		http.Error(w, "unknown vehicle", http.StatusNotFound)
+		return
+	}
+
+	h, ok := srv.routes()[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if srv.unavailable(w) {
+		return
+	}
+
+	srv.waitReady(r.Context())
+	h(v, w, r)
+}
+
+// Handle registers an extra handler for the given pattern, as with
+// http.ServeMux.
+func (srv *Server) Handle(pattern string, handler http.Handler) {
+	srv.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers an extra handler function for the given
+// pattern, as with http.ServeMux.
+func (srv *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	srv.mux.HandleFunc(pattern, handler)
+}
+
+// Use wraps all of the server's handlers in middleware.  Middleware
+// registered later wraps middleware registered earlier, so it sees
+// requests first.  Use must not be called while the server is
+// serving requests.
+func (srv *Server) Use(middleware func(http.Handler) http.Handler) {
+	srv.handler = middleware(srv.handler)
+}
+
+// ServeHTTP implements http.Handler.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.handler.ServeHTTP(w, withRequestID(w, r))
+}
+
+// Run asks the vehicles for updated data every UpdateInterval until ctx
+// is canceled, and keeps the battery status they respond with for
+// requests until the next update.  While UpdateInterval is zero, no
+// updates are made.  Each vehicle is updated by its own
+// carwings.Poller, in turn with the commands sent to it.
+func (srv *Server) Run(ctx context.Context) {
+	// Changes to the options and vehicles before now, such as by
+	// New, are already in effect
+	select {
+	case <-srv.changed:
+	default:
+	}
+
+	for {
+		opts := srv.options()
+
+		srv.mu.Lock()
+		vehicles := append([]*vehicle(nil), srv.vehicles...)
+		srv.mu.Unlock()
+
+		pctx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		if opts.UpdateInterval > 0 {
+			for _, v := range vehicles {
+				p := srv.updater(v, opts)
+				v.mu.Lock()
+				v.poller = p
+				v.mu.Unlock()
+
+				wg.Add(1)
+				go func(p *carwings.Poller) {
+					defer wg.Done()
+					p.Run(pctx)
+				}(p)
+			}
+		} else {
+			// Nothing to wait for until the next update
+			srv.readiness.set()
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-srv.changed:
+			// Start over with the new options
+		}
+
+		cancel()
+		wg.Wait()
+		for _, v := range vehicles {
+			v.mu.Lock()
+			v.poller = nil
+			v.mu.Unlock()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// updater returns the Poller that updates v every UpdateInterval while
+// Run is running.
+func (srv *Server) updater(v *vehicle, opts Options) *carwings.Poller {
+	p := srv.poller(v, opts)
+	p.Interval = opts.UpdateInterval
+	p.ActiveInterval = opts.ActiveUpdateInterval
+	p.IdleInterval = opts.IdleUpdateInterval
+	p.Skip = srv.skip(v, opts)
+	p.Lock = &v.cmdMu
+	p.OnError = func(err error) {
+		if err := srv.partial(v, err); err != nil {
+			srv.breaker.trip(err)
+			v.setError(err)
+			opts.Logger.Printf("Error updating status of %s: %s", v.s.VIN, err)
+		}
+	}
+	return p
+}
+
+// skip returns the Skip hook of the Poller that updates v, which skips
+// updates during quiet hours, while paused and while the Carwings
+// service is unavailable.  The first update is skipped too if v's
+// status is more recent than UpdateInterval, such as when Run starts
+// over with new options.
+func (srv *Server) skip(v *vehicle, opts Options) func(time.Time) bool {
+	first := true
+	return func(now time.Time) bool {
+		v.mu.Lock()
+		fetched := v.fetched
+		v.mu.Unlock()
+
+		skip := true
+		switch {
+		case first && now.Sub(fetched) < opts.UpdateInterval:
+		case opts.QuietHours.Contains(now):
+			opts.Logger.Printf("Skipping update of %s during quiet hours", v.s.VIN)
+		case srv.Paused():
+			opts.Logger.Printf("Skipping update of %s while paused", v.s.VIN)
+		default:
+			if serr, _ := srv.breaker.open(); serr != nil {
+				opts.Logger.Printf("Skipping update of %s while Carwings service is unavailable", v.s.VIN)
+				break
+			}
+			skip = false
+		}
+		first = false
+
+		if skip {
+			// Nothing to wait for until the next update
+			srv.readiness.set()
+		}
+		return skip
+	}
+}
+
+// poller returns a Poller that asks v for updated data and fetches it
+// once the vehicle responds.
+func (srv *Server) poller(v *vehicle, opts Options) *carwings.Poller {
+	return &carwings.Poller{
+		Session:         v.s,
+		Update:          true,
+		UpdateTimeout:   opts.UpdateTimeout,
+		OnBatteryStatus: func(bs carwings.BatteryStatus) { srv.setBatteryStatus(v, bs) },
+		OnClimateStatus: func(cs carwings.ClimateStatus) {
+			v.mu.Lock()
+			v.climate = cs.Running
+			v.mu.Unlock()
+
+			if onClimateStatus := srv.options().OnClimateStatus; onClimateStatus != nil {
+				onClimateStatus(v.s.VIN, cs)
+			}
+		},
+	}
+}
+
+// refresh asks v for updated data and fetches it once the vehicle
+// responds, with the Poller that updates v if Run is running, so it
+// knows what the vehicle is doing.
+func (srv *Server) refresh(ctx context.Context, v *vehicle) error {
+	v.mu.Lock()
+	p := v.poller
+	v.mu.Unlock()
+
+	if p == nil {
+		p = srv.poller(v, srv.options())
+	}
+	return srv.partial(v, p.Poll(ctx))
+}
+
+// batteryStatus returns the battery status of v from the last update,
+// if it is recent enough, or otherwise retrieves it.
+func (srv *Server) batteryStatus(ctx context.Context, v *vehicle) (carwings.BatteryStatus, error) {
+	v.mu.Lock()
+	status, fetched, p := v.battery, v.fetched, v.poller
+	v.mu.Unlock()
+
+	if p != nil && time.Since(fetched) < p.NextInterval() {
+		return status, nil
+	}
+
+	return srv.fetchBatteryStatus(ctx, v)
+}
+
+// fetchBatteryStatus retrieves the battery status of v, remembers it
+// and passes it to the OnBatteryStatus hook.
+func (srv *Server) fetchBatteryStatus(ctx context.Context, v *vehicle) (carwings.BatteryStatus, error) {
+	status, err := v.s.BatteryStatusContext(ctx)
+	if err := srv.partial(v, err); err != nil {
+		return status, err
+	}
+
+	srv.setBatteryStatus(v, status)
+	return status, nil
+}
+
+// setBatteryStatus remembers status as the latest for v and passes it
+// to the OnBatteryStatus hook.
+func (srv *Server) setBatteryStatus(v *vehicle, status carwings.BatteryStatus) {
+	v.mu.Lock()
+	v.battery, v.fetched = status, time.Now()
+	v.mu.Unlock()
+
+	srv.readiness.set()
+
+	if onBatteryStatus := srv.options().OnBatteryStatus; onBatteryStatus != nil {
+		onBatteryStatus(v.s.VIN, status)
+	}
+}
+
+// partial logs the fields a lenient Session couldn't parse and
+// returns nil if err is a *carwings.PartialError, so the rest of the
+// status is still served.  Other errors are returned as is.
+func (srv *Server) partial(v *vehicle, err error) error {
+	if isPartial(err) {
+		srv.options().Logger.Printf("Partial status for %s: %s", v.s.VIN, err)
+		return nil
+	}
+	return err
+}
+
+func isPartial(err error) bool {
+	var perr *carwings.PartialError
+	return errors.As(err, &perr)
+}
+
+func (srv *Server) handleBattery(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		status, err := srv.batteryStatus(r.Context(), v)
+		if err != nil {
+			srv.error(w, err)
+			return
+		}
+
+		// The status only changes when the vehicle reports a new
+		// one, or it is retrieved again.  Without a timestamp, the
+		// ETag comes from the body.
+		var etag string
+		if !status.Timestamp.IsZero() {
+			etag = strconv.FormatInt(status.Timestamp.UnixNano(), 36) + "-" + strconv.FormatInt(status.Retrieved.UnixNano(), 36)
+		}
+		WriteJSON(w, r, status, etag)
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+func (srv *Server) handleClimate(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		status, err := v.s.ClimateControlStatusContext(r.Context())
+		if err := srv.partial(v, err); err != nil {
+			srv.error(w, err)
+			return
+		}
+
+		WriteJSON(w, r, status, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+func (srv *Server) handleStatus(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		ctx, cancel := context.WithTimeout(r.Context(), srv.options().UpdateTimeout)
+		defer cancel()
+
+		// Locating the vehicle and reading the cabin temperature
+		// are requests to the vehicle, like commands
+		v.cmdMu.Lock()
+		vs, err := v.s.VehicleStatus(ctx)
+		v.cmdMu.Unlock()
+		if err != nil && err != context.DeadlineExceeded {
+			srv.error(w, err)
+			return
+		}
+
+		// Each part is either the data or the error retrieving it,
+		// or both if only some of it could be parsed
+		var resp struct {
+			Battery      *carwings.BatteryStatus `json:",omitempty"`
+			BatteryErr   string                  `json:",omitempty"`
+			Climate      *carwings.ClimateStatus `json:",omitempty"`
+			ClimateErr   string                  `json:",omitempty"`
+			Location     *carwings.Location      `json:",omitempty"`
+			LocationErr  string                  `json:",omitempty"`
+			CabinTemp    *int                    `json:",omitempty"`
+			CabinTempErr string                  `json:",omitempty"`
+		}
+
+		if vs.BatteryErr == nil || isPartial(vs.BatteryErr) {
+			resp.Battery = &vs.Battery
+		}
+		if vs.BatteryErr != nil {
+			resp.BatteryErr = vs.BatteryErr.Error()
+		}
+		if vs.ClimateErr == nil || isPartial(vs.ClimateErr) {
+			resp.Climate = &vs.Climate
+		}
+		if vs.ClimateErr != nil {
+			resp.ClimateErr = vs.ClimateErr.Error()
+		}
+		if vs.LocationErr == nil {
+			resp.Location = &vs.Location
+		} else {
+			resp.LocationErr = vs.LocationErr.Error()
+		}
+		if vs.CabinTempErr == nil {
+			resp.CabinTemp = &vs.CabinTemp
+		} else {
+			resp.CabinTempErr = vs.CabinTempErr.Error()
+		}
+
+		WriteJSON(w, r, resp, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// poll checks whether the request with the given result key has
+// finished until it has, or until the update timeout.
+func (srv *Server) poll(ctx context.Context, key string, check func(context.Context, string) (bool, error)) error {
+	timeout := srv.options().UpdateTimeout
+	start := time.Now()
+	for {
+		// Requests take at least a few seconds, so wait before
+		// checking each time
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(3 * time.Second):
+		}
+
+		done, err := check(ctx, key)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Since(start) > timeout {
+			return fmt.Errorf("timed out after %v waiting for the vehicle", timeout)
+		}
+	}
+}
+
+// command runs fn in the background as a job with startJob.  If it
+// completes within the command timeout the client gets its result,
+// otherwise 202 Accepted.  Either way, the Location header and body
+// have the job, to follow it with GET /jobs/{id}.
+func (srv *Server) command(w http.ResponseWriter, r *http.Request, v *vehicle, name string, fn func(context.Context) error) {
+	j, err := srv.startJob(detach(r), v, name, srv.options().CommandTimeout, fn)
+	w.Header().Set("Location", requestBase(r, r.URL.Path)+"/jobs/"+j.ID)
+
+	switch {
+	case j.State == JobPending:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(j)
+	case err != nil:
+		srv.error(w, err)
+	default:
+		WriteJSON(w, r, j, "")
+	}
+}
+
+// queueCommand runs fn in the background, after any other commands
+// for v have finished, so the vehicle never has more than one request
+// in flight.  fn is called with ctx, which shouldn't be canceled when
+// the request that queued the command is.  The returned channel
+// receives fn's result.
+func (srv *Server) queueCommand(ctx context.Context, v *vehicle, name string, fn func(context.Context) error) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		v.cmdMu.Lock()
+		defer v.cmdMu.Unlock()
+
+		err := fn(ctx)
+		srv.breaker.trip(err)
+		if err != nil {
+			srv.options().Logger.Printf("%s%s for %s failed: %s", requestPrefix(ctx), name, v.s.VIN, err)
+		}
+		ch <- err
+	}()
+
+	return ch
+}
+
+func (srv *Server) handleChargingOn(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		srv.options().Logger.Printf("%sCharging request", requestPrefix(r.Context()))
+
+		srv.command(w, r, v, "Charging request", v.s.ChargingRequestContext)
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+func (srv *Server) handleClimateOn(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		srv.options().Logger.Printf("%sClimate control on request", requestPrefix(r.Context()))
+
+		// force=1 turns it on even below the vehicle's MinClimateSOC
+		force := r.FormValue("force") == "1"
+		srv.command(w, r, v, "Climate control on request", func(ctx context.Context) error {
+			if force {
+				ctx = carwings.ContextWithForce(ctx)
+			}
+			return srv.climateOn(ctx, v)
+		})
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+func (srv *Server) handleClimateOff(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		srv.options().Logger.Printf("%sClimate control off request", requestPrefix(r.Context()))
+
+		srv.command(w, r, v, "Climate control off request", func(ctx context.Context) error {
+			return srv.climateOff(ctx, v)
+		})
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// Command runs fn with the Session for the vehicle with the given
+// VIN, or the default vehicle if vin is empty, in turn with the
+// server's own commands for it, and returns fn's error.  Like them,
+// it is a job, reported on by GET /jobs/{id}.
+func (srv *Server) Command(ctx context.Context, vin string, name string, fn func(context.Context, *carwings.Session) error) error {
+	var v *vehicle
+	if vin == "" {
+		srv.mu.Lock()
+		v = srv.vehicles[0]
+		srv.mu.Unlock()
+	} else if v = srv.lookup(vin); v == nil {
+		return fmt.Errorf("no vehicle %s", vin)
+	}
+
+	j, _ := srv.startJob(context.Background(), v, name, 0, func(context.Context) error {
+		return fn(ctx, v.s)
+	})
+
+	select {
+	case <-j.done:
+		j, _ = srv.jobs.get(j.ID)
+		return j.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// climateOn turns on v's climate control and waits for the vehicle
+// to respond.
+func (srv *Server) climateOn(ctx context.Context, v *vehicle) error {
+	key, err := v.s.ClimateOnRequestContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := srv.poll(ctx, key, v.s.CheckClimateOnRequestContext); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.climate = true
+	v.mu.Unlock()
+
+	return nil
+}
+
+// climateOff turns off v's climate control and waits for the vehicle
+// to respond.
+func (srv *Server) climateOff(ctx context.Context, v *vehicle) error {
+	key, err := v.s.ClimateOffRequestContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := srv.poll(ctx, key, v.s.CheckClimateOffRequestContext); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.climate = false
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (srv *Server) handleUpdate(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		if srv.options().QuietHours.Contains(time.Now()) && r.FormValue("override") != "1" {
+			http.Error(w, "not updating during quiet hours; pass override=1 to update anyway", http.StatusConflict)
+			return
+		}
+
+		srv.options().Logger.Printf("%sUpdate request", requestPrefix(r.Context()))
+
+		srv.command(w, r, v, "Update request", func(ctx context.Context) error {
+			return srv.refresh(ctx, v)
+		})
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
@@ -0,0 +1,191 @@
+package httpd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// Responses to logging in, for a USA account with two vehicles
+const (
+	testInitialApp = `{"status":200,"baseprm":"uyI5Dj9g8VCOFDnBRUbr3g"}`
+	testLogin      = `{"status":200,"VehicleInfoList":{"vehicleInfo":[{"vin":"VIN123","custom_sessionid":"sess"},{"vin":"VIN456","custom_sessionid":"sess2"}]},"CustomerInfo":{"Timezone":"America/New_York"}}`
+)
+
+// testNow is the time of the test Sessions' clock.
+var testNow = time.Date(2018, 8, 5, 12, 0, 0, 0, time.UTC)
+
+type testHandler func(params url.Values) string
+
+// respond returns a testHandler that always responds with body.
+func respond(body string) testHandler {
+	return func(url.Values) string { return body }
+}
+
+// batteryResponse returns a battery status response with the given
+// state of charge.
+func batteryResponse(soc int) string {
+	return fmt.Sprintf(`{"status":200,"BatteryStatusRecords":{"BatteryStatus":{"BatteryChargingStatus":"NOT_CHARGING","BatteryCapacity":"240","BatteryRemainingAmount":"220","SOC":{"Value":"%d"}},"PluginState":"CONNECTED","CruisingRangeAcOn":"115328.0","CruisingRangeAcOff":"117224.0","NotificationDateAndTime":"2018\/08\/05 10:18"}}`, soc)
+}
+
+// testService is a fake Carwings service.  It answers logging in and
+// the endpoints in handlers, by name like "BatteryStatusRecordsRequest",
+// and responds 404 Not Found to the rest.
+type testService struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int // by endpoint
+}
+
+func newTestService(t *testing.T, handlers map[string]testHandler) *testService {
+	t.Helper()
+
+	all := map[string]testHandler{
+		"InitialApp_v2":    respond(testInitialApp),
+		"UserLoginRequest": respond(testLogin),
+	}
+	for name, h := range handlers {
+		all[name] = h
+	}
+
+	ts := &testService{requests: map[string]int{}}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := path.Base(r.URL.Path)
+		endpoint = endpoint[:len(endpoint)-len(path.Ext(endpoint))]
+
+		ts.mu.Lock()
+		ts.requests[endpoint]++
+		ts.mu.Unlock()
+
+		h, ok := all[endpoint]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(h(r.PostForm)))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// count returns the number of requests made to endpoint.
+func (ts *testService) count(endpoint string) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.requests[endpoint]
+}
+
+// fixedClock is a Clock that's always at the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// newTestServer returns a Server for both vehicles of the account at
+// ts, with VIN123 at the top level.
+func newTestServer(t *testing.T, ts *testService, opts Options) *Server {
+	t.Helper()
+
+	var srv *Server
+	for _, vin := range []string{"VIN123", "VIN456"} {
+		s, err := carwings.NewSession("user", "password",
+			carwings.WithBaseURL(ts.URL+"/"), carwings.WithVIN(vin), carwings.WithClock(fixedClock(testNow)))
+		if err != nil {
+			t.Fatalf("NewSession: %v", err)
+		}
+		if srv == nil {
+			srv = New(s, opts)
+		} else {
+			srv.Add(s)
+		}
+	}
+	return srv
+}
+
+// serve makes a request to srv and returns the response.
+func serve(srv http.Handler, method, target string, header http.Header) *http.Response {
+	r := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	return w.Result()
+}
+
+// decode decodes the JSON body of resp into v.
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+}
+
+func TestVehicleRouting(t *testing.T) {
+	// Each vehicle has its own state of charge
+	ts := newTestService(t, map[string]testHandler{
+		"BatteryStatusRecordsRequest": func(params url.Values) string {
+			if params.Get("VIN") == "VIN456" {
+				return batteryResponse(45)
+			}
+			return batteryResponse(91)
+		},
+	})
+	srv := newTestServer(t, ts, Options{})
+
+	tests := []struct {
+		target   string
+		status   int
+		soc      int    // of the battery status served, if any
+		location string // of a redirect
+	}{
+		{target: "/battery", status: http.StatusOK, soc: 91},
+		{target: "/vehicles/VIN123/battery", status: http.StatusOK, soc: 91},
+		{target: "/vehicles/VIN456/battery", status: http.StatusOK, soc: 45},
+		{target: "/vehicles/VIN456", status: http.StatusMovedPermanently, location: "VIN456/"},
+		{target: "/vehicles/VIN789/battery", status: http.StatusNotFound},
+		{target: "/vehicles/VIN456/nonexistent", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := serve(srv, "GET", tt.target, nil)
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, resp.StatusCode, tt.status)
+			continue
+		}
+		if loc := resp.Header.Get("Location"); loc != tt.location {
+			t.Errorf("GET %s: Location %q, want %q", tt.target, loc, tt.location)
+		}
+		if tt.soc != 0 {
+			var bs carwings.BatteryStatus
+			decode(t, resp, &bs)
+			if bs.StateOfCharge != tt.soc {
+				t.Errorf("GET %s: state of charge %d, want %d", tt.target, bs.StateOfCharge, tt.soc)
+			}
+		}
+	}
+
+	var vehicles []struct{ VIN, URL string }
+	decode(t, serve(srv, "GET", "/vehicles", nil), &vehicles)
+	want := []struct{ VIN, URL string }{
+		{"VIN123", "vehicles/VIN123/"},
+		{"VIN456", "vehicles/VIN456/"},
+	}
+	if fmt.Sprint(vehicles) != fmt.Sprint(want) {
+		t.Errorf("GET /vehicles: %v, want %v", vehicles, want)
+	}
+}
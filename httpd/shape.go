@@ -0,0 +1,178 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Key naming styles for the naming query parameter
+const (
+	namingDefault = ""
+	namingSnake   = "snake"
+	namingCamel   = "camel"
+)
+
+// shape applies the fields and naming query parameters to body, a
+// JSON response.  fields is a comma-separated list of the fields to
+// keep, with dots separating nested ones (TimeToFull.Level2); names
+// are matched ignoring case and underscores, so snake_case works too.
+// Fields that don't exist are left out.  naming renames every key to
+// snake_case or camelCase.
+func shape(body []byte, fields, naming string) ([]byte, error) {
+	switch naming {
+	case namingDefault, namingSnake, namingCamel:
+	default:
+		return nil, fmt.Errorf("unsupported naming %q: must be snake or camel", naming)
+	}
+	if fields == "" && naming == namingDefault {
+		return body, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if fields != "" {
+		var paths [][]string
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				paths = append(paths, strings.Split(f, "."))
+			}
+		}
+		v = filterFields(v, paths)
+	}
+	if naming != namingDefault {
+		v = renameKeys(v, naming)
+	}
+
+	body, err := json.Marshal(v)
+	return append(body, '\n'), err
+}
+
+// fieldKey normalizes a field name for matching.
+func fieldKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// filterFields keeps only the fields of v on paths.  The fields of
+// each element of an array are filtered.
+func filterFields(v interface{}, paths [][]string) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = filterFields(v[i], paths)
+		}
+		return v
+
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, fv := range v {
+			var rest [][]string
+			whole := false
+			for _, p := range paths {
+				if fieldKey(p[0]) != fieldKey(k) {
+					continue
+				}
+				if len(p) == 1 {
+					whole = true
+				} else {
+					rest = append(rest, p[1:])
+				}
+			}
+
+			switch {
+			case whole:
+				out[k] = fv
+			case rest != nil:
+				out[k] = filterFields(fv, rest)
+			}
+		}
+		return out
+
+	default:
+		return v
+	}
+}
+
+// renameKeys renames the keys of every object in v to the naming
+// style.
+func renameKeys(v interface{}, naming string) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = renameKeys(v[i], naming)
+		}
+		return v
+
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, fv := range v {
+			words := splitWords(k)
+			for i, w := range words {
+				w = strings.ToLower(w)
+				if naming == namingCamel && i > 0 {
+					w = strings.ToUpper(w[:1]) + w[1:]
+				}
+				words[i] = w
+			}
+
+			sep := ""
+			if naming == namingSnake {
+				sep = "_"
+			}
+			out[strings.Join(words, sep)] = renameKeys(fv, naming)
+		}
+		return out
+
+	default:
+		return v
+	}
+}
+
+// splitWords splits a Go field name into words, keeping acronyms
+// together: CruisingRangeACOn is Cruising, Range, AC and On.
+// Numbers stay with the word before them, and units with their
+// numbers, so Level2At6kW is Level2, At6kW.
+func splitWords(name string) []string {
+	runes := []rune(name)
+
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case cur == '_':
+			words = append(words, string(runes[start:i]))
+			start = i + 1
+			continue
+		case !unicode.IsUpper(cur):
+			continue
+		case i >= 2 && unicode.IsLower(prev) && unicode.IsDigit(runes[i-2]):
+			// A unit after a number, like the kW in 6kW
+			continue
+		case unicode.IsLower(prev), unicode.IsDigit(prev):
+		case unicode.IsUpper(prev) && unicode.IsLower(next):
+		default:
+			continue
+		}
+		if i > start {
+			words = append(words, string(runes[start:i]))
+		}
+		start = i
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
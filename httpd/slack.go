@@ -0,0 +1,217 @@
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slack rejects responses to slash commands that take longer than 3
+// seconds, so commands for the vehicle report back via the request's
+// response_url instead.
+
+// slackMaxAge is how old a signed request may be, to limit replays.
+const slackMaxAge = 5 * time.Minute
+
+type slackMessage struct {
+	ResponseType string       `json:"response_type"`
+	Text         string       `json:"text"`
+	Blocks       []slackBlock `json:"blocks,omitempty"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackReply is a message visible to the whole channel, as a single
+// section of Markdown.
+func slackReply(text string) slackMessage {
+	return slackMessage{
+		ResponseType: "in_channel",
+		Text:         text,
+		Blocks: []slackBlock{{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: text},
+		}},
+	}
+}
+
+const slackHelp = "Usage: `battery`, `climate`, `climate on` or `climate off`"
+
+// verifySlack checks the request's signature against the signing
+// secret, and returns its body.
+func verifySlack(r *http.Request, secret string) ([]byte, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	ts := r.Header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp")
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > slackMaxAge || age < -slackMaxAge {
+		return nil, fmt.Errorf("stale request")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(r.Header.Get("X-Slack-Signature")), []byte(want)) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	return body, nil
+}
+
+// ServeSlack answers Slack slash commands for the default vehicle,
+// like "/leaf battery" or "/leaf climate on".  Requests must be signed
+// with the app's signing secret.
+func (srv *Server) ServeSlack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	secret := srv.options().SlackSigningSecret
+	if secret == "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	body, err := verifySlack(r, secret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	srv.mu.Lock()
+	v := srv.vehicles[0]
+	srv.mu.Unlock()
+
+	text := strings.ToLower(strings.TrimSpace(form.Get("text")))
+	srv.options().Logger.Printf("Slack command %q from %s", text, form.Get("user_name"))
+
+	var reply slackMessage
+	switch text {
+	case "", "battery", "status":
+		reply = srv.slackBattery(r.Context(), v)
+
+	case "climate":
+		v.mu.Lock()
+		running := v.climate
+		v.mu.Unlock()
+
+		if running {
+			reply = slackReply("Climate control is *on*.")
+		} else {
+			reply = slackReply("Climate control is *off*.")
+		}
+
+	case "climate on", "climate off":
+		reply = srv.slackClimate(detach(r), v, text == "climate on", form.Get("response_url"))
+
+	default:
+		reply = slackMessage{ResponseType: "ephemeral", Text: slackHelp}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+func (srv *Server) slackBattery(ctx context.Context, v *vehicle) slackMessage {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	bs, err := srv.batteryStatus(ctx, v)
+	if err != nil {
+		return slackReply(fmt.Sprintf("Couldn't get the battery status: %s", err))
+	}
+
+	units := srv.options().Units
+
+	text := fmt.Sprintf("*%d%%* charged, %.0f %s of range (as of %s)\n%s, %s.",
+		bs.StateOfCharge,
+		metersToUnits(units, bs.CruisingRangeACOff), units,
+		bs.Timestamp.Local().Format("Jan 2 15:04"),
+		capitalize(bs.PluginState.String()),
+		bs.ChargingStatus)
+
+	return slackReply(text)
+}
+
+// slackClimate queues a climate control command and replies right
+// away.  The outcome is posted to responseURL when the vehicle
+// responds.
+func (srv *Server) slackClimate(ctx context.Context, v *vehicle, on bool, responseURL string) slackMessage {
+	if serr, _ := srv.breaker.open(); serr != nil {
+		return slackReply(fmt.Sprintf("The Carwings service is unavailable: %s", serr))
+	}
+
+	name, fn, done := "Climate control on request", srv.climateOn, "Climate control is *on*."
+	if !on {
+		name, fn, done = "Climate control off request", srv.climateOff, "Climate control is *off*."
+	}
+
+	j, _ := srv.startJob(ctx, v, name, 0, func(ctx context.Context) error {
+		return fn(ctx, v)
+	})
+
+	go func() {
+		<-j.done
+		reply := slackReply(done)
+		if j, _ := srv.jobs.get(j.ID); j.err != nil {
+			reply = slackReply(fmt.Sprintf("%s failed: %s", name, j.err))
+		}
+		if responseURL == "" {
+			return
+		}
+
+		b, err := json.Marshal(reply)
+		if err != nil {
+			return
+		}
+		resp, err := http.Post(responseURL, "application/json", bytes.NewReader(b))
+		if err != nil {
+			srv.options().Logger.Printf("Error responding to Slack: %s", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+
+	if on {
+		return slackReply("Turning on climate control...")
+	}
+	return slackReply("Turning off climate control...")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
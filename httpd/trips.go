@@ -0,0 +1,182 @@
+package httpd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+const (
+	defaultTripsPerPage = 50
+	maxTripsPerPage     = 500
+)
+
+// monthlyStatistics retrieves the statistics for month through the
+// MonthlyStatistics option, if set.
+func (srv *Server) monthlyStatistics(ctx context.Context, s *carwings.Session, month time.Time) (carwings.MonthlyStatistics, error) {
+	if get := srv.options().MonthlyStatistics; get != nil {
+		return get(ctx, s, month)
+	}
+	return s.GetMonthlyStatisticsContext(ctx, month)
+}
+
+// tripsPage is a page of the trips between two dates.
+type tripsPage struct {
+	Trips   []carwings.TripDetail
+	Page    int
+	PerPage int
+	Pages   int
+	Total   int
+}
+
+// handleTrips serves the trips started between the from and to dates
+// (YYYY-MM-DD, inclusive), oldest first, a page at a time.  Both
+// dates default to today.
+func (srv *Server) handleTrips(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		today := time.Now().Format("2006-01-02")
+		from, err := parseDateParam(r, "from", today)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseDateParam(r, "to", today)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.Before(from) {
+			http.Error(w, "to is before from", http.StatusBadRequest)
+			return
+		}
+		end := to.AddDate(0, 0, 1)
+
+		page, err := parseIntParam(r, "page", 1)
+		if err != nil || page < 1 {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+		perPage, err := parseIntParam(r, "per_page", defaultTripsPerPage)
+		if err != nil || perPage < 1 || perPage > maxTripsPerPage {
+			http.Error(w, fmt.Sprintf("invalid per_page: must be from 1 to %d", maxTripsPerPage), http.StatusBadRequest)
+			return
+		}
+
+		// Months are requested by a day in the middle, so the
+		// account's time zone doesn't move them into the one
+		// before.
+		var trips []carwings.TripDetail
+		last := time.Date(to.Year(), to.Month(), 15, 12, 0, 0, 0, time.Local)
+		for month := time.Date(from.Year(), from.Month(), 15, 12, 0, 0, 0, time.Local); !month.After(last); month = month.AddDate(0, 1, 0) {
+			ms, err := srv.monthlyStatistics(r.Context(), v.s, month)
+			if err != nil {
+				srv.error(w, err)
+				return
+			}
+			for _, d := range ms.Dates {
+				for _, t := range d.Trips {
+					if !t.Started.Before(from) && t.Started.Before(end) {
+						trips = append(trips, t)
+					}
+				}
+			}
+		}
+
+		resp := tripsPage{
+			Trips:   []carwings.TripDetail{},
+			Page:    page,
+			PerPage: perPage,
+			Pages:   (len(trips) + perPage - 1) / perPage,
+			Total:   len(trips),
+		}
+		if start := (page - 1) * perPage; start < len(trips) {
+			stop := start + perPage
+			if stop > len(trips) {
+				stop = len(trips)
+			}
+			resp.Trips = trips[start:stop]
+		}
+
+		// Links to the neighbouring pages, for clients that follow them
+		if page > 1 {
+			w.Header().Add("Link", `<`+pageURL(r, page-1)+`>; rel="prev"`)
+		}
+		if page < resp.Pages {
+			w.Header().Add("Link", `<`+pageURL(r, page+1)+`>; rel="next"`)
+		}
+
+		WriteJSON(w, r, resp, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+// handleMonthlyStatistics serves the statistics for the month given
+// as YYYY-MM, by default the current one.  Statistics for months that
+// are over are cached by the Session if it has a statistics cache.
+func (srv *Server) handleMonthlyStatistics(v *vehicle, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s := r.FormValue("month")
+		if s == "" {
+			s = time.Now().Format("2006-01")
+		}
+		month, err := time.ParseInLocation("2006-01", s, time.Local)
+		if err != nil {
+			http.Error(w, "invalid month: must be YYYY-MM", http.StatusBadRequest)
+			return
+		}
+
+		// Ask by a day in the middle of the month, as for trips
+		ms, err := srv.monthlyStatistics(r.Context(), v.s, month.AddDate(0, 0, 14).Add(12*time.Hour))
+		if err != nil {
+			srv.error(w, err)
+			return
+		}
+
+		WriteJSON(w, r, ms, "")
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+}
+
+func parseDateParam(r *http.Request, name, def string) (time.Time, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		s = def
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s: must be YYYY-MM-DD", name)
+	}
+	return t, nil
+}
+
+func parseIntParam(r *http.Request, name string, def int) (int, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// pageURL returns the URL of the request with its page parameter
+// replaced.  It is relative, so it works wherever the server is
+// mounted.
+func pageURL(r *http.Request, page int) string {
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	u := url.URL{Path: path.Base(r.URL.Path), RawQuery: q.Encode()}
+	return u.String()
+}
@@ -0,0 +1,127 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+// breaker stops requests to the Carwings service for a while after it
+// reports that it is unavailable, so clients aren't left waiting on
+// requests that will fail anyway.
+type breaker struct {
+	mu    sync.Mutex
+	until time.Time
+	err   *carwings.ServiceError
+}
+
+// trip opens the breaker if err is a carwings.ServiceError, and reports
+// whether it did.
+func (b *breaker) trip(err error) bool {
+	var serr *carwings.ServiceError
+	if !errors.As(err, &serr) {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.until = time.Now().Add(serr.RetryAfter)
+	b.err = serr
+	return true
+}
+
+// open returns the error that opened the breaker and how long until it
+// closes, or nil if it is closed.
+func (b *breaker) open() (*carwings.ServiceError, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err == nil || !time.Now().Before(b.until) {
+		return nil, 0
+	}
+	return b.err, time.Until(b.until)
+}
+
+// writeUnavailable responds with 503 Service Unavailable, describing
+// the state of the Carwings service.
+func writeUnavailable(w http.ResponseWriter, serr *carwings.ServiceError, retry time.Duration) {
+	secs := int((retry + time.Second - 1) / time.Second)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	json.NewEncoder(w).Encode(struct {
+		Upstream   string
+		StatusCode int
+		Message    string `json:",omitempty"`
+		RetryAfter int
+		RequestID  string `json:",omitempty"`
+	}{"unavailable", serr.StatusCode, serr.Message, secs, w.Header().Get(requestIDHeader)})
+}
+
+// requestError returns the message of an error response, including
+// the request ID so it can be found in the logs.
+func requestError(err error, id string) string {
+	if id == "" {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s (request ID %s)", err, id)
+}
+
+// unavailable responds with 503 Service Unavailable and returns true
+// if the breaker is open.
+func (srv *Server) unavailable(w http.ResponseWriter) bool {
+	serr, retry := srv.breaker.open()
+	if serr == nil {
+		return false
+	}
+
+	writeUnavailable(w, serr, retry)
+	return true
+}
+
+// Error responds to err from a request to the Carwings service made
+// by a handler registered on the server, the same way as the server's
+// own endpoints do, such as with 503 Service Unavailable and a
+// Retry-After header while the service is down.
+func (srv *Server) Error(w http.ResponseWriter, err error) {
+	srv.error(w, err)
+}
+
+// error responds to a failed request to the Carwings service, with 503
+// Service Unavailable if the service is down and 500 Internal Server
+// Error otherwise.
+func (srv *Server) error(w http.ResponseWriter, err error) {
+	id := w.Header().Get(requestIDHeader)
+	if srv.breaker.trip(err) {
+		srv.options().Logger.Printf("%sRequest failed: %s", logPrefix(id), err)
+		srv.unavailable(w)
+		return
+	}
+
+	// The vehicle itself failed the command
+	var oerr *carwings.OperationError
+	if errors.As(err, &oerr) {
+		status := http.StatusBadGateway
+		if errors.Is(err, carwings.ErrVehicleUnreachable) {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, requestError(err, id), status)
+		return
+	}
+
+	if errors.Is(err, carwings.ErrNotPluggedIn) || errors.Is(err, carwings.ErrBatteryTooLow) {
+		http.Error(w, requestError(err, id), http.StatusConflict)
+		return
+	}
+
+	http.Error(w, requestError(err, id), http.StatusInternalServerError)
+}
@@ -0,0 +1,44 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/joeshaw/carwings"
+)
+
+func TestServiceUnavailable(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"BatteryStatusRecordsRequest": respond(`{"status":503,"message":"under maintenance"}`),
+	})
+	srv := newTestServer(t, ts, Options{})
+
+	// The first request finds the service down, and the rest are
+	// refused without trying it again until it's due back
+	retry := strconv.Itoa(int(carwings.DefaultRetryAfter / time.Second))
+	for i, target := range []string{"/battery", "/battery", "/vehicles/VIN456/battery"} {
+		resp := serve(srv, "GET", target, nil)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("GET %s: status %d, want %d", target, resp.StatusCode, http.StatusServiceUnavailable)
+		}
+		if got := resp.Header.Get("Retry-After"); got != retry {
+			t.Errorf("GET %s: Retry-After %q, want %q", target, got, retry)
+		}
+
+		var body struct {
+			Upstream   string
+			StatusCode int
+			Message    string
+		}
+		decode(t, resp, &body)
+		if body.Upstream != "unavailable" || body.StatusCode != 503 || body.Message != "under maintenance" {
+			t.Errorf("GET %s: body %+v, want the service's error", target, body)
+		}
+
+		if n := ts.count("BatteryStatusRecordsRequest"); n != 1 {
+			t.Errorf("after %d requests, %d to the service, want 1", i+1, n)
+		}
+	}
+}
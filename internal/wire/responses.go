@@ -0,0 +1,299 @@
+package wire
+
+import "encoding/json"
+
+// InitialApp is the response to InitialApp_v2.php.
+type InitialApp struct {
+	Base
+	Baseprm string `json:"baseprm"`
+}
+
+// VehicleInfo identifies a vehicle in a Login response.  Not a
+// comprehensive representation, just what we need.
+type VehicleInfo struct {
+	VIN             string `json:"vin"`
+	CustomSessionID string `json:"custom_sessionid"`
+}
+
+// Login is the response to UserLoginRequest.php.
+type Login struct {
+	Base
+
+	// OMG this API... one of these three will be populated.
+	VehicleInfos    []VehicleInfo `json:"vehicleInfo"`
+	VehicleInfoList struct {
+		VehicleInfos []VehicleInfo `json:"vehicleInfo"`
+	} `json:"vehicleInfoList"`
+	VehicleInfo VehicleInfo `json:"VehicleInfo"`
+
+	CustomerInfo struct {
+		Timezone    string
+		VehicleInfo VehicleInfo `json:"VehicleInfo"`
+	}
+}
+
+// ResultKey is the response to asynchronous requests.
+type ResultKey struct {
+	Base
+	ResultKey string `json:"resultKey"`
+}
+
+// Result is the response to polling for the result of an asynchronous
+// request.
+type Result struct {
+	Base
+	ResponseFlag    Number `json:"responseFlag"` // 0 or 1
+	OperationResult string `json:"operationResult"`
+}
+
+// ClimateResult is the response to polling for the result of a
+// climate control request.
+type ClimateResult struct {
+	Result
+	ACContinueTime string `json:"acContinueTime"`
+	TimeStamp      Time   `json:"timeStamp"`
+	HVACStatus     string `json:"hvacStatus"`
+}
+
+// CabinTempResult is the response to polling for the result of a
+// cabin temperature request.
+type CabinTempResult struct {
+	Base
+	ResponseFlag Number `json:"responseFlag"` // 0 or 1
+	Temperature  Number `json:"Inc_temp"`
+}
+
+// LocateResult is the response to polling for the result of a locate
+// request.
+type LocateResult struct {
+	Base
+	ResponseFlag Number `json:"responseFlag"` // 0 or 1
+	Latitude     string `json:"lat"`
+	Longitude    string `json:"lng"`
+	ReceivedDate Time   `json:"receivedDate"`
+}
+
+// BatteryStatus is the response to BatteryStatusRecordsRequest.php.
+// The records are an empty array when no data is available, so they
+// are decoded separately into a BatteryStatusRecord.
+type BatteryStatus struct {
+	Base
+	BatteryStatusRecords json.RawMessage
+}
+
+// BatteryStatusRecord is the battery status in a BatteryStatus
+// response.
+type BatteryStatusRecord struct {
+	BatteryStatus struct {
+		BatteryChargingStatus     string
+		BatteryCapacity           Number
+		BatteryRemainingAmount    Number
+		BatteryRemainingAmountWH  Number
+		BatteryRemainingAmountKWH Number
+		SOC                       struct {
+			Value Number
+		}
+	}
+	PluginState        string
+	CruisingRangeAcOn  Number
+	CruisingRangeAcOff Number
+	TimeRequiredToFull struct {
+		HourRequiredToFull    Number
+		MinutesRequiredToFull Number
+	}
+	TimeRequiredToFull200 struct {
+		HourRequiredToFull    Number
+		MinutesRequiredToFull Number
+	}
+	TimeRequiredToFull200_6kW struct {
+		HourRequiredToFull    Number
+		MinutesRequiredToFull Number
+	}
+	NotificationDateAndTime Time
+
+	// Some vehicles hint at the charger they're plugged into with
+	// its supply voltage, like "220V", or NOT_CHARGING.
+	ChargeMode string
+
+	// Lifetime quick and normal (Level 1 and 2) charge counts.  Only
+	// some vehicles report them, so they are nil when left out.
+	QuickChargeCount  *Number
+	NormalChargeCount *Number
+}
+
+// RemoteAC is the response to RemoteACRecordsRequest.php.  Sometimes
+// the records are an empty array instead of a struct value, so they
+// are decoded separately into a RemoteACRecord.
+type RemoteAC struct {
+	Base
+	RemoteACRecords json.RawMessage
+}
+
+// RemoteACRecord is the climate control status in a RemoteAC response.
+type RemoteACRecord struct {
+	OperationResult        string
+	OperationDateAndTime   Time
+	RemoteACOperation      string
+	ACStartStopDateAndTime Time
+	ACStartStopURL         string
+	CruisingRangeAcOn      Number
+	CruisingRangeAcOff     Number
+	PluginState            string
+	ACDurationBatterySec   Number
+	ACDurationPluggedSec   Number
+	PreAC_unit             string
+	PreAC_temp             Number
+}
+
+// Monthly is the response to PriceSimulatorDetailInfoRequest.php:
+//
+//	{
+//	  "status": 200,
+//	  "PriceSimulatorDetailInfoResponsePersonalData": {
+//	    "TargetMonth": "201808",
+//	    "TotalPowerConsumptTotal": "55.88882",
+//	    "TotalPowerConsumptMoter": "71.44184",
+//	    "TotalPowerConsumptMinus": "15.55302",
+//	    "ElectricPrice": "0.15",
+//	    "ElectricBill": "8.3833230",
+//	    "ElectricCostScale": "kWh/100km",
+//	    "MainRateFlg": "COUNTRY",
+//	    "ExistFlg": "EXIST",
+//	    "PriceSimulatorDetailInfoDateList": {
+//	      "PriceSimulatorDetailInfoDate": [
+//	        {
+//	          "TargetDate": "2018-08-05",
+//	          "PriceSimulatorDetailInfoTripList": {
+//	            "PriceSimulatorDetailInfoTrip": [
+//	              {
+//	                "TripId": "1",
+//	                "PowerConsumptTotal": "2461.12",
+//	                "PowerConsumptMoter": "3812.22",
+//	                "PowerConsumptMinus": "1351.1",
+//	                "TravelDistance": "17841",
+//	                "ElectricMileage": "13.8",
+//	                "CO2Reduction": "3",
+//	                "MapDisplayFlg": "NONACTIVE",
+//	                "GpsDatetime": "2018-08-05T10:18:47"
+//	              },
+//	              { ... repeats for each trip ... }
+//	            ]
+//	          },
+//	          "DisplayDate": "Aug 05"
+//	        },
+//	        { ... repeats for each day ... }
+//	      ]
+//	    },
+//	    "PriceSimulatorTotalInfo": {
+//	      "TotalNumberOfTrips": "23",
+//	      "TotalPowerConsumptTotal": "55.88882",
+//	      "TotalPowerConsumptMoter": "71.44184",
+//	      "TotalPowerConsumptMinus": "15.55302",
+//	      "TotalTravelDistance": "416252",
+//	      "TotalElectricMileage": "0.0134",
+//	      "TotalCO2Reductiont": "72"
+//	    },
+//	    "DisplayMonth": "Aug/2018"
+//	  }
+//	}
+type Monthly struct {
+	Base
+	Data struct {
+		TargetMonth string
+		// The following three fields are ignored because they also appear in the totals
+		// - TotalPowerConsumptTotal
+		// - TotalPowerConsumptMoter
+		// - TotalPowerConsumptMinus
+		ElectricPrice     Number
+		ElectricBill      Number
+		ElectricCostScale string
+		// The following two fields are ignored because their meaning is unclear
+		// - MainRateFlg
+		// - ExistFlg
+		Detail struct {
+			// This is an empty string instead of an array
+			// if there's no data, so it's decoded
+			// separately into []DetailInfoDate.
+			RawList json.RawMessage `json:"PriceSimulatorDetailInfoDate"`
+		} `json:"PriceSimulatorDetailInfoDateList"`
+		Total MonthlyTotals `json:"PriceSimulatorTotalInfo"`
+	} `json:"PriceSimulatorDetailInfoResponsePersonalData"`
+	// DisplayMonth string  // ignored
+}
+
+// DetailInfoDate is the trips on one day in a Monthly response.
+type DetailInfoDate struct {
+	TargetDate string
+	// DisplayDate string  // ignored
+	Trips struct {
+		List []Trip `json:"PriceSimulatorDetailInfoTrip"`
+	} `json:"PriceSimulatorDetailInfoTripList"`
+}
+
+// Trip is a trip in a Monthly response.
+type Trip struct {
+	TripId             Number
+	PowerConsumedTotal Number `json:"PowerConsumptTotal"`
+	PowerConsumedMotor Number `json:"PowerConsumptMoter"`
+	PowerRegenerated   Number `json:"PowerConsumptMinus"`
+	Meters             Number `json:"TravelDistance"`
+	Efficiency         Number `json:"ElectricMileage"`
+	CO2Reduction       Number
+	MapDisplayFlag     string `json:"MapDisplayFlg"`
+	GPSDateTime        Time   `json:"GpsDatetime"`
+}
+
+// MonthlyTotals is the totals for the month in a Monthly response.
+type MonthlyTotals struct {
+	Trips              Number `json:"TotalNumberOfTrips"`
+	PowerConsumed      Number `json:"TotalPowerConsumptTotal"`
+	PowerConsumedMotor Number `json:"TotalPowerConsumptMoter"`
+	PowerRegenerated   Number `json:"TotalPowerConsumptMinus"`
+	MetersTravelled    Number `json:"TotalTravelDistance"`
+	Efficiency         Number `json:"TotalElectricMileage"`
+	CO2Reduction       Number `json:"TotalCO2Reductiont"`
+}
+
+// Daily is the response to DriveAnalysisBasicScreenRequestEx.php:
+//
+//	{
+//	  "status": 200,
+//	  "DriveAnalysisBasicScreenResponsePersonalData": {
+//	    "DateSummary": {
+//	      "TargetDate": "2018-08-12",
+//	      "ElectricMileage": "11.9",
+//	      "ElectricMileageLevel": "5",
+//	      "PowerConsumptMoter": "140.5",
+//	      "PowerConsumptMoterLevel": "5",
+//	      "PowerConsumptMinus": "29.3",
+//	      "PowerConsumptMinusLevel": "2",
+//	      "PowerConsumptAUX": "7.4",
+//	      "PowerConsumptAUXLevel": "5",
+//	      "DisplayDate": "Aug 12, 18"
+//	    },
+//	    "ElectricCostScale": "kWh/100km"
+//	  },
+//	  "AdviceList": {
+//	    "Advice": {
+//	      "title": "Drive Tip:",
+//	      "body": "Use remote climate control or timer so that the cabin will be at a comfortable temperature before starting.  This allows the car to save energy whilst being driven."
+//	    }
+//	  }
+//	}
+type Daily struct {
+	Base
+	Data struct {
+		Stats struct {
+			TargetDate              string
+			ElectricMileage         Number
+			ElectricMileageLevel    Number
+			PowerConsumptMoter      Number
+			PowerConsumptMoterLevel Number
+			PowerConsumptMinus      Number
+			PowerConsumptMinusLevel Number
+			PowerConsumptAUX        Number
+			PowerConsumptAUXLevel   Number
+		} `json:"DateSummary"`
+		ElectricCostScale string
+	} `json:"DriveAnalysisBasicScreenResponsePersonalData"`
+}
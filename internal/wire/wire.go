@@ -0,0 +1,158 @@
+// Package wire defines the shapes of the responses from the Carwings
+// service, and the types for values in them that need special parsing.
+// They are internal, so they can change along with the service
+// without affecting the carwings package's API.
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is implemented by every response, through Base.
+type Response interface {
+	Status() int
+	ErrorMessage() string
+}
+
+// Base holds the fields common to every response.
+type Base struct {
+	StatusCode json.RawMessage `json:"status"`
+	Message    string          `json:"message"`
+}
+
+// Status returns the status code in the response, which is sent
+// either as a number or a string.
+func (r *Base) Status() int {
+	s := strings.Trim(string(r.StatusCode), `"`)
+	v, _ := strconv.Atoi(s)
+	return v
+}
+
+// ErrorMessage returns the message in the response, if any.
+func (r *Base) ErrorMessage() string {
+	return r.Message
+}
+
+// Time is a time in one of the many formats the Carwings service uses.
+// Missing times are zero.
+type Time time.Time
+
+func (cwt *Time) UnmarshalJSON(data []byte) error {
+	// Missing times are sent as empty strings, and sometimes as
+	// null or an empty array or object
+	if data == nil || IsEmpty(data) {
+		return nil
+	}
+
+	// Carwings uses at least five different date formats! 🙄🙄🙄
+	t, err := time.Parse(`"2006\/01\/02 15:04"`, string(data))
+	if err == nil {
+		*cwt = Time(t)
+		return nil
+	}
+
+	t, err = time.Parse(`"2006-01-02 15:04:05"`, string(data))
+	if err == nil {
+		*cwt = Time(t)
+		return nil
+	}
+
+	// Also e.g. "UserVehicleBoundTime": "2018-08-04T15:08:33Z"
+	t, err = time.Parse(`"2006-01-02T15:04:05Z"`, string(data))
+	if err == nil {
+		*cwt = Time(t)
+		return nil
+	}
+
+	// Also e.g. "GpsDatetime": "2018-08-05T10:18:47" in monthly statistics response
+	t, err = time.Parse(`"2006-01-02T15:04:05"`, string(data))
+	if err == nil {
+		*cwt = Time(t)
+		return nil
+	}
+
+	// Also e.g. "LastScheduledTime": "2018-08-04T15:08:33Z" in ClimateControlSchedule response
+	t, err = time.Parse(`"Jan _2, 2006 03:04 PM"`, string(data))
+	if err == nil {
+		*cwt = Time(t)
+		return nil
+	}
+
+	return fmt.Errorf("cannot parse %q as carwings time", string(data))
+}
+
+// FixLocation alters the location associated with the time, without changing
+// the value.  This is needed since all times are parsed as if they were UTC
+// when in fact some of them are in the timezone specified in the session.
+func (cwt Time) FixLocation(location *time.Location) Time {
+	t := time.Time(cwt)
+	return Time(time.Date(
+		t.Year(),
+		t.Month(),
+		t.Day(),
+		t.Hour(),
+		t.Minute(),
+		t.Second(),
+		t.Nanosecond(),
+		location,
+	))
+}
+
+// Number is a number the Carwings service sends either as a JSON
+// number or a string, and sometimes as an empty string, null, or an
+// empty array or object when it's missing.  Those are zero.  Every
+// numeric field in a response is a Number, since the same field has
+// come in different forms depending on the region, the vehicle and the
+// API version, such as:
+//
+//	"BatteryCapacity": "240"
+//	"BatteryCapacity": 240
+//	"responseFlag": "1"
+//	"Inc_temp": 21.5
+//	"ElectricPrice": ""
+//	"CruisingRangeAcOn": " 93000.0"
+type Number float64
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	if IsEmpty(data) {
+		*n = 0
+		return nil
+	}
+
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("cannot parse %s as carwings number", data)
+	}
+	*n = Number(f)
+	return nil
+}
+
+// Int returns n as an int, truncating any fractional part.
+func (n Number) Int() int {
+	return int(n)
+}
+
+// Float returns n as a float64.
+func (n Number) Float() float64 {
+	return float64(n)
+}
+
+// IsEmpty returns whether data is one of the values the Carwings
+// service sends in place of missing data: an empty string, null, or
+// an empty array or object.
+func IsEmpty(data []byte) bool {
+	switch strings.Join(strings.Fields(string(data)), "") {
+	case `""`, "null", "[]", "{}":
+		return true
+	}
+	return false
+}
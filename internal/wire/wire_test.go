@@ -0,0 +1,231 @@
+package wire
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNumberUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    float64
+		wantErr bool
+	}{
+		{data: `"240"`, want: 240},
+		{data: `240`, want: 240},
+		{data: `21.5`, want: 21.5},
+		{data: `"1"`, want: 1},
+		{data: `" 93000.0"`, want: 93000},
+		{data: `"-3"`, want: -3},
+		{data: `""`, want: 0},
+		{data: `null`, want: 0},
+		{data: `[]`, want: 0},
+		{data: `{}`, want: 0},
+		{data: `[ ]`, want: 0},
+		{data: `"NaN"`, wantErr: true},
+		{data: `"Inf"`, wantErr: true},
+		{data: `"-Infinity"`, wantErr: true},
+		{data: `"abc"`, wantErr: true},
+		{data: `"12a"`, wantErr: true},
+		{data: `"   "`, want: 0},
+		{data: `true`, wantErr: true},
+		{data: `[1]`, wantErr: true},
+		{data: `{"Value":"1"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		// Start from a non-zero value, so empty values are seen to
+		// reset it
+		n := Number(7)
+		err := json.Unmarshal([]byte(tt.data), &n)
+		switch {
+		case tt.wantErr && err == nil:
+			t.Errorf("%s: got %v, want error", tt.data, n)
+		case !tt.wantErr && err != nil:
+			t.Errorf("%s: %v", tt.data, err)
+		case !tt.wantErr && n.Float() != tt.want:
+			t.Errorf("%s: got %v, want %v", tt.data, n, tt.want)
+		}
+	}
+}
+
+func TestNumberInt(t *testing.T) {
+	if got := Number(93000.9).Int(); got != 93000 {
+		t.Errorf("Int() = %d, want 93000", got)
+	}
+}
+
+func FuzzNumber(f *testing.F) {
+	for _, seed := range []string{`"240"`, `240`, `21.5`, `""`, `" 93000.0"`, `null`, `[]`, `{}`, `"NaN"`, `"1e400"`, `"0x1p-2"`, `"abc"`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		n := Number(7)
+		err := n.UnmarshalJSON(data)
+		if IsEmpty(data) {
+			if err != nil || n != 0 {
+				t.Errorf("%s: got %v, %v, want 0", data, n, err)
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+		if f := n.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			t.Errorf("%s: got %v", data, f)
+		}
+	})
+}
+
+func FuzzTime(f *testing.F) {
+	for _, seed := range []string{
+		`"2018\/08\/05 10:18"`,
+		`"2018-08-05 10:18:47"`,
+		`"2018-08-04T15:08:33Z"`,
+		`"2018-08-05T10:18:47"`,
+		`"Aug  5, 2018 10:18 PM"`,
+		`""`, `null`, `[]`, `{}`, `"2018-02-30 10:18:47"`, `1533464327`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var cwt Time
+		err := cwt.UnmarshalJSON(data)
+		if IsEmpty(data) {
+			if err != nil || !time.Time(cwt).IsZero() {
+				t.Errorf("%s: got %v, %v, want zero", data, time.Time(cwt), err)
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		// Every format is the vehicle's wall clock time, which
+		// FixLocation keeps
+		loc := time.FixedZone("test", 9*60*60)
+		got, want := time.Time(cwt.FixLocation(loc)), time.Time(cwt)
+		if got.Location() != loc || got.Format("2006-01-02 15:04:05") != want.Format("2006-01-02 15:04:05") {
+			t.Errorf("%s: FixLocation gave %v, want %v in %v", data, got, want, loc)
+		}
+	})
+}
+
+// Battery status records in the shapes each region's service sends
+// them: NNA (North America) as strings, NE (Europe) as a mix of
+// numbers and strings with padding, and NML (Japan) with missing
+// fields as empty strings, arrays and objects.
+var batteryRecords = []struct {
+	region string
+	data   string
+	want   batteryWant
+}{
+	{
+		region: "NNA",
+		data: `{
+			"OperationResult": "START",
+			"OperationDateAndTime": "Aug 5, 2018 10:18 AM",
+			"BatteryStatus": {
+				"BatteryChargingStatus": "NOT_CHARGING",
+				"BatteryCapacity": "240",
+				"BatteryRemainingAmount": "220",
+				"BatteryRemainingAmountWH": "",
+				"BatteryRemainingAmountkWH": "",
+				"SOC": {"Value": "91"}
+			},
+			"PluginState": "CONNECTED",
+			"CruisingRangeAcOn": "115328.0",
+			"CruisingRangeAcOff": "117224.0",
+			"TimeRequiredToFull": {"HourRequiredToFull": "", "MinutesRequiredToFull": ""},
+			"TimeRequiredToFull200": {"HourRequiredToFull": "3", "MinutesRequiredToFull": "30"},
+			"TimeRequiredToFull200_6kW": {"HourRequiredToFull": "1", "MinutesRequiredToFull": "30"},
+			"NotificationDateAndTime": "2018\/08\/05 10:18",
+			"TargetDate": "2018\/08\/05 14:18"
+		}`,
+		want: batteryWant{capacity: 240, remaining: 220, soc: 91, acOn: 115328, acOff: 117224, hours200: 3, minutes200: 30},
+	},
+	{
+		region: "NE",
+		data: `{
+			"BatteryStatus": {
+				"BatteryChargingStatus": "NORMAL_CHARGING",
+				"BatteryCapacity": 240,
+				"BatteryRemainingAmount": 150,
+				"BatteryRemainingAmountWH": "18750",
+				"BatteryRemainingAmountkWH": "",
+				"SOC": {"Value": 62}
+			},
+			"PluginState": "CONNECTED",
+			"CruisingRangeAcOn": " 93000.0",
+			"CruisingRangeAcOff": " 97000.0",
+			"TimeRequiredToFull": {"HourRequiredToFull": "8", "MinutesRequiredToFull": "0"},
+			"TimeRequiredToFull200": {"HourRequiredToFull": 4, "MinutesRequiredToFull": 30},
+			"TimeRequiredToFull200_6kW": null,
+			"NotificationDateAndTime": "2018-08-05 10:18:47",
+			"ChargeMode": "220V"
+		}`,
+		want: batteryWant{capacity: 240, remaining: 150, remainingWH: 18750, soc: 62, acOn: 93000, acOff: 97000, hours200: 4, minutes200: 30},
+	},
+	{
+		region: "NML",
+		data: `{
+			"BatteryStatus": {
+				"BatteryChargingStatus": "NOT_CHARGING",
+				"BatteryCapacity": "12",
+				"BatteryRemainingAmount": "9",
+				"BatteryRemainingAmountWH": [],
+				"BatteryRemainingAmountkWH": {},
+				"SOC": {}
+			},
+			"PluginState": "NOT_CONNECTED",
+			"CruisingRangeAcOn": "",
+			"CruisingRangeAcOff": "98000",
+			"TimeRequiredToFull": {"HourRequiredToFull": [], "MinutesRequiredToFull": null},
+			"TimeRequiredToFull200": {"HourRequiredToFull": "", "MinutesRequiredToFull": ""},
+			"TimeRequiredToFull200_6kW": {"HourRequiredToFull": "", "MinutesRequiredToFull": ""},
+			"NotificationDateAndTime": ""
+		}`,
+		want: batteryWant{capacity: 12, remaining: 9, acOff: 98000},
+	},
+}
+
+type batteryWant struct {
+	capacity, remaining, remainingWH, soc int
+	acOn, acOff                           int
+	hours200, minutes200                  int
+}
+
+func TestBatteryStatusRecordNumbers(t *testing.T) {
+	for _, tt := range batteryRecords {
+		var rec BatteryStatusRecord
+		if err := json.Unmarshal([]byte(tt.data), &rec); err != nil {
+			t.Errorf("%s: %v", tt.region, err)
+			continue
+		}
+
+		bs := rec.BatteryStatus
+		got := batteryWant{
+			capacity:    bs.BatteryCapacity.Int(),
+			remaining:   bs.BatteryRemainingAmount.Int(),
+			remainingWH: bs.BatteryRemainingAmountWH.Int(),
+			soc:         bs.SOC.Value.Int(),
+			acOn:        rec.CruisingRangeAcOn.Int(),
+			acOff:       rec.CruisingRangeAcOff.Int(),
+			hours200:    rec.TimeRequiredToFull200.HourRequiredToFull.Int(),
+			minutes200:  rec.TimeRequiredToFull200.MinutesRequiredToFull.Int(),
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestBatteryStatusRecordGarbage(t *testing.T) {
+	data := `{"BatteryStatus": {"BatteryCapacity": "n/a", "SOC": {"Value": "91"}}}`
+	var rec BatteryStatusRecord
+	if err := json.Unmarshal([]byte(data), &rec); err == nil {
+		t.Errorf("got %+v, want error", rec)
+	}
+}
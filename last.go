@@ -0,0 +1,122 @@
+package carwings
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// lastStatus is the last status of each kind a Session retrieved in
+// full, and when.
+type lastStatus struct {
+	Battery          BatteryStatus
+	BatteryRetrieved time.Time `json:",omitempty"`
+	Climate          ClimateStatus
+	ClimateRetrieved time.Time `json:",omitempty"`
+}
+
+// WithLastStatusStore keeps the statuses returned by LastBatteryStatus
+// and LastClimateStatus in st, so they are still available to the
+// next Session, such as in the next run of a program.
+func WithLastStatusStore(st Store) Option {
+	return func(s *Session) { s.lastStore = st }
+}
+
+// LastBatteryStatus returns the last battery status the session
+// retrieved without errors, and how long ago, for showing data that
+// is out of date but still useful when the Carwings service can't be
+// reached.  ok is false if there isn't one.
+func (s *Session) LastBatteryStatus() (bs BatteryStatus, age time.Duration, ok bool) {
+	last := s.lastStatuses()
+	if last.BatteryRetrieved.IsZero() {
+		return BatteryStatus{}, 0, false
+	}
+
+	bs = last.Battery
+	if bs.Retrieved.IsZero() {
+		// Saved before statuses had it
+		bs.Retrieved = last.BatteryRetrieved
+	}
+	if loc := s.location(); loc != nil && !bs.Timestamp.IsZero() {
+		bs.Timestamp = bs.Timestamp.In(loc)
+	}
+	return bs, s.now().Sub(last.BatteryRetrieved), true
+}
+
+// LastClimateStatus is like LastBatteryStatus, for the climate control
+// status.
+func (s *Session) LastClimateStatus() (cs ClimateStatus, age time.Duration, ok bool) {
+	last := s.lastStatuses()
+	if last.ClimateRetrieved.IsZero() {
+		return ClimateStatus{}, 0, false
+	}
+
+	cs = last.Climate
+	if cs.Retrieved.IsZero() {
+		cs.Retrieved = last.ClimateRetrieved
+	}
+	if loc := s.location(); loc != nil {
+		cs.LastOperationTime = cs.LastOperationTime.In(loc)
+		cs.ACStopTime = cs.ACStopTime.In(loc)
+	}
+	return cs, s.now().Sub(last.ClimateRetrieved), true
+}
+
+// lastStatuses returns the last statuses.  Their times are in the time
+// zone they were saved with, which may have lost its name.
+func (s *Session) lastStatuses() lastStatus {
+	s.loadLastStatus()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// loadLastStatus loads the statuses from the store the first time it
+// is called.  A store that can't be read is treated as empty.
+func (s *Session) loadLastStatus() {
+	s.lastOnce.Do(func() {
+		if s.lastStore == nil {
+			return
+		}
+
+		data, err := s.lastStore.Load()
+		if err != nil {
+			return
+		}
+
+		var last lastStatus
+		if err := json.Unmarshal(data, &last); err != nil {
+			if s.debug() {
+				s.logf("Error loading last status: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.last = last
+		s.mu.Unlock()
+	})
+}
+
+// setLastStatus records a status with update and saves them all to
+// the store, if there is one.
+func (s *Session) setLastStatus(update func(*lastStatus)) {
+	s.loadLastStatus()
+
+	s.mu.Lock()
+	update(&s.last)
+	last := s.last
+	s.mu.Unlock()
+
+	if s.lastStore == nil {
+		return
+	}
+
+	data, err := json.Marshal(last)
+	if err == nil {
+		err = s.lastStore.Save(append(data, '\n'))
+	}
+	if err != nil && s.debug() {
+		s.logf("Error saving last status: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package carwings
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// LocateRequest sends a request to find the vehicle's position.  This
+// is an asynchronous operation: it returns a "result key" that can be
+// used to poll for status with the CheckLocateRequest method.
+func (s *Session) LocateRequest() (string, error) {
+	return s.LocateRequestContext(context.Background())
+}
+
+// LocateRequestContext is like LocateRequest, but uses ctx for its requests.
+func (s *Session) LocateRequestContext(ctx context.Context) (string, error) {
+	resp, err := call[wire.ResultKey](ctx, s, "MyCarFinderRequest.php", nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.ResultKey, nil
+}
+
+// CheckLocateRequest returns whether the LocateRequest has finished.
+// The position is then available from GetLocation.
+func (s *Session) CheckLocateRequest(resultKey string) (bool, error) {
+	return s.CheckLocateRequestContext(context.Background(), resultKey)
+}
+
+// CheckLocateRequestContext is like CheckLocateRequest, but uses ctx for its requests.
+func (s *Session) CheckLocateRequestContext(ctx context.Context, resultKey string) (bool, error) {
+	params := url.Values{}
+	params.Set("resultKey", resultKey)
+
+	resp, err := call[wire.LocateResult](ctx, s, "MyCarFinderResultRequest.php", params)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.ResponseFlag != 1 {
+		return false, nil
+	}
+
+	lat, err := strconv.ParseFloat(resp.Latitude, 64)
+	if err != nil {
+		return false, &decodeError{err}
+	}
+	lng, err := strconv.ParseFloat(resp.Longitude, 64)
+	if err != nil {
+		return false, &decodeError{err}
+	}
+
+	loc := Location{
+		Timestamp: time.Time(resp.ReceivedDate).In(s.location()),
+		Latitude:  lat,
+		Longitude: lng,
+	}
+
+	s.mu.Lock()
+	s.lastLocation = loc
+	s.mu.Unlock()
+
+	return true, nil
+}
+
+// GetLocation returns the latest cached vehicle position result.
+func (s *Session) GetLocation() Location {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastLocation
+}
@@ -0,0 +1,198 @@
+package carwings
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// An Option configures a Session created by NewSession.
+type Option func(*Session)
+
+// NewSession returns a Session connected to the Carwings service with
+// the given credentials, configured by opts.  Unless WithRegion is
+// given, the vehicle is expected to be in the USA.
+func NewSession(username, password string, opts ...Option) (*Session, error) {
+	return NewSessionContext(context.Background(), username, password, opts...)
+}
+
+// NewSessionContext is like NewSession, but uses ctx for its requests.
+func NewSessionContext(ctx context.Context, username, password string, opts ...Option) (*Session, error) {
+	s := &Session{Region: RegionUSA}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.ConnectContext(ctx, username, password); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WithRegion sets the region where the vehicle operates, one of the
+// Region constants.
+func WithRegion(region string) Option {
+	return func(s *Session) { s.Region = region }
+}
+
+// WithVIN selects the vehicle to use, for accounts with more than one.
+func WithVIN(vin string) Option {
+	return func(s *Session) { s.VIN = vin }
+}
+
+// WithHTTPClient sets the HTTP client used for requests, instead of
+// Client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Session) { s.client = c }
+}
+
+// WithBaseURL sets the URL of the Carwings service, instead of the
+// one in RegionBaseURLs for the Session's region or BaseURL.
+func WithBaseURL(url string) Option {
+	return func(s *Session) { s.baseURL = url }
+}
+
+// WithStore saves the session in st, and reuses the saved session
+// instead of logging in again when there is one.
+func WithStore(st Store) Option {
+	return func(s *Session) { s.store = st }
+}
+
+// WithLogger logs the session's requests and responses, and other
+// debugging output, to l.
+func WithLogger(l *log.Logger) Option {
+	return func(s *Session) { s.logger = l }
+}
+
+// WithRetry makes up to attempts tries at each request when the
+// Carwings service can't be reached or is unavailable, waiting wait
+// after the first failure and twice as long after each one after
+// that.  Note that a command whose response was lost may be carried
+// out more than once.
+func WithRetry(attempts int, wait time.Duration) Option {
+	return WithRetryPolicy(RetryPolicy{Attempts: attempts, Wait: wait})
+}
+
+// A RetryPolicy says which failed requests a Session sends again, and
+// how long it waits between tries.
+type RetryPolicy struct {
+	// The most tries at each request.  0 or 1 doesn't retry.
+	Attempts int
+
+	// How long to wait after the first failure.  Each wait after
+	// that is twice as long as the one before, up to MaxWait if it
+	// isn't zero.
+	Wait    time.Duration
+	MaxWait time.Duration
+
+	// The HTTP or API status codes, of a ServiceError or
+	// APIStatusError, to try again on.  Requests that can't reach
+	// the service are always tried again.  If RetryOn is nil, every
+	// ServiceError is.
+	RetryOn []int
+}
+
+// retryable reports whether a request that failed with err may
+// succeed if it is sent again.
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, ErrTransport) {
+		return true
+	}
+
+	var code int
+	var serr *ServiceError
+	var aerr *APIStatusError
+	switch {
+	case errors.As(err, &serr):
+		if p.RetryOn == nil {
+			return true
+		}
+		code = serr.StatusCode
+	case errors.As(err, &aerr):
+		code = aerr.Code
+	default:
+		return false
+	}
+
+	for _, c := range p.RetryOn {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRetryPolicy sets which failed requests the session sends again
+// and how often, like WithRetry with more control.  Interactive
+// programs might give up quickly, while daemons keep trying for
+// longer.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(s *Session) { s.retry = p }
+}
+
+// WithRequestTimeout gives up on each try at a request to the
+// Carwings service after d, so that one that hangs can be retried.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Session) { s.requestTimeout = d }
+}
+
+// WithOperationTimeout gives up on each request to the Carwings
+// service after d, including all of its tries and the waits between
+// them, unless the context given to the method has an earlier
+// deadline.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(s *Session) { s.operationTimeout = d }
+}
+
+// A RequestHook is called after every attempt at a request to the
+// Carwings service, with the endpoint, how long it took and the error
+// it failed with, if any.  Requests that fail with ErrNotLoggedIn are
+// followed by logging in again.
+type RequestHook func(endpoint string, elapsed time.Duration, err error)
+
+// WithRequestHook calls h after every request the session sends,
+// which is useful for monitoring the service.
+func WithRequestHook(h RequestHook) Option {
+	return func(s *Session) { s.requestHook = h }
+}
+
+// WithLocation sets the time zone of the times the vehicle reports,
+// and of the dates sent to the service, instead of the time zone of
+// the account.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Session) { s.fixedLoc = loc }
+}
+
+// A Clock tells the time.  Sessions use the system clock unless
+// WithClock gives them another, such as a fixed one in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock sets the clock used for the dates of requests and for
+// MinUpdateInterval.
+func WithClock(c Clock) Option {
+	return func(s *Session) { s.clock = c }
+}
+
+// WithMinUpdateInterval sets the Session's MinUpdateInterval.
+func WithMinUpdateInterval(d time.Duration) Option {
+	return func(s *Session) { s.MinUpdateInterval = d }
+}
+
+// WithParseMode sets the Session's ParseMode.
+func WithParseMode(mode ParseMode) Option {
+	return func(s *Session) { s.ParseMode = mode }
+}
+
+// WithRequirePluggedIn sets the Session's RequirePluggedIn.
+func WithRequirePluggedIn(require bool) Option {
+	return func(s *Session) { s.RequirePluggedIn = require }
+}
+
+// WithMinClimateSOC sets the Session's MinClimateSOC.
+func WithMinClimateSOC(percent int) Option {
+	return func(s *Session) { s.MinClimateSOC = percent }
+}
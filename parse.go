@@ -0,0 +1,213 @@
+package carwings
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// ParseMode controls how a Session handles responses from the Carwings
+// service that don't look the way they're expected to.
+type ParseMode int
+
+const (
+	// ParseDefault fails on responses that can't be parsed, but
+	// ignores fields it doesn't know about and tolerates the empty
+	// strings, arrays and objects the service sometimes sends in
+	// place of numbers and times.
+	ParseDefault ParseMode = iota
+
+	// ParseStrict also fails on unknown fields and empty values.
+	// It is meant for tests, to notice changes to the API early.
+	ParseStrict
+
+	// ParseLenient makes BatteryStatus and ClimateControlStatus
+	// return the fields they could parse along with a
+	// *PartialError when some of a response can't be parsed,
+	// instead of failing outright.  It is meant for production,
+	// so dashboards degrade gracefully.
+	ParseLenient
+)
+
+// checkStrict returns an error if data, a JSON value to be decoded
+// into v, has fields v doesn't know about or empty values in place of
+// numbers, times or objects.  Field names are prefixed with prefix.
+func checkStrict(prefix string, data []byte, v interface{}) error {
+	found := map[string]string{}
+	inspectFields(data, reflect.TypeOf(v), prefix, found)
+	if len(found) == 0 {
+		return nil
+	}
+
+	anomalies := make([]string, 0, len(found))
+	for f, what := range found {
+		anomalies = append(anomalies, what+" "+f)
+	}
+	sort.Strings(anomalies)
+	return &decodeError{fmt.Errorf("strict parsing: %s", strings.Join(anomalies, ", "))}
+}
+
+const (
+	unknownField = "unknown field"
+	emptyValue   = "empty value"
+)
+
+// inspectFields records in found the fields in the JSON value data
+// that type t doesn't have, as unknownField, and those with empty
+// values where t expects a number, time or object, as emptyValue.
+// Fields that are decoded later, like json.RawMessage, aren't
+// examined.
+func inspectFields(data []byte, t reflect.Type, prefix string, found map[string]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return
+	}
+
+	name := strings.TrimSuffix(prefix, ".")
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		if wire.IsEmpty(data) && name != "" {
+			found[name] = emptyValue
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			if wire.IsEmpty(data) && name != "" {
+				found[name] = emptyValue
+			}
+			return
+		}
+		for key, raw := range obj {
+			sf, ok := jsonField(t, key)
+			if !ok {
+				found[prefix+key] = unknownField
+				continue
+			}
+			inspectFields(raw, sf.Type, prefix+key+".", found)
+		}
+
+	case reflect.Slice, reflect.Array:
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil {
+			return
+		}
+		for _, raw := range list {
+			inspectFields(raw, t.Elem(), prefix, found)
+		}
+	}
+}
+
+// decode parses data, the field named field of a response from
+// endpoint, into the struct pointed to by v, according to the
+// Session's ParseMode.  In ParseLenient mode, it goes on to parse
+// every field it can when some of them fail, and returns a
+// *PartialError describing those.
+func (s *Session) decode(endpoint, field string, data []byte, v interface{}) error {
+	prefix := ""
+	if field != "" {
+		prefix = field + "."
+	}
+	if DebugUnknownFields {
+		s.reportUnknownFields(endpoint, prefix, data, v)
+	}
+	if s.ParseMode == ParseStrict {
+		if err := checkStrict(prefix, data, v); err != nil {
+			return err
+		}
+	}
+
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+	if s.ParseMode != ParseLenient {
+		return &decodeError{err}
+	}
+
+	var fields []FieldError
+	if !decodeFields(data, reflect.ValueOf(v).Elem(), "", &fields) {
+		// Not even an object
+		return &decodeError{err}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return &PartialError{Fields: fields}
+}
+
+// decodeFields parses the JSON object in data into the struct value v
+// one field at a time, recursing into nested structs, and appends
+// the fields that can't be parsed to errs.  It returns false if data
+// isn't an object.
+func decodeFields(data []byte, v reflect.Value, prefix string, errs *[]FieldError) bool {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return false
+	}
+
+	for key, raw := range obj {
+		one, _ := json.Marshal(map[string]json.RawMessage{key: raw})
+		err := json.Unmarshal(one, v.Addr().Interface())
+		if err == nil {
+			continue
+		}
+
+		f, ok := structField(v, key)
+		if !ok {
+			continue
+		}
+		f.Set(reflect.Zero(f.Type()))
+		if f.Kind() == reflect.Struct && decodeFields(raw, f, prefix+key+".", errs) {
+			continue
+		}
+		*errs = append(*errs, FieldError{Field: prefix + key, Err: err})
+	}
+
+	return true
+}
+
+// structField returns the field of the struct value v that
+// encoding/json would decode key into.
+func structField(v reflect.Value, key string) (reflect.Value, bool) {
+	sf, ok := jsonField(v.Type(), key)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return v.FieldByIndex(sf.Index), true
+}
+
+// jsonField returns the field of the struct type t that encoding/json
+// would decode key into, including the fields of embedded structs.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			if f, ok := jsonField(sf.Type, key); ok {
+				f.Index = append([]int{i}, f.Index...)
+				return f, true
+			}
+			continue
+		}
+		if sf.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.EqualFold(name, key) {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,244 @@
+package carwings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// testBatteryRecords is the BatteryStatusRecords of a battery status
+// response, with a field to replace by the tests.
+const testBatteryRecords = `{
+	"BatteryStatus": {
+		"BatteryChargingStatus": "NOT_CHARGING",
+		"BatteryCapacity": "240",
+		"BatteryRemainingAmount": "220",
+		"SOC": {"Value": "91"}
+	},
+	"PluginState": "CONNECTED",
+	"CruisingRangeAcOn": "115328.0",
+	"CruisingRangeAcOff": "117224.0",
+	"NotificationDateAndTime": "2018\/08\/05 10:18"
+	%s
+}`
+
+// batteryResponse returns a battery status response with extra, like
+// `, "Field": "value"`, at the end of its records.  Later fields with
+// the same name replace earlier ones.
+func batteryResponse(extra string) string {
+	return fmt.Sprintf(`{"status": 200, "BatteryStatusRecords": %s}`, fmt.Sprintf(testBatteryRecords, extra))
+}
+
+func TestParseModes(t *testing.T) {
+	type result struct {
+		err     string // substring of the error, if any
+		partial []string
+		soc     int
+	}
+	tests := []struct {
+		name  string
+		extra string
+		want  map[ParseMode]result
+	}{
+		{
+			name: "valid",
+			want: map[ParseMode]result{
+				ParseDefault: {soc: 91},
+				ParseStrict:  {soc: 91},
+				ParseLenient: {soc: 91},
+			},
+		},
+		{
+			name:  "unknown field",
+			extra: `, "NewField": "1"`,
+			want: map[ParseMode]result{
+				ParseDefault: {soc: 91},
+				ParseStrict:  {err: "unknown field BatteryStatusRecords.NewField"},
+				ParseLenient: {soc: 91},
+			},
+		},
+		{
+			name:  "empty number",
+			extra: `, "CruisingRangeAcOn": ""`,
+			want: map[ParseMode]result{
+				ParseDefault: {soc: 91},
+				ParseStrict:  {err: "empty value BatteryStatusRecords.CruisingRangeAcOn"},
+				ParseLenient: {soc: 91},
+			},
+		},
+		{
+			name:  "empty time",
+			extra: `, "NotificationDateAndTime": []`,
+			want: map[ParseMode]result{
+				ParseDefault: {soc: 91},
+				ParseStrict:  {err: "empty value BatteryStatusRecords.NotificationDateAndTime"},
+				ParseLenient: {soc: 91},
+			},
+		},
+		{
+			name:  "bad number",
+			extra: `, "CruisingRangeAcOn": "n/a"`,
+			want: map[ParseMode]result{
+				ParseDefault: {err: "cannot parse"},
+				ParseStrict:  {err: "cannot parse"},
+				ParseLenient: {partial: []string{"CruisingRangeAcOn"}, soc: 91},
+			},
+		},
+		{
+			name:  "bad nested number",
+			extra: `, "BatteryStatus": {"BatteryCapacity": "240", "SOC": {"Value": "lots"}}`,
+			want: map[ParseMode]result{
+				ParseDefault: {err: "cannot parse"},
+				ParseStrict:  {err: "cannot parse"},
+				ParseLenient: {partial: []string{"BatteryStatus.SOC.Value"}},
+			},
+		},
+		{
+			name:  "bad time and type",
+			extra: `, "NotificationDateAndTime": "yesterday", "PluginState": 1`,
+			want: map[ParseMode]result{
+				ParseDefault: {err: "invalid response"},
+				ParseStrict:  {err: "invalid response"},
+				ParseLenient: {partial: []string{"NotificationDateAndTime", "PluginState"}, soc: 91},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ts := newTestService(t, map[string]testHandler{
+			"BatteryStatusRecordsRequest": respond(batteryResponse(tt.extra)),
+		})
+		for mode, want := range tt.want {
+			s := newTestSession(t, ts, WithParseMode(mode))
+			bs, err := s.BatteryStatus()
+
+			if want.err == "" && want.partial == nil {
+				if err != nil {
+					t.Errorf("%s, mode %d: %v", tt.name, mode, err)
+				}
+			} else if !errors.Is(err, ErrDecode) {
+				t.Errorf("%s, mode %d: got %v, want ErrDecode", tt.name, mode, err)
+			}
+			if want.err != "" && (err == nil || !strings.Contains(err.Error(), want.err)) {
+				t.Errorf("%s, mode %d: got %v, want error with %q", tt.name, mode, err, want.err)
+			}
+
+			var perr *PartialError
+			if errors.As(err, &perr) != (want.partial != nil) {
+				t.Errorf("%s, mode %d: got %v, want PartialError for %q", tt.name, mode, err, want.partial)
+			}
+			if perr != nil {
+				var fields []string
+				for _, f := range perr.Fields {
+					fields = append(fields, f.Field)
+				}
+				if strings.Join(fields, " ") != strings.Join(want.partial, " ") {
+					t.Errorf("%s, mode %d: got PartialError for %q, want %q", tt.name, mode, fields, want.partial)
+				}
+			}
+			if err == nil || perr != nil {
+				if bs.StateOfCharge != want.soc {
+					t.Errorf("%s, mode %d: state of charge %d, want %d", tt.name, mode, bs.StateOfCharge, want.soc)
+				}
+			}
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, extra := range []string{
+		``,
+		`, "NewField": "1"`,
+		`, "CruisingRangeAcOn": ""`,
+		`, "CruisingRangeAcOn": "n/a"`,
+		`, "BatteryStatus": {"SOC": {"Value": []}}`,
+		`, "BatteryStatus": []`,
+		`, "TimeRequiredToFull": {"HourRequiredToFull": "1", "Extra": {}}`,
+		`, "NotificationDateAndTime": "yesterday", "PluginState": 1`,
+	} {
+		f.Add([]byte(fmt.Sprintf(testBatteryRecords, extra)))
+	}
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`"text"`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rec wire.BatteryStatusRecord
+		whole := json.Unmarshal(data, &rec)
+
+		if err := checkStrict("", data, &wire.BatteryStatusRecord{}); err != nil && !errors.Is(err, ErrDecode) {
+			t.Errorf("checkStrict: %v isn't ErrDecode", err)
+		}
+
+		for _, mode := range []ParseMode{ParseDefault, ParseStrict, ParseLenient} {
+			s := &Session{ParseMode: mode}
+			var rec wire.BatteryStatusRecord
+			err := s.decode("BatteryStatusRecordsRequest.php", "BatteryStatusRecords", data, &rec)
+			if err == nil {
+				if whole != nil && mode != ParseLenient {
+					t.Errorf("mode %d: decoded what json.Unmarshal can't: %v", mode, whole)
+				}
+				continue
+			}
+			if !errors.Is(err, ErrDecode) {
+				t.Errorf("mode %d: %v isn't ErrDecode", mode, err)
+			}
+
+			var perr *PartialError
+			switch {
+			case mode == ParseDefault && whole == nil:
+				t.Errorf("mode %d: %v, but json.Unmarshal succeeded", mode, err)
+			case mode != ParseLenient && errors.As(err, &perr):
+				t.Errorf("mode %d: PartialError outside ParseLenient: %v", mode, err)
+			case mode == ParseLenient && errors.As(err, &perr) && len(perr.Fields) == 0:
+				t.Errorf("mode %d: PartialError without fields", mode)
+			}
+		}
+	})
+}
+
+func TestUsageCounters(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+		want  UsageCounters
+		err   error
+	}{
+		{
+			name:  "counters",
+			extra: `, "QuickChargeCount": "12", "NormalChargeCount": "345"`,
+			want:  UsageCounters{QuickCharges: 12, NormalCharges: 345},
+		},
+		{
+			name: "no counters",
+			err:  ErrUsageCountersUnavailable,
+		},
+		{
+			name:  "one counter",
+			extra: `, "QuickChargeCount": "12"`,
+			err:   ErrUsageCountersUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestService(t, map[string]testHandler{
+				"BatteryStatusRecordsRequest": respond(batteryResponse(tt.extra)),
+			})
+			s := newTestSession(t, ts)
+
+			uc, err := s.UsageCounters()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if uc.QuickCharges != tt.want.QuickCharges || uc.NormalCharges != tt.want.NormalCharges || uc.Timestamp.IsZero() {
+				t.Errorf("counters %+v, want %+v as of the notification", uc, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package carwings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultUpdateTimeout is how long a Poller waits for the vehicle to
+// respond to an update request if its UpdateTimeout is zero.
+const DefaultUpdateTimeout = time.Minute
+
+// A Poller runs the update pipeline for the vehicle of a Session:
+// asking it for new data with UpdateStatus, waiting for it to respond
+// with CheckUpdate, and retrieving its battery and climate control
+// status.  The statuses are passed to its callbacks.
+//
+// A Poller's fields must not be changed while Run is running, but
+// Poll and NextInterval may be called alongside it.
+type Poller struct {
+	Session *Session
+
+	// Update, if true, asks the vehicle for new data before each
+	// retrieval, rather than using whatever it last reported.  This
+	// wakes up its telematics unit, draining its 12V battery a
+	// little each time.
+	Update bool
+
+	// How long to wait for the vehicle to respond to an update
+	// request.  Defaults to DefaultUpdateTimeout.
+	UpdateTimeout time.Duration
+
+	// How often Run polls.  If not zero, ActiveInterval is used
+	// instead of Interval while the vehicle is charging or running
+	// its climate control, and IdleInterval while it is unplugged
+	// with its climate control off.
+	Interval       time.Duration
+	ActiveInterval time.Duration
+	IdleInterval   time.Duration
+
+	// Jitter, if not zero, adds a random delay of up to Jitter to
+	// each interval, so many Pollers don't all hit the service at
+	// once.
+	Jitter time.Duration
+
+	// Skip, if not nil, is called by Run before each poll, and the
+	// poll is skipped if it returns true, such as during quiet
+	// hours.
+	Skip func(now time.Time) bool
+
+	// Lock, if not nil, is held by Run during each poll, such as to
+	// keep it from overlapping other requests to the vehicle.
+	Lock sync.Locker
+
+	// OnBatteryStatus is called with each battery status retrieved.
+	OnBatteryStatus func(bs BatteryStatus)
+
+	// OnClimateStatus, if not nil, is called with each climate
+	// control status retrieved.  The climate control status is only
+	// retrieved if it is set.
+	OnClimateStatus func(cs ClimateStatus)
+
+	// OnError, if not nil, is called by Run with each poll's error.
+	OnError func(err error)
+
+	// The statuses from the last poll, for choosing the interval
+	mu      sync.Mutex
+	battery BatteryStatus
+	climate bool
+	polled  bool
+}
+
+// Poll runs the pipeline once.  With a ParseLenient Session, a
+// PartialError for a status doesn't stop it: the status is still
+// passed on, and the first PartialError is returned at the end.
+func (p *Poller) Poll(ctx context.Context) error {
+	s := p.Session
+
+	if p.Update {
+		key, err := s.UpdateStatusContext(ctx)
+		if err != nil {
+			return err
+		}
+		if err := p.wait(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	var partial error
+	lenient := func(err error) error {
+		var perr *PartialError
+		if errors.As(err, &perr) {
+			if partial == nil {
+				partial = err
+			}
+			return nil
+		}
+		return err
+	}
+
+	bs, err := s.BatteryStatusContext(ctx)
+	if err := lenient(err); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.battery, p.polled = bs, true
+	p.mu.Unlock()
+	if p.OnBatteryStatus != nil {
+		p.OnBatteryStatus(bs)
+	}
+
+	if p.OnClimateStatus != nil {
+		cs, err := s.ClimateControlStatusContext(ctx)
+		if err := lenient(err); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.climate = cs.Running
+		p.mu.Unlock()
+		p.OnClimateStatus(cs)
+	}
+
+	return partial
+}
+
+// wait waits up to the update timeout for the update request with
+// the given result key to finish.
+func (p *Poller) wait(ctx context.Context, key string) error {
+	timeout := p.UpdateTimeout
+	if timeout <= 0 {
+		timeout = DefaultUpdateTimeout
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := pollRequest(wctx, key, p.Session.CheckUpdateContext)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("timed out after %v waiting for the vehicle", timeout)
+	}
+	return err
+}
+
+// Run polls straight away, and then after each interval until ctx is
+// done.
+func (p *Poller) Run(ctx context.Context) {
+	for {
+		if p.Skip == nil || !p.Skip(p.Session.now()) {
+			if p.Lock != nil {
+				p.Lock.Lock()
+			}
+			err := p.Poll(ctx)
+			if p.Lock != nil {
+				p.Lock.Unlock()
+			}
+			if err != nil && ctx.Err() == nil && p.OnError != nil {
+				p.OnError(err)
+			}
+		}
+
+		t := time.NewTimer(p.NextInterval())
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// NextInterval returns how long Run waits before the next poll, based
+// on what the vehicle was doing at the last one.
+func (p *Poller) NextInterval() time.Duration {
+	p.mu.Lock()
+	battery, climate, polled := p.battery, p.climate, p.polled
+	p.mu.Unlock()
+
+	d := p.Interval
+	switch {
+	case p.ActiveInterval > 0 && (charging(battery) || climate):
+		d = p.ActiveInterval
+	case p.IdleInterval > 0 && polled && battery.PluginState == NotConnected && !climate:
+		d = p.IdleInterval
+	}
+
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return d
+}
@@ -0,0 +1,146 @@
+package carwings
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPollerNextInterval(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+		want  time.Duration
+	}{
+		{
+			name:  "charging",
+			extra: `, "BatteryStatus": {"BatteryChargingStatus": "NORMAL_CHARGING", "SOC": {"Value": "50"}}`,
+			want:  time.Minute,
+		},
+		{
+			name:  "plugged in",
+			extra: `, "PluginState": "CONNECTED"`,
+			want:  10 * time.Minute,
+		},
+		{
+			name:  "unplugged",
+			extra: `, "PluginState": "NOT_CONNECTED"`,
+			want:  time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		ts := newTestService(t, map[string]testHandler{
+			"BatteryStatusRecordsRequest": respond(batteryResponse(tt.extra)),
+		})
+		p := &Poller{
+			Session:        newTestSession(t, ts),
+			Interval:       10 * time.Minute,
+			ActiveInterval: time.Minute,
+			IdleInterval:   time.Hour,
+		}
+
+		// Before the first poll, nothing is known about the vehicle
+		if got := p.NextInterval(); got != p.Interval {
+			t.Errorf("%s: before polling, NextInterval() = %v, want %v", tt.name, got, p.Interval)
+		}
+		if err := p.Poll(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := p.NextInterval(); got != tt.want {
+			t.Errorf("%s: NextInterval() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// testLocker is a sync.Locker that reports whether it is locked.
+type testLocker struct {
+	sync.Mutex
+
+	mu     sync.Mutex
+	locked bool
+}
+
+func (l *testLocker) Lock() {
+	l.Mutex.Lock()
+	l.set(true)
+}
+
+func (l *testLocker) Unlock() {
+	l.set(false)
+	l.Mutex.Unlock()
+}
+
+func (l *testLocker) set(locked bool) {
+	l.mu.Lock()
+	l.locked = locked
+	l.mu.Unlock()
+}
+
+func (l *testLocker) isLocked() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.locked
+}
+
+func TestPollerRun(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"BatteryStatusRecordsRequest": respond(batteryResponse("")),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		lock     testLocker
+		skips    int
+		statuses int
+	)
+	p := &Poller{
+		Session:  newTestSession(t, ts),
+		Interval: time.Millisecond,
+		Lock:     &lock,
+
+		// Every other poll is skipped
+		Skip: func(time.Time) bool {
+			skips++
+			return skips%2 == 0
+		},
+		OnBatteryStatus: func(bs BatteryStatus) {
+			if !lock.isLocked() {
+				t.Error("polled without holding Lock")
+			}
+			if statuses++; statuses == 3 {
+				cancel()
+			}
+		},
+		OnError: func(err error) { t.Error(err) },
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	// The interval can be asked for while Run is running
+	for running := true; running; {
+		p.NextInterval()
+		select {
+		case <-done:
+			running = false
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if lock.isLocked() {
+		t.Error("Lock still held after Run")
+	}
+	if statuses != 3 || ts.count("BatteryStatusRecordsRequest") != 3 {
+		t.Errorf("%d statuses from %d polls, want 3", statuses, ts.count("BatteryStatusRecordsRequest"))
+	}
+	if skips < 5 {
+		t.Errorf("Skip called %d times, want at least 5", skips)
+	}
+}
@@ -0,0 +1,164 @@
+package carwings
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrPredictionUnavailable is returned from Predictor.Predict when
+// there isn't enough data to make a prediction.
+var ErrPredictionUnavailable = errors.New("not enough data to predict charging time")
+
+// SOCSample is a state of charge reading at a point in time.
+type SOCSample struct {
+	Time          time.Time
+	StateOfCharge int // percent
+}
+
+// ChargerLevel identifies the kind of charger a vehicle is plugged
+// into, corresponding to the estimates in TimeToFull.
+type ChargerLevel int
+
+const (
+	// 1.4 kW Level 1 (120V 12A) trickle charger
+	Level1 ChargerLevel = iota
+
+	// 3.3 kW Level 2 (240V ~15A) charger
+	Level2
+
+	// 6.6 kW Level 2 (240V ~30A) charger
+	Level2At6kW
+)
+
+func (l ChargerLevel) String() string {
+	switch l {
+	case Level1:
+		return "level 1"
+	case Level2:
+		return "level 2"
+	case Level2At6kW:
+		return "level 2 at 6 kW"
+	default:
+		return "unknown"
+	}
+}
+
+// duration returns the estimate from ttf for the charger level.
+func (l ChargerLevel) duration(ttf TimeToFull) time.Duration {
+	switch l {
+	case Level1:
+		return ttf.Level1
+	case Level2:
+		return ttf.Level2
+	case Level2At6kW:
+		return ttf.Level2At6kW
+	default:
+		return 0
+	}
+}
+
+// Predictor predicts when a charging vehicle will reach a target state
+// of charge.  The prediction is based on the rate of charge seen in
+// the most recent charging session in Samples.  If there aren't
+// enough samples, it falls back to the vehicle's own time to full
+// estimate for the charger Level.
+type Predictor struct {
+	// State of charge history, in any order.
+	Samples []SOCSample
+
+	// The charger the vehicle is plugged into.
+	Level ChargerLevel
+
+	// The vehicle's most recent time to full estimates.
+	TimeToFull TimeToFull
+}
+
+// chargingRate returns the rate of charge, in percent per hour, of the
+// most recent charging session in the samples, along with the most
+// recent sample.
+func (p *Predictor) chargingRate() (float64, SOCSample, bool) {
+	if len(p.Samples) == 0 {
+		return 0, SOCSample{}, false
+	}
+
+	samples := make([]SOCSample, len(p.Samples))
+	copy(samples, p.Samples)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+
+	last := samples[len(samples)-1]
+
+	// Walk backwards to the start of the current charging session,
+	// which is the last point the state of charge went down.
+	first := last
+	for i := len(samples) - 2; i >= 0; i-- {
+		if samples[i].StateOfCharge > first.StateOfCharge {
+			break
+		}
+		first = samples[i]
+	}
+
+	elapsed := last.Time.Sub(first.Time)
+	if elapsed <= 0 || last.StateOfCharge <= first.StateOfCharge {
+		return 0, last, false
+	}
+
+	return float64(last.StateOfCharge-first.StateOfCharge) / elapsed.Hours(), last, true
+}
+
+// rate returns the rate of charge, in percent per hour, to use for
+// predictions, along with the most recent sample.
+func (p *Predictor) rate() (float64, SOCSample, error) {
+	rate, last, ok := p.chargingRate()
+	if last.Time.IsZero() {
+		return 0, last, ErrPredictionUnavailable
+	}
+
+	if !ok {
+		// Assume charging is linear up to full
+		d := p.Level.duration(p.TimeToFull)
+		if d <= 0 || last.StateOfCharge >= 100 {
+			return 0, last, ErrPredictionUnavailable
+		}
+		rate = float64(100-last.StateOfCharge) / d.Hours()
+	}
+
+	return rate, last, nil
+}
+
+// Predict returns the time at which the vehicle is expected to reach
+// the target state of charge, in percent.  If the target has already
+// been reached, the time of the most recent sample is returned.
+func (p *Predictor) Predict(target int) (time.Time, error) {
+	_, last, _ := p.chargingRate()
+	if !last.Time.IsZero() && last.StateOfCharge >= target {
+		return last.Time, nil
+	}
+
+	rate, last, err := p.rate()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	hours := float64(target-last.StateOfCharge) / rate
+	return last.Time.Add(time.Duration(hours * float64(time.Hour))), nil
+}
+
+// LatestStart returns the latest time charging can start for the
+// vehicle to reach the target state of charge, in percent, by the
+// deadline.  If the target has already been reached, the deadline
+// itself is returned.
+func (p *Predictor) LatestStart(target int, deadline time.Time) (time.Time, error) {
+	_, last, _ := p.chargingRate()
+	if !last.Time.IsZero() && last.StateOfCharge >= target {
+		return deadline, nil
+	}
+
+	rate, last, err := p.rate()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	hours := float64(target-last.StateOfCharge) / rate
+	return deadline.Add(-time.Duration(hours * float64(time.Hour))), nil
+}
@@ -0,0 +1,15 @@
+package carwings
+
+// modelQuirks are the ways a model's responses differ from the LEAF's,
+// which the parsing otherwise assumes.
+type modelQuirks struct {
+	// How long climate control runs on battery and plugged in, in
+	// seconds, for vehicles that leave the durations out of their
+	// climate control status.  The e-NV200 runs it for the same 15
+	// minutes and 2 hours as the LEAF.
+	climateBatterySec, climatePluggedSec int
+}
+
+var quirks = map[Model]modelQuirks{
+	ENV200: {climateBatterySec: 15 * 60, climatePluggedSec: 2 * 60 * 60},
+}
@@ -0,0 +1,64 @@
+package carwings
+
+import "errors"
+
+// ErrRangeUnavailable is returned from EstimateRange when there isn't
+// enough data to make an estimate.
+var ErrRangeUnavailable = errors.New("not enough data to estimate range")
+
+// RangeEstimate is a personalized estimate of the vehicle's range,
+// based on the energy remaining in the battery and the driver's recent
+// efficiency, rather than the vehicle's own (often optimistic) guess.
+type RangeEstimate struct {
+	// Estimated range with climate control off, in meters.
+	ACOff int
+
+	// Estimated range with climate control on, in meters.
+	ACOn int
+
+	// The driving efficiency the estimate is based on, in Wh per
+	// km.
+	WhPerKm float64
+}
+
+// Efficiency returns the driving efficiency over the given months, in
+// Wh per km.  It returns zero if no distance was travelled.
+func Efficiency(stats ...MonthlyStatistics) float64 {
+	var (
+		kWh    float64
+		meters int
+	)
+	for _, ms := range stats {
+		kWh += ms.Total.PowerConsumed
+		meters += ms.Total.MetersTravelled
+	}
+
+	if meters == 0 {
+		return 0
+	}
+
+	return kWh * 1000 / (float64(meters) / 1000)
+}
+
+// EstimateRange estimates the vehicle's range from the energy remaining
+// in the battery and the efficiency of driving in the given months.
+// The cost of running climate control is taken from the difference
+// between the vehicle's own estimates with it on and off.
+func EstimateRange(bs BatteryStatus, stats ...MonthlyStatistics) (RangeEstimate, error) {
+	whPerKm := Efficiency(stats...)
+	if whPerKm <= 0 || bs.RemainingWH <= 0 {
+		return RangeEstimate{}, ErrRangeUnavailable
+	}
+
+	acOff := int(float64(bs.RemainingWH) / whPerKm * 1000)
+	acOn := acOff
+	if bs.CruisingRangeACOff > 0 && bs.CruisingRangeACOn > 0 {
+		acOn = int(float64(acOff) * float64(bs.CruisingRangeACOn) / float64(bs.CruisingRangeACOff))
+	}
+
+	return RangeEstimate{
+		ACOff:   acOff,
+		ACOn:    acOn,
+		WhPerKm: whPerKm,
+	}, nil
+}
@@ -0,0 +1,355 @@
+package carwings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// Connect establishes a new authenticated Session with the Carwings
+// service.
+func (s *Session) Connect(username, password string) error {
+	return s.ConnectContext(context.Background(), username, password)
+}
+
+// ConnectContext is like Connect, but uses ctx for its requests.
+func (s *Session) ConnectContext(ctx context.Context, username, password string) error {
+	params := url.Values{}
+	params.Set("initial_app_str", initialAppStrings)
+
+	var initResp wire.InitialApp
+	if err := s.request(ctx, "InitialApp_v2.php", params, &initResp); err != nil {
+		return err
+	}
+
+	encpw, err := encrypt(password, initResp.Baseprm)
+	if err != nil {
+		return err
+	}
+
+	s.username = username
+	s.encpw = encpw
+
+	if st := s.sessionStore(); st != nil {
+		if err := s.load(st); err == nil {
+			return nil
+		} else if s.debug() {
+			s.logf("Error loading saved session: %v", err)
+		}
+	}
+
+	return s.LoginContext(ctx)
+}
+
+// Login authenticates with the Carwings service, using the credentials
+// provided to Connect.
+func (s *Session) Login() error {
+	return s.LoginContext(context.Background())
+}
+
+// LoginContext is like Login, but uses ctx for its requests.
+func (s *Session) LoginContext(ctx context.Context) error {
+	params := url.Values{}
+	params.Set("initial_app_str", initialAppStrings)
+
+	params.Set("UserId", s.username)
+	params.Set("Password", s.encpw)
+	params.Set("RegionCode", s.Region)
+
+	var loginResp wire.Login
+	if err := s.request(ctx, "UserLoginRequest.php", params, &loginResp); err != nil {
+		return err
+	}
+
+	var vis []wire.VehicleInfo
+	switch {
+	case len(loginResp.VehicleInfos) > 0:
+		vis = loginResp.VehicleInfos
+
+	case len(loginResp.VehicleInfoList.VehicleInfos) > 0:
+		vis = loginResp.VehicleInfoList.VehicleInfos
+
+	case len(loginResp.CustomerInfo.VehicleInfo.VIN) > 0:
+		vis = []wire.VehicleInfo{loginResp.CustomerInfo.VehicleInfo}
+
+	default:
+		vis = []wire.VehicleInfo{loginResp.VehicleInfo}
+	}
+
+	s.mu.Lock()
+	want := s.VIN
+	s.mu.Unlock()
+
+	var vi wire.VehicleInfo
+	var vins []string
+	for _, v := range vis {
+		if v.VIN == "" {
+			continue
+		}
+		vins = append(vins, v.VIN)
+		if vi.VIN == "" && (want == "" || v.VIN == want) {
+			vi = v
+		}
+	}
+
+	if vi.VIN == "" {
+		return ErrVehicleInfoUnavailable
+	}
+
+	loc, err := time.LoadLocation(loginResp.CustomerInfo.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	s.mu.Lock()
+	s.customSessionID = vi.CustomSessionID
+	if s.VIN == "" {
+		// Logging in again picks the same vehicle, so VIN never
+		// changes once set
+		s.VIN = vi.VIN
+	}
+	s.vins = vins
+	s.tz = loginResp.CustomerInfo.Timezone
+	s.loc = loc
+	s.loggedIn = s.now()
+	s.mu.Unlock()
+
+	if st := s.sessionStore(); st != nil {
+		return s.save(st)
+	}
+
+	return nil
+}
+
+// LoggedIn returns when the Session logged in, which may have been in
+// an earlier process for a saved session.  It is the zero time if
+// that isn't known.
+func (s *Session) LoggedIn() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loggedIn
+}
+
+// LastContact returns when the Carwings service last answered one of
+// the Session's requests successfully, or the zero time if it hasn't.
+func (s *Session) LastContact() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastContact
+}
+
+// Expiry returns when the session is expected to expire,
+// SessionLifetime after it logged in, so long-running programs can
+// log in again ahead of time.  It is the zero time if when the
+// session logged in isn't known.
+func (s *Session) Expiry() time.Time {
+	loggedIn := s.LoggedIn()
+	if loggedIn.IsZero() {
+		return time.Time{}
+	}
+	return loggedIn.Add(SessionLifetime)
+}
+
+// reloginRetry is how long KeepLoggedIn waits before trying again
+// when logging in fails.
+const reloginRetry = 5 * time.Minute
+
+// KeepLoggedIn logs the Session in again interval after each time it
+// logged in, until ctx is done, so the first request after the
+// session expires doesn't have to wait for it.  If interval is zero,
+// it is an hour less than SessionLifetime.  Errors logging in are
+// logged, and it tries again a few minutes later.  It is meant to be
+// run in its own goroutine by long-running programs.
+func (s *Session) KeepLoggedIn(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = SessionLifetime - time.Hour
+	}
+
+	var retry bool
+	for {
+		wait := interval
+		if t := s.LoggedIn(); !t.IsZero() {
+			wait = t.Add(interval).Sub(s.now())
+		}
+		if retry {
+			wait = reloginRetry
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		err := s.LoginContext(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logf("Error logging in again: %v", err)
+		}
+		retry = err != nil
+	}
+}
+
+// Ping checks that the Carwings service is reachable and accepts the
+// Session, with a lightweight request that doesn't reach the vehicle.
+// An expired session is logged in again, as with any other request,
+// so an error means the credentials were refused or the service is
+// unavailable.
+func (s *Session) Ping() error {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is like Ping, but uses ctx for its requests.
+func (s *Session) PingContext(ctx context.Context) error {
+	_, err := call[wire.Base](ctx, s, "BatteryStatusRecordsRequest.php", nil)
+	return err
+}
+
+// Vehicles returns the VINs of all vehicles on the account.  To use a
+// vehicle other than the first, create another Session with its VIN
+// and connect with the same credentials.
+func (s *Session) Vehicles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.vins...)
+}
+
+// Model returns the model of the Session's vehicle, from its VIN.
+func (s *Session) Model() Model {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return ModelOf(s.VIN)
+}
+
+// sessionStore returns where the session is saved, if anywhere.
+func (s *Session) sessionStore() Store {
+	if s.store != nil {
+		return s.store
+	}
+	if s.Filename != "" {
+		return FileStore(s.Filename)
+	}
+	return nil
+}
+
+func (s *Session) load(st Store) error {
+	data, err := st.Load()
+	if err != nil {
+		return err
+	}
+
+	m := map[string]string{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(m["tz"])
+	if err != nil {
+		loc = time.UTC
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The saved session is for a different vehicle
+	if s.VIN != "" && s.VIN != m["vin"] {
+		return fmt.Errorf("session is for VIN %s, not %s", m["vin"], s.VIN)
+	}
+
+	// Sessions saved before we kept track of all vehicles on the
+	// account need to log in again.
+	if m["vins"] == "" {
+		return errors.New("session has no vehicle list")
+	}
+
+	if s.VIN == "" {
+		s.VIN = m["vin"]
+	}
+	s.vins = strings.Split(m["vins"], ",")
+	s.customSessionID = m["customSessionID"]
+	s.tz = m["tz"]
+	s.loc = loc
+	// Sessions saved before this was kept have the zero time
+	s.loggedIn, _ = time.Parse(time.RFC3339, m["loggedIn"])
+
+	return nil
+}
+
+func (s *Session) save(st Store) error {
+	s.mu.Lock()
+	m := map[string]string{
+		"vin":             s.VIN,
+		"vins":            strings.Join(s.vins, ","),
+		"customSessionID": s.customSessionID,
+		"tz":              s.tz,
+		"loggedIn":        s.loggedIn.Format(time.RFC3339),
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return st.Save(append(data, '\n'))
+}
+
+func (s *Session) apiRequest(ctx context.Context, endpoint string, params url.Values, target wire.Response) error {
+	params = s.setCommonParams(params)
+
+	err := s.request(ctx, endpoint, params, target)
+	if errors.Is(err, ErrNotLoggedIn) {
+		if err := s.LoginContext(ctx); err != nil {
+			return err
+		}
+
+		params = s.setCommonParams(params)
+		return s.request(ctx, endpoint, params, target)
+	}
+
+	return err
+}
+
+func (s *Session) setCommonParams(params url.Values) url.Values {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	params.Set("RegionCode", s.Region)
+	params.Set("VIN", s.VIN)
+	params.Set("custom_sessionid", s.customSessionID)
+	params.Set("tz", s.tz)
+	return params
+}
+
+// location returns the time zone vehicle times are in: the one set
+// with WithLocation, or else the account's.
+func (s *Session) location() *time.Location {
+	if s.fixedLoc != nil {
+		return s.fixedLoc
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loc
+}
+
+// now returns the current time according to the session's Clock.
+func (s *Session) now() time.Time {
+	if s.clock != nil {
+		return s.clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,47 @@
+package carwings
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestLoginAgainKeepsVIN(t *testing.T) {
+	ts := newTestService(t, nil)
+	s := newTestSession(t, ts, WithVIN("VIN456"))
+
+	// Logging in again, as KeepLoggedIn and /admin/relogin do, while
+	// the VIN is read elsewhere mustn't race with it (go test -race)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if err := s.LoginContext(context.Background()); err != nil {
+				t.Errorf("LoginContext: %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if s.VIN != "VIN456" {
+			t.Fatalf("VIN = %q, want VIN456", s.VIN)
+		}
+	}
+	wg.Wait()
+
+	if got := ts.count("UserLoginRequest"); got != 11 {
+		t.Errorf("%d logins, want 11", got)
+	}
+}
+
+func TestLoginPicksFirstVehicle(t *testing.T) {
+	ts := newTestService(t, nil)
+	s := newTestSession(t, ts)
+
+	if s.VIN != "VIN123" {
+		t.Errorf("VIN = %q, want VIN123", s.VIN)
+	}
+	if vins := s.Vehicles(); len(vins) != 2 || vins[1] != "VIN456" {
+		t.Errorf("Vehicles() = %q, want [VIN123 VIN456]", vins)
+	}
+}
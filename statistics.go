@@ -0,0 +1,217 @@
+package carwings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/joeshaw/carwings/internal/wire"
+)
+
+// GetMonthlyStatistics gets the statistics for a particular month
+func (s *Session) GetMonthlyStatistics(month time.Time) (MonthlyStatistics, error) {
+	return s.GetMonthlyStatisticsContext(context.Background(), month)
+}
+
+// GetMonthlyStatisticsContext is like GetMonthlyStatistics, but uses ctx for its requests.
+func (s *Session) GetMonthlyStatisticsContext(ctx context.Context, month time.Time) (MonthlyStatistics, error) {
+	if ms, ok := s.cachedMonth(month); ok {
+		return ms, nil
+	}
+
+	ms, err := s.getMonthlyStatistics(ctx, month)
+	if err != nil {
+		return ms, err
+	}
+
+	if err := s.cacheMonth(month, ms); err != nil && s.debug() {
+		s.logf("Error caching statistics: %v", err)
+	}
+	return ms, nil
+}
+
+// maxConcurrentMonths is how many months GetMonthlyStatisticsRange
+// requests at once, to go easy on the service.
+const maxConcurrentMonths = 4
+
+// GetMonthlyStatisticsRange gets the statistics for every month from
+// the month of from to the month of to, inclusive, in order.  Several
+// months are requested at once, which is much faster than one after
+// another for long periods.
+func (s *Session) GetMonthlyStatisticsRange(from, to time.Time) ([]MonthlyStatistics, error) {
+	return s.GetMonthlyStatisticsRangeContext(context.Background(), from, to)
+}
+
+// GetMonthlyStatisticsRangeContext is like GetMonthlyStatisticsRange,
+// but uses ctx for its requests.
+func (s *Session) GetMonthlyStatisticsRangeContext(ctx context.Context, from, to time.Time) ([]MonthlyStatistics, error) {
+	loc := s.monthLocation(from)
+
+	// Months are requested by a day in the middle, so converting
+	// them to the account's time zone doesn't move them into the
+	// one before.
+	middle := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), 15, 12, 0, 0, 0, loc)
+	}
+	var months []time.Time
+	for month, last := middle(from), middle(to); !month.After(last); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		stats    = make([]MonthlyStatistics, len(months))
+		sem      = make(chan struct{}, maxConcurrentMonths)
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, month := range months {
+		wg.Add(1)
+		go func(i int, month time.Time) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			ms, err := s.GetMonthlyStatisticsContext(ctx, month)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			stats[i] = ms
+		}(i, month)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *Session) getMonthlyStatistics(ctx context.Context, month time.Time) (MonthlyStatistics, error) {
+	ms := MonthlyStatistics{}
+	params := url.Values{}
+	params.Set("TargetMonth", month.In(s.monthLocation(month)).Format("200601"))
+
+	resp, err := call[wire.Monthly](ctx, s, "PriceSimulatorDetailInfoRequest.php", params)
+	if err != nil {
+		return ms, err
+	}
+
+	// This field is an empty string instead of an object if there's no data.
+	var days []wire.DetailInfoDate
+	if raw := resp.Data.Detail.RawList; !wire.IsEmpty(raw) {
+		const field = "PriceSimulatorDetailInfoResponsePersonalData.PriceSimulatorDetailInfoDateList.PriceSimulatorDetailInfoDate."
+		if DebugUnknownFields {
+			s.reportUnknownFields("PriceSimulatorDetailInfoRequest.php", field, raw, &days)
+		}
+		if s.ParseMode == ParseStrict {
+			if err := checkStrict(field, raw, &days); err != nil {
+				return ms, err
+			}
+		}
+		if err := json.Unmarshal(raw, &days); err != nil {
+			return ms, &decodeError{err}
+		}
+	}
+
+	ms.EfficiencyScale = resp.Data.ElectricCostScale
+	ms.ElectricityRate = resp.Data.ElectricPrice.Float()
+	ms.ElectricityBill = resp.Data.ElectricBill.Float()
+	total := resp.Data.Total
+	ms.Total = MonthlyTotals{
+		Trips:              total.Trips.Int(),
+		PowerConsumed:      total.PowerConsumed.Float(),
+		PowerConsumedMotor: total.PowerConsumedMotor.Float(),
+		PowerRegenerated:   total.PowerRegenerated.Float(),
+		MetersTravelled:    total.MetersTravelled.Int(),
+		Efficiency:         total.Efficiency.Float(),
+		CO2Reduction:       total.CO2Reduction.Int(),
+	}
+	// Trip times are sent as the vehicle's local time, without a zone
+	loc := s.location()
+
+	ms.Dates = make([]DateDetail, 0, 31)
+	for _, day := range days {
+		trips := make([]TripDetail, 0, 10)
+		for _, trip := range day.Trips.List {
+			started := time.Time(trip.GPSDateTime.FixLocation(loc))
+			trips = append(trips, TripDetail{
+				TripId:             trip.TripId.Int(),
+				PowerConsumedTotal: trip.PowerConsumedTotal.Float(),
+				PowerConsumedMotor: trip.PowerConsumedMotor.Float(),
+				PowerRegenerated:   trip.PowerRegenerated.Float(),
+				Meters:             trip.Meters.Int(),
+				Efficiency:         trip.Efficiency.Float(),
+				CO2Reduction:       trip.CO2Reduction.Int(),
+				MapDisplayFlag:     trip.MapDisplayFlag,
+				GPSDateTime:        started,
+				Started:            started,
+			})
+		}
+		ms.Dates = append(ms.Dates, DateDetail{
+			TargetDate: day.TargetDate,
+			Trips:      trips,
+		})
+	}
+
+	return ms, nil
+}
+
+// GetDailyStatistics returns the statistics for a specified Date^W^W^Wtoday
+func (s *Session) GetDailyStatistics(day time.Time) (DailyStatistics, error) {
+	return s.GetDailyStatisticsContext(context.Background(), day)
+}
+
+// GetDailyStatisticsContext is like GetDailyStatistics, but uses ctx for its requests.
+func (s *Session) GetDailyStatisticsContext(ctx context.Context, day time.Time) (DailyStatistics, error) {
+	ds := DailyStatistics{}
+
+	params := url.Values{}
+	// TODO: There's a bug getting stats for any day other than today: we have guessed the
+	// TODO: name of the `TargetDate` parameter wrong :-(
+	// TODO: It isn't `TargetDate` or `DetailTargetDate`
+	// On the other hand, we can get/calculate all of this (and more) from the daily records in the
+	// MonthlyStatistics response, so maybe it's silly to do it this way?
+	// params.Set("DetailTargetDate", day.In(s.location()).Format("2006-01-02"))
+
+	resp, err := call[wire.Daily](ctx, s, "DriveAnalysisBasicScreenRequestEx.php", params)
+	if err != nil {
+		return ds, err
+	}
+
+	if resp.Data.Stats.TargetDate == "" {
+		return ds, errors.New("daily driving statistics not available")
+	}
+
+	ds.TargetDate, _ = time.ParseInLocation("2006-01-02", resp.Data.Stats.TargetDate, s.location())
+	ds.EfficiencyScale = resp.Data.ElectricCostScale
+	ds.Efficiency = resp.Data.Stats.ElectricMileage.Float()
+	ds.EfficiencyLevel = resp.Data.Stats.ElectricMileageLevel.Int()
+	ds.PowerConsumedMotor = resp.Data.Stats.PowerConsumptMoter.Float()
+	ds.PowerConsumedMotorLevel = resp.Data.Stats.PowerConsumptMoterLevel.Int()
+	ds.PowerRegeneration = resp.Data.Stats.PowerConsumptMinus.Float()
+	ds.PowerRegenerationLevel = resp.Data.Stats.PowerConsumptMinusLevel.Int()
+	ds.PowerConsumedAUX = resp.Data.Stats.PowerConsumptAUX.Float()
+	ds.PowerConsumedAUXLevel = resp.Data.Stats.PowerConsumptAUXLevel.Int()
+
+	return ds, nil
+}
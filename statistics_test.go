@@ -0,0 +1,193 @@
+package carwings
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// monthlyHandler returns a testHandler that responds to
+// PriceSimulatorDetailInfoRequest with a trip at each of the given GPS
+// times, which are the vehicle's local time without a zone, all on the
+// same day of the requested month.
+func monthlyHandler(gpsTimes ...string) testHandler {
+	return func(params url.Values) string {
+		var trips []string
+		for i, gps := range gpsTimes {
+			trips = append(trips, fmt.Sprintf(`{
+				"TripId": "%d",
+				"PowerConsumptTotal": "2461.12",
+				"PowerConsumptMoter": "3812.22",
+				"PowerConsumptMinus": "1351.1",
+				"TravelDistance": "17841",
+				"ElectricMileage": "13.8",
+				"CO2Reduction": "3",
+				"MapDisplayFlg": "NONACTIVE",
+				"GpsDatetime": %q
+			}`, i+1, gps))
+		}
+		return fmt.Sprintf(`{
+			"status": 200,
+			"PriceSimulatorDetailInfoResponsePersonalData": {
+				"TargetMonth": %q,
+				"ElectricPrice": "0.15",
+				"ElectricBill": "0.0",
+				"ElectricCostScale": "kWh/100km",
+				"PriceSimulatorDetailInfoDateList": {
+					"PriceSimulatorDetailInfoDate": [{
+						"TargetDate": "2018-08-31",
+						"PriceSimulatorDetailInfoTripList": {
+							"PriceSimulatorDetailInfoTrip": [%s]
+						},
+						"DisplayDate": "Aug 31"
+					}]
+				},
+				"PriceSimulatorTotalInfo": {
+					"TotalNumberOfTrips": "%d",
+					"TotalPowerConsumptTotal": "55.88882",
+					"TotalPowerConsumptMoter": "71.44184",
+					"TotalPowerConsumptMinus": "15.55302",
+					"TotalTravelDistance": "416252",
+					"TotalElectricMileage": "0.0134",
+					"TotalCO2Reductiont": "72"
+				},
+				"DisplayMonth": "Aug/2018"
+			}
+		}`, params.Get("TargetMonth"), strings.Join(trips, ","), len(gpsTimes))
+	}
+}
+
+func TestMonthlyStatisticsTripTimes(t *testing.T) {
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": monthlyHandler("2018-08-31T00:05:00", "2018-08-31T23:30:00"),
+	})
+
+	for _, r := range testRegions {
+		loc := loadLocation(t, r.zone)
+		s := newTestSession(t, ts, WithLocation(loc))
+
+		ms, err := s.GetMonthlyStatistics(time.Date(2018, 8, 15, 12, 0, 0, 0, loc))
+		if err != nil {
+			t.Fatalf("%s: %v", r.region, err)
+		}
+		if len(ms.Dates) != 1 || len(ms.Dates[0].Trips) != 2 {
+			t.Fatalf("%s: got %+v, want 1 day with 2 trips", r.region, ms.Dates)
+		}
+		if ms.Total.Trips != 2 || ms.Total.MetersTravelled != 416252 {
+			t.Errorf("%s: got totals %+v", r.region, ms.Total)
+		}
+
+		// The GPS times are the vehicle's local time, so they stay
+		// on the last day of the month in every time zone
+		for i, want := range []time.Time{
+			time.Date(2018, 8, 31, 0, 5, 0, 0, loc),
+			time.Date(2018, 8, 31, 23, 30, 0, 0, loc),
+		} {
+			trip := ms.Dates[0].Trips[i]
+			if !trip.Started.Equal(want) || trip.Started.Location() != loc {
+				t.Errorf("%s: trip %d started %v, want %v", r.region, i+1, trip.Started, want)
+			}
+			if !trip.GPSDateTime.Equal(trip.Started) {
+				t.Errorf("%s: trip %d GPSDateTime %v, want %v", r.region, i+1, trip.GPSDateTime, trip.Started)
+			}
+			if trip.Meters != 17841 || trip.Efficiency != 13.8 {
+				t.Errorf("%s: trip %d = %+v", r.region, i+1, trip)
+			}
+		}
+	}
+}
+
+func TestMonthlyStatisticsTargetMonth(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": func(params url.Values) string {
+			mu.Lock()
+			requested = append(requested, params.Get("TargetMonth"))
+			mu.Unlock()
+			return monthlyHandler()(params)
+		},
+	})
+
+	// Instants near the turn of August into September, which is a
+	// different month depending on the time zone
+	tests := []struct {
+		month time.Time
+		want  map[string]string // by region
+	}{
+		{
+			month: time.Date(2018, 9, 1, 2, 0, 0, 0, time.UTC),
+			want:  map[string]string{"NNA": "201808", "NE": "201809", "NML": "201809"},
+		},
+		{
+			month: time.Date(2018, 8, 31, 20, 0, 0, 0, time.UTC),
+			want:  map[string]string{"NNA": "201808", "NE": "201808", "NML": "201809"},
+		},
+		{
+			month: time.Date(2018, 8, 31, 23, 30, 0, 0, time.UTC),
+			want:  map[string]string{"NNA": "201808", "NE": "201809", "NML": "201809"},
+		},
+	}
+
+	for _, r := range testRegions {
+		s := newTestSession(t, ts, WithLocation(loadLocation(t, r.zone)))
+		for _, tt := range tests {
+			mu.Lock()
+			requested = nil
+			mu.Unlock()
+			if _, err := s.GetMonthlyStatistics(tt.month); err != nil {
+				t.Fatalf("%s: %v", r.region, err)
+			}
+			mu.Lock()
+			if len(requested) != 1 || requested[0] != tt.want[r.region] {
+				t.Errorf("%s: %v requested %q, want %s", r.region, tt.month, requested, tt.want[r.region])
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+func TestMonthlyStatisticsRangeMonths(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested = map[string]bool{}
+	)
+	ts := newTestService(t, map[string]testHandler{
+		"PriceSimulatorDetailInfoRequest": func(params url.Values) string {
+			mu.Lock()
+			requested[params.Get("TargetMonth")] = true
+			mu.Unlock()
+			return monthlyHandler()(params)
+		},
+	})
+
+	// The first and last instants of the range are in the first and
+	// last months in the session's time zone, though not in UTC
+	loc := loadLocation(t, "Asia/Tokyo")
+	s := newTestSession(t, ts, WithLocation(loc))
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, loc).UTC()
+	to := time.Date(2019, 1, 31, 23, 59, 0, 0, loc).UTC()
+
+	stats, err := s.GetMonthlyStatisticsRange(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 {
+		t.Errorf("got %d months, want 3", len(stats))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requested) != 3 {
+		t.Errorf("requested %v, want 201811 to 201901", requested)
+	}
+	for _, month := range []string{"201811", "201812", "201901"} {
+		if !requested[month] {
+			t.Errorf("%s not requested, got %v", month, requested)
+		}
+	}
+}
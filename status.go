@@ -0,0 +1,99 @@
+package carwings
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// VehicleStatus is everything known about the vehicle at once.  Each
+// part has its own error, since some may be unavailable while others
+// are not.
+type VehicleStatus struct {
+	Battery    BatteryStatus
+	BatteryErr error
+
+	Climate    ClimateStatus
+	ClimateErr error
+
+	Location    Location
+	LocationErr error
+
+	CabinTemp    int
+	CabinTempErr error
+}
+
+// VehicleStatus gathers the battery, climate control, location and
+// cabin temperature of the vehicle concurrently.  Locating the vehicle
+// and reading its cabin temperature wait for the vehicle to respond,
+// so use a ctx with a deadline to bound how long that takes.
+//
+// An error is returned only if ctx is done or every part failed;
+// otherwise the errors for the individual parts are in the
+// VehicleStatus.
+func (s *Session) VehicleStatus(ctx context.Context) (VehicleStatus, error) {
+	var (
+		vs VehicleStatus
+		wg sync.WaitGroup
+	)
+
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		vs.Battery, vs.BatteryErr = s.BatteryStatusContext(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		vs.Climate, vs.ClimateErr = s.ClimateControlStatusContext(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		key, err := s.LocateRequestContext(ctx)
+		if err == nil {
+			err = pollRequest(ctx, key, s.CheckLocateRequestContext)
+		}
+		vs.Location, vs.LocationErr = s.GetLocation(), err
+	}()
+	go func() {
+		defer wg.Done()
+		key, err := s.CabinTempRequestContext(ctx)
+		if err == nil {
+			err = pollRequest(ctx, key, s.CheckCabinTempRequestContext)
+		}
+		vs.CabinTemp, vs.CabinTempErr = s.GetCabinTemp(), err
+	}()
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return vs, err
+	}
+
+	if vs.BatteryErr != nil && vs.ClimateErr != nil && vs.LocationErr != nil && vs.CabinTempErr != nil {
+		return vs, vs.BatteryErr
+	}
+
+	return vs, nil
+}
+
+// pollRequest checks whether the request with the given result key has
+// finished every few seconds until it has, or until ctx is done.
+func pollRequest(ctx context.Context, key string, check func(context.Context, string) (bool, error)) error {
+	for {
+		// Requests take at least a few seconds
+		t := time.NewTimer(3 * time.Second)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		done, err := check(ctx, key)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package carwings
+
+import "os"
+
+// Store saves a Session's data between runs, such as its login state,
+// so that a new Session can reuse it instead of logging in again.
+type Store interface {
+	// Load returns the data last passed to Save.
+	Load() ([]byte, error)
+
+	// Save replaces the stored data.
+	Save(data []byte) error
+}
+
+// FileStore returns a Store that keeps the data in the named file.  A
+// leading ~ in filename is replaced with $HOME.
+func FileStore(filename string) Store {
+	if len(filename) > 0 && filename[0] == '~' {
+		filename = os.Getenv("HOME") + filename[1:]
+	}
+	return fileStore(filename)
+}
+
+type fileStore string
+
+func (f fileStore) Load() ([]byte, error) {
+	return os.ReadFile(string(f))
+}
+
+func (f fileStore) Save(data []byte) error {
+	if err := os.WriteFile(string(f), data, 0600); err != nil {
+		os.Remove(string(f))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package carwings
+
+import "github.com/joeshaw/carwings/types"
+
+// The vehicle status types are defined in the types package, and
+// available here under the same names.
+type (
+	BatteryStatus     = types.BatteryStatus
+	TimeToFull        = types.TimeToFull
+	PluginState       = types.PluginState
+	ChargingStatus    = types.ChargingStatus
+	ChargeType        = types.ChargeType
+	UsageCounters     = types.UsageCounters
+	ClimateStatus     = types.ClimateStatus
+	Location          = types.Location
+	VehicleLocation   = types.VehicleLocation
+	TripDetail        = types.TripDetail
+	DateDetail        = types.DateDetail
+	MonthlyTotals     = types.MonthlyTotals
+	MonthlyStatistics = types.MonthlyStatistics
+	DailyStatistics   = types.DailyStatistics
+	Model             = types.Model
+)
+
+// PluginState values
+const (
+	NotConnected       = types.NotConnected
+	Connected          = types.Connected
+	QCConnected        = types.QCConnected
+	InvalidPluginState = types.InvalidPluginState
+)
+
+// ChargingStatus values
+const (
+	NotCharging           = types.NotCharging
+	NormalCharging        = types.NormalCharging
+	RapidlyCharging       = types.RapidlyCharging
+	InvalidChargingStatus = types.InvalidChargingStatus
+)
+
+// ChargeType values
+const (
+	Level1Charge = types.Level1Charge
+	Level2Charge = types.Level2Charge
+	QuickCharge  = types.QuickCharge
+)
+
+// Model values
+const (
+	UnknownModel = types.UnknownModel
+	Leaf         = types.Leaf
+	ENV200       = types.ENV200
+)
+
+// ModelOf returns the model of the vehicle with the given VIN.  See
+// types.ModelOf.
+func ModelOf(vin string) Model {
+	return types.ModelOf(vin)
+}
+
+// EstimateChargingPower returns the approximate charging power, in kW,
+// between two battery status samples from a charging vehicle.  See
+// types.EstimateChargingPower.
+func EstimateChargingPower(prev, cur BatteryStatus) float64 {
+	return types.EstimateChargingPower(prev, cur)
+}
+
+// ChargeTypeOf returns how a vehicle with the given status is
+// charging.  See types.ChargeTypeOf.
+func ChargeTypeOf(cs ChargingStatus, ps PluginState, voltage int) ChargeType {
+	return types.ChargeTypeOf(cs, ps, voltage)
+}
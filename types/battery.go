@@ -0,0 +1,255 @@
+// Package types defines the vehicle status types returned by the
+// carwings package.  They are also available there under the same
+// names, so most programs don't need to import this package.
+package types
+
+import "time"
+
+// BatteryStatus contains information about the vehicle's state of
+// charge, current plugged-in state, charging status, and the time to
+// charge the battery to full.
+type BatteryStatus struct {
+	// Date and time this battery status was retrieved from the
+	// vehicle.
+	Timestamp time.Time
+
+	// Date and time the Session retrieved this status from the
+	// Carwings service, which may be long after Timestamp if the
+	// vehicle hasn't been asked for an update since.
+	Retrieved time.Time
+
+	// Total capacity of the battery.  Units unknown.
+	Capacity int
+
+	// Remaining battery level.  Units unknown, but same as Capacity.
+	Remaining int
+
+	// Remaining battery level in Watt Hours.
+	RemainingWH int
+
+	// Current state of charge.  In percent, should be roughly
+	// equivalent to Remaining / Capacity * 100.
+	StateOfCharge int // percent
+
+	// Estimated cruising range with climate control on, in
+	// meters.
+	CruisingRangeACOn int
+
+	// Estimated cruising range with climate control off, in
+	// meters.
+	CruisingRangeACOff int
+
+	// Current plugged-in state
+	PluginState PluginState
+
+	// Current charging status
+	ChargingStatus ChargingStatus
+
+	// Amount of time remaining until battery is fully charged,
+	// using different possible charging methods.
+	TimeToFull TimeToFull
+
+	// Estimated charging power, in kW.  This is derived from the
+	// change in RemainingWH since the previous battery status
+	// retrieved by the Session, and is zero if the vehicle isn't
+	// charging or there isn't enough data for an estimate.
+	ChargingPower float64
+
+	// Supply voltage of the charger, in volts, for vehicles that
+	// report it.  Zero if it isn't known.
+	ChargeVoltage int
+
+	// How the vehicle is charging, or "" if it isn't or that isn't
+	// known.  Together with ChargingPower, this tells a 3.3 kW
+	// Level 2 session from a 6.6 kW one.
+	ChargeType ChargeType
+}
+
+// UsageCounters are the vehicle's lifetime charge counts.  Frequent
+// quick charging ages the battery faster than charging at home, so
+// they tell how a used car's battery has been treated.
+type UsageCounters struct {
+	// Date and time the counts were retrieved from the vehicle.
+	Timestamp time.Time
+
+	// Number of DC quick charges, such as at a CHAdeMO charger.
+	QuickCharges int
+
+	// Number of Level 1 and Level 2 charges.
+	NormalCharges int
+}
+
+// EstimateChargingPower returns the approximate charging power, in kW,
+// between two battery status samples from a charging vehicle.  It
+// returns zero if cur isn't charging, the samples are out of order,
+// or the remaining energy didn't increase between them.
+func EstimateChargingPower(prev, cur BatteryStatus) float64 {
+	if cur.ChargingStatus != NormalCharging && cur.ChargingStatus != RapidlyCharging {
+		return 0
+	}
+
+	elapsed := cur.Timestamp.Sub(prev.Timestamp)
+	if prev.Timestamp.IsZero() || elapsed <= 0 {
+		return 0
+	}
+
+	// Not all vehicles report the remaining energy in Wh
+	if prev.RemainingWH <= 0 || cur.RemainingWH <= prev.RemainingWH {
+		return 0
+	}
+
+	return float64(cur.RemainingWH-prev.RemainingWH) / elapsed.Hours() / 1000
+}
+
+// ChargeTypeOf returns how a vehicle with the given status is
+// charging, from its charging status and its supply voltage in volts,
+// if known.
+func ChargeTypeOf(cs ChargingStatus, ps PluginState, voltage int) ChargeType {
+	switch {
+	case cs == RapidlyCharging || (cs == NormalCharging && ps == QCConnected):
+		return QuickCharge
+	case cs != NormalCharging:
+		return ""
+	case voltage >= 200:
+		return Level2Charge
+	case voltage > 0:
+		return Level1Charge
+	default:
+		return ""
+	}
+}
+
+// TimeToSOC estimates how long it will take to charge the battery to
+// the target state of charge, in percent, via different charging
+// methods.  The estimates are derived from TimeToFull assuming a
+// constant rate of charge.  Since vehicles charge more slowly as the
+// battery gets close to full, they are somewhat pessimistic.
+func (bs BatteryStatus) TimeToSOC(target int) TimeToFull {
+	if target > 100 {
+		target = 100
+	}
+	if bs.StateOfCharge >= target || bs.StateOfCharge >= 100 {
+		return TimeToFull{}
+	}
+
+	frac := float64(target-bs.StateOfCharge) / float64(100-bs.StateOfCharge)
+	scale := func(d time.Duration) time.Duration {
+		return (time.Duration(float64(d) * frac)).Round(time.Minute)
+	}
+
+	return TimeToFull{
+		Level1:      scale(bs.TimeToFull.Level1),
+		Level2:      scale(bs.TimeToFull.Level2),
+		Level2At6kW: scale(bs.TimeToFull.Level2At6kW),
+	}
+}
+
+// TimeToFull contains information about how long it will take to
+// charge the battery to full via different charging methods.
+type TimeToFull struct {
+	// Time to fully charge the battery using a 1.4 kW Level 1
+	// (120V 12A) trickle charge.
+	Level1 time.Duration
+
+	// Time to fully charge the battery using a 3.3 kW Level 2
+	// (240V ~15A) charge.
+	Level2 time.Duration
+
+	// Time to fully charge the battery using a 6.6 kW Level 2
+	// (240V ~30A) charge.
+	Level2At6kW time.Duration
+}
+
+// PluginState indicates whether and how the vehicle is plugged in.
+// It is separate from ChargingStatus, because the vehicle can be
+// plugged in but not actively charging.
+type PluginState string
+
+const (
+	// Not connected to a charger
+	NotConnected = PluginState("NOT_CONNECTED")
+
+	// Connected to a normal J1772 Level 1 or 2 charger
+	Connected = PluginState("CONNECTED")
+
+	// Connected to a high voltage DC quick charger (ChaDeMo)
+	QCConnected = PluginState("QC_CONNECTED")
+
+	// Invalid state, when updating data from the vehicle fails.
+	InvalidPluginState = PluginState("INVALID")
+)
+
+func (ps PluginState) String() string {
+	switch ps {
+	case NotConnected:
+		return "not connected"
+	case Connected:
+		return "connected"
+	case QCConnected:
+		return "connected to quick charger"
+	case InvalidPluginState:
+		return "invalid"
+	default:
+		return string(ps)
+	}
+}
+
+// ChargingStatus indicates whether and how the vehicle is charging.
+type ChargingStatus string
+
+const (
+	// Not charging
+	NotCharging = ChargingStatus("NOT_CHARGING")
+
+	// Normal charging from a Level 1 or 2 EVSE
+	NormalCharging = ChargingStatus("NORMAL_CHARGING")
+
+	// Rapidly charging from a ChaDeMo DC quick charger
+	RapidlyCharging = ChargingStatus("RAPIDLY_CHARGING")
+
+	// Invalid state, when updating data from the vehicle fails.
+	InvalidChargingStatus = ChargingStatus("INVALID")
+)
+
+func (cs ChargingStatus) String() string {
+	switch cs {
+	case NotCharging:
+		return "not charging"
+	case NormalCharging:
+		return "charging"
+	case RapidlyCharging:
+		return "rapidly charging"
+	case InvalidChargingStatus:
+		return "invalid"
+	default:
+		return string(cs)
+	}
+}
+
+// ChargeType indicates the kind of charger the vehicle is charging
+// from.
+type ChargeType string
+
+const (
+	// Charging from a 100-120V Level 1 EVSE
+	Level1Charge = ChargeType("LEVEL_1")
+
+	// Charging from a 200-240V Level 2 EVSE
+	Level2Charge = ChargeType("LEVEL_2")
+
+	// Charging from a ChaDeMo DC quick charger
+	QuickCharge = ChargeType("QUICK")
+)
+
+func (ct ChargeType) String() string {
+	switch ct {
+	case Level1Charge:
+		return "level 1"
+	case Level2Charge:
+		return "level 2"
+	case QuickCharge:
+		return "quick charge"
+	default:
+		return string(ct)
+	}
+}
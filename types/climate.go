@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+// ClimateStatus contains information about the vehicle's climate
+// control (AC or heater) status.
+type ClimateStatus struct {
+	// Date and time this status was retrieved from the vehicle.
+	LastOperationTime time.Time
+
+	// Date and time the Session retrieved this status from the
+	// Carwings service.
+	Retrieved time.Time
+
+	// The current climate control operation status.
+	Running bool
+
+	// Current plugged-in state
+	PluginState PluginState
+
+	// The amount of time the climate control system will run
+	// while on battery power, in seconds.
+	BatteryDuration int
+
+	// The amount of time the climate control system will run
+	// while plugged in, in seconds.
+	PluggedDuration int
+
+	// The climate preset temperature unit, F or C
+	TemperatureUnit string
+
+	// The climate preset temperature value
+	Temperature int
+
+	// Time the AC was stopped, or is scheduled to stop
+	ACStopTime time.Time
+
+	// Estimated cruising range with climate control on, in
+	// meters.
+	CruisingRangeACOn int
+
+	// Estimated cruising range with climate control off, in
+	// meters.
+	CruisingRangeACOff int
+}
@@ -0,0 +1,24 @@
+package types
+
+import "time"
+
+// VehicleLocation indicates the vehicle's current location.
+type VehicleLocation struct {
+	// Timestamp of the last time vehicle location was updated.
+	Timestamp time.Time
+
+	// Latitude of the vehicle
+	Latitude string
+
+	// Longitude of the vehicle
+	Longitude string
+}
+
+// Location is the position of the vehicle.
+type Location struct {
+	// Date and time the vehicle reported its position.
+	Timestamp time.Time
+
+	Latitude  float64
+	Longitude float64
+}
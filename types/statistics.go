@@ -0,0 +1,60 @@
+package types
+
+import "time"
+
+// TripDetail holds the details of each trip.  The JSON field names
+// are those used by the Carwings service.
+type TripDetail struct {
+	TripId             int       `json:",string"`
+	PowerConsumedTotal float64   `json:"PowerConsumptTotal,string"`
+	PowerConsumedMotor float64   `json:"PowerConsumptMoter,string"`
+	PowerRegenerated   float64   `json:"PowerConsumptMinus,string"`
+	Meters             int       `json:"TravelDistance,string"`
+	Efficiency         float64   `json:"ElectricMileage,string"`
+	CO2Reduction       int       `json:",string"`
+	MapDisplayFlag     string    `json:"MapDisplayFlg"`
+	GPSDateTime        time.Time `json:"GpsDatetime"`
+	Started            time.Time `json:",omitempty"`
+}
+
+// DateDetail is the detail for a single date
+type DateDetail struct {
+	TargetDate string
+	Trips      []TripDetail
+}
+
+// MonthlyTotals holds the various totals of things for the whole month
+type MonthlyTotals struct {
+	Trips              int     `json:"TotalNumberOfTrips,string"`
+	PowerConsumed      float64 `json:"TotalPowerConsumptTotal,string"`
+	PowerConsumedMotor float64 `json:"TotalPowerConsumptMoter,string"`
+	PowerRegenerated   float64 `json:"TotalPowerConsumptMinus,string"`
+	MetersTravelled    int     `json:"TotalTravelDistance,string"`
+	Efficiency         float64 `json:"TotalElectricMileage,string"`
+	CO2Reduction       int     `json:"TotalCO2Reductiont,string"`
+}
+
+// MonthlyStatistics is the structure returned which includes
+// all of the trips and all of the totals as well as the electricity rate
+// informtion that has been supplied to CarWings.
+type MonthlyStatistics struct {
+	EfficiencyScale string
+	ElectricityRate float64
+	ElectricityBill float64
+	Dates           []DateDetail
+	Total           MonthlyTotals
+}
+
+// DailyStatistics holds the statistics for a day
+type DailyStatistics struct {
+	TargetDate              time.Time
+	EfficiencyScale         string
+	Efficiency              float64 `json:",string"`
+	EfficiencyLevel         int     `json:",string"`
+	PowerConsumedMotor      float64 `json:",string"`
+	PowerConsumedMotorLevel int     `json:",string"`
+	PowerRegeneration       float64 `json:",string"`
+	PowerRegenerationLevel  int     `json:",string"`
+	PowerConsumedAUX        float64 `json:",string"`
+	PowerConsumedAUXLevel   int     `json:",string"`
+}
@@ -0,0 +1,27 @@
+package types
+
+import "strings"
+
+// Model is the model of a vehicle on Carwings.
+type Model string
+
+// Model values
+const (
+	UnknownModel Model = ""
+	Leaf         Model = "LEAF"
+	ENV200       Model = "e-NV200"
+)
+
+// ModelOf returns the model of the vehicle with the given VIN.  The
+// e-NV200 van is the only vehicle on Carwings besides the LEAF, and it
+// is built in Barcelona, so its VIN starts with the manufacturer code
+// of Nissan Motor Ibérica, VSK.
+func ModelOf(vin string) Model {
+	switch {
+	case vin == "":
+		return UnknownModel
+	case strings.HasPrefix(strings.ToUpper(vin), "VSK"):
+		return ENV200
+	}
+	return Leaf
+}
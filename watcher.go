@@ -0,0 +1,197 @@
+package carwings
+
+import (
+	"context"
+	"time"
+)
+
+// An Event is a change in the vehicle's status noticed by a Watcher:
+// one of SOCChanged, PluggedIn, Unplugged, ChargingStarted,
+// ChargingStopped, ClimateChanged or WatchError.
+type Event interface {
+	event()
+}
+
+// SOCChanged is sent when the state of charge changes.
+type SOCChanged struct {
+	VIN      string
+	From, To int
+	Battery  BatteryStatus
+}
+
+// PluggedIn is sent when the vehicle is plugged in.
+type PluggedIn struct {
+	VIN     string
+	Battery BatteryStatus
+}
+
+// Unplugged is sent when the vehicle is unplugged.
+type Unplugged struct {
+	VIN     string
+	Battery BatteryStatus
+}
+
+// ChargingStarted is sent when the vehicle starts charging.
+type ChargingStarted struct {
+	VIN     string
+	Battery BatteryStatus
+}
+
+// ChargingStopped is sent when the vehicle stops charging, whether
+// it's full or not.
+type ChargingStopped struct {
+	VIN     string
+	Battery BatteryStatus
+}
+
+// ClimateChanged is sent when the climate control turns on or off.
+type ClimateChanged struct {
+	VIN     string
+	Climate ClimateStatus
+}
+
+// WatchError is sent when an update fails.  The Watcher carries on
+// with the next one.
+type WatchError struct {
+	VIN string
+	Err error
+}
+
+func (SOCChanged) event()      {}
+func (PluggedIn) event()       {}
+func (Unplugged) event()       {}
+func (ChargingStarted) event() {}
+func (ChargingStopped) event() {}
+func (ClimateChanged) event()  {}
+func (WatchError) event()      {}
+
+// A Watcher retrieves the vehicle's status on an interval and sends
+// an Event on Events for each change from the last one.  Nothing is
+// sent for the first status, which there's nothing to compare with.
+//
+//	w := carwings.NewWatcher(s, 15*time.Minute)
+//	go w.Run(ctx)
+//	for ev := range w.Events {
+//		switch ev := ev.(type) {
+//		case carwings.PluggedIn:
+//			...
+//		}
+//	}
+type Watcher struct {
+	// Events receives the changes.  It is closed when Run returns.
+	Events <-chan Event
+
+	// Update, if true, asks the vehicle for new data before each
+	// retrieval, as with UpdateStatus, rather than using whatever it
+	// last reported.  This wakes up its telematics unit, draining
+	// its 12V battery a little each time.
+	Update bool
+
+	s        *Session
+	interval time.Duration
+	events   chan Event
+
+	battery BatteryStatus
+	climate ClimateStatus
+	seen    bool
+}
+
+// NewWatcher returns a Watcher for the vehicle of the Session, which
+// retrieves its status every interval once Run is called.
+func NewWatcher(s *Session, interval time.Duration) *Watcher {
+	events := make(chan Event, 16)
+	return &Watcher{
+		Events:   events,
+		s:        s,
+		interval: interval,
+		events:   events,
+	}
+}
+
+// Run retrieves the status straight away and then every interval,
+// until ctx is done.  It closes Events when it returns, and must only
+// be called once.
+func (w *Watcher) Run(ctx context.Context) {
+	defer close(w.events)
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		w.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// check retrieves the status once and sends events for what changed.
+func (w *Watcher) check(ctx context.Context) {
+	// An update that takes longer than the interval is given up on,
+	// rather than holding up the next one.
+	reqCtx, cancel := context.WithTimeout(ctx, w.interval)
+	defer cancel()
+
+	vin := w.s.VIN
+
+	var bs BatteryStatus
+	var cs ClimateStatus
+	p := Poller{
+		Session:         w.s,
+		Update:          w.Update,
+		UpdateTimeout:   w.interval,
+		OnBatteryStatus: func(b BatteryStatus) { bs = b },
+		OnClimateStatus: func(c ClimateStatus) { cs = c },
+	}
+	if err := p.Poll(reqCtx); err != nil {
+		w.send(ctx, WatchError{VIN: vin, Err: err})
+		return
+	}
+
+	prev, prevClimate, seen := w.battery, w.climate, w.seen
+	w.battery, w.climate, w.seen = bs, cs, true
+	if !seen {
+		return
+	}
+
+	if bs.StateOfCharge != prev.StateOfCharge {
+		w.send(ctx, SOCChanged{VIN: vin, From: prev.StateOfCharge, To: bs.StateOfCharge, Battery: bs})
+	}
+
+	switch {
+	case prev.PluginState == NotConnected && pluggedIn(bs):
+		w.send(ctx, PluggedIn{VIN: vin, Battery: bs})
+	case pluggedIn(prev) && bs.PluginState == NotConnected:
+		w.send(ctx, Unplugged{VIN: vin, Battery: bs})
+	}
+
+	switch {
+	case !charging(prev) && charging(bs):
+		w.send(ctx, ChargingStarted{VIN: vin, Battery: bs})
+	case charging(prev) && !charging(bs):
+		w.send(ctx, ChargingStopped{VIN: vin, Battery: bs})
+	}
+
+	if cs.Running != prevClimate.Running {
+		w.send(ctx, ClimateChanged{VIN: vin, Climate: cs})
+	}
+}
+
+// send sends ev unless ctx is done first.
+func (w *Watcher) send(ctx context.Context, ev Event) {
+	select {
+	case w.events <- ev:
+	case <-ctx.Done():
+	}
+}
+
+func charging(bs BatteryStatus) bool {
+	return bs.ChargingStatus == NormalCharging || bs.ChargingStatus == RapidlyCharging
+}
+
+func pluggedIn(bs BatteryStatus) bool {
+	return bs.PluginState == Connected || bs.PluginState == QCConnected
+}